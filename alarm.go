@@ -0,0 +1,185 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ThresholdOp is the comparison of a ThresholdRule.
+type ThresholdOp string
+
+// Threshold comparisons. A rule fires when "value <op> rule value" holds.
+const (
+	ThresholdGT ThresholdOp = "gt"
+	ThresholdLT ThresholdOp = "lt"
+	ThresholdEQ ThresholdOp = "eq"
+	ThresholdNE ThresholdOp = "ne"
+)
+
+// ThresholdRule describes a condition on polled values that raises an
+// Alarm.
+type ThresholdRule struct {
+	// Name identifies the rule in alarms. Optional.
+	Name string
+	// OID is the object the rule applies to.
+	OID OID
+	// Prefix applies the rule to every object in the subtree under OID,
+	// such as all instances of a table column.
+	Prefix bool
+	// Op is the comparison.
+	Op ThresholdOp
+	// Value is the threshold, parsed according to the type of each
+	// variable: a signed or unsigned number for numeric types, a dotted
+	// OID or an IP address for those types, and plain text otherwise.
+	Value string
+}
+
+// matches reports whether the rule applies to oid.
+func (r ThresholdRule) matches(oid OID) bool {
+	if r.Prefix {
+		return oid.HasPrefix(r.OID)
+	}
+	return oid.Equal(r.OID)
+}
+
+// Alarm is a threshold violation found by EvaluateThresholds.
+type Alarm struct {
+	Rule     ThresholdRule
+	Variable Variable
+}
+
+// String returns a human-readable description of the alarm.
+func (a Alarm) String() string {
+	name := a.Rule.Name
+	if name == "" {
+		name = a.Rule.OID.String()
+	}
+	value := a.Variable.Value
+	if a.Variable.Type == TypeOctetString {
+		value = a.Variable.AsString()
+	}
+	return fmt.Sprintf("%s: %s = %v (%s %s)", name, a.Variable.OID, value, a.Rule.Op, a.Rule.Value)
+}
+
+// EvaluateThresholds checks polled variables against rules and returns an
+// alarm for every variable that violates a rule, in variable order.
+//
+// Comparisons follow the variable type: numeric types compare as numbers,
+// OIDs in lexicographic OID order, and OCTET STRINGs as text. Exception
+// values (noSuchObject, noSuchInstance, endOfMibView) never match, nor does
+// a rule whose Value cannot be parsed for the variable's type or whose Op
+// is unknown.
+func EvaluateThresholds(vars []Variable, rules []ThresholdRule) []Alarm {
+	var alarms []Alarm
+	for _, v := range vars {
+		for _, rule := range rules {
+			if !rule.matches(v.OID) {
+				continue
+			}
+			c, ok := compareThreshold(v, rule.Value)
+			if !ok {
+				continue
+			}
+			if thresholdHolds(rule.Op, c) {
+				alarms = append(alarms, Alarm{Rule: rule, Variable: v})
+			}
+		}
+	}
+	return alarms
+}
+
+// thresholdHolds applies op to the result of a three-way comparison.
+func thresholdHolds(op ThresholdOp, c int) bool {
+	switch op {
+	case ThresholdGT:
+		return c > 0
+	case ThresholdLT:
+		return c < 0
+	case ThresholdEQ:
+		return c == 0
+	case ThresholdNE:
+		return c != 0
+	default:
+		return false
+	}
+}
+
+// compareThreshold compares the value of v with the threshold s parsed for
+// v's type, returning -1, 0 or +1. It returns false if the two cannot be
+// compared.
+func compareThreshold(v Variable, s string) (int, bool) {
+	s = strings.TrimSpace(s)
+
+	switch v.Type {
+	case TypeInteger:
+		n, ok := v.AsInt()
+		t, err := strconv.ParseInt(s, 10, 64)
+		if !ok || err != nil {
+			return 0, false
+		}
+		return cmp.Compare(n, t), true
+
+	case TypeCounter32, TypeGauge32, TypeTimeTicks, TypeCounter64, TypeUInteger32:
+		n, ok := v.AsUint()
+		if !ok {
+			return 0, false
+		}
+		t, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			// A negative threshold is below every unsigned value.
+			if _, serr := strconv.ParseInt(s, 10, 64); serr == nil {
+				return 1, true
+			}
+			return 0, false
+		}
+		return cmp.Compare(n, t), true
+
+	case TypeObjectIdentifier:
+		oid, ok := v.Value.(OID)
+		t, err := ParseOID(s)
+		if !ok || err != nil {
+			return 0, false
+		}
+		return oid.Compare(t), true
+
+	case TypeIPAddress:
+		var ip net.IP
+		switch val := v.Value.(type) {
+		case net.IP:
+			ip = val
+		case []byte:
+			ip = val
+		case string:
+			ip = net.ParseIP(val)
+		}
+		t := net.ParseIP(s)
+		if ip == nil || t == nil {
+			return 0, false
+		}
+		return bytes.Compare(ip.To16(), t.To16()), true
+
+	case TypeOctetString:
+		return strings.Compare(v.AsString(), s), true
+
+	default:
+		return 0, false
+	}
+}
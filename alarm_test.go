@@ -0,0 +1,89 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestEvaluateThresholds(t *testing.T) {
+	oid := MustParseOID("1.3.6.1.4.1.99999.1.0")
+	tests := []struct {
+		name  string
+		v     Variable
+		op    ThresholdOp
+		value string
+		want  bool
+	}{
+		{"integer gt", Variable{Type: TypeInteger, Value: 5}, ThresholdGT, "4", true},
+		{"integer gt equal", Variable{Type: TypeInteger, Value: 5}, ThresholdGT, "5", false},
+		{"integer lt negative", Variable{Type: TypeInteger, Value: -3}, ThresholdLT, "-2", true},
+		{"integer eq", Variable{Type: TypeInteger, Value: 5}, ThresholdEQ, " 5 ", true},
+		{"integer ne", Variable{Type: TypeInteger, Value: 5}, ThresholdNE, "5", false},
+		{"integer unparsable", Variable{Type: TypeInteger, Value: 5}, ThresholdNE, "five", false},
+
+		{"counter gt", Variable{Type: TypeCounter32, Value: uint32(4000000000)}, ThresholdGT, "3999999999", true},
+		{"counter64 lt", Variable{Type: TypeCounter64, Value: uint64(1 << 40)}, ThresholdLT, "1099511627777", true},
+		{"gauge eq", Variable{Type: TypeGauge32, Value: uint32(80)}, ThresholdEQ, "80", true},
+		{"timeticks ne", Variable{Type: TypeTimeTicks, Value: uint32(100)}, ThresholdNE, "100", false},
+		{"unsigned above negative", Variable{Type: TypeGauge32, Value: uint32(0)}, ThresholdGT, "-1", true},
+
+		{"string eq", Variable{Type: TypeOctetString, Value: []byte("down")}, ThresholdEQ, "down", true},
+		{"string ne", Variable{Type: TypeOctetString, Value: []byte("up")}, ThresholdNE, "down", true},
+		{"string gt", Variable{Type: TypeOctetString, Value: []byte("b")}, ThresholdGT, "a", true},
+		{"string lt", Variable{Type: TypeOctetString, Value: []byte("b")}, ThresholdLT, "a", false},
+
+		{"OID eq", Variable{Type: TypeObjectIdentifier, Value: MustParseOID("1.3.6.1.4.1.9.1.1208")}, ThresholdEQ, "1.3.6.1.4.1.9.1.1208", true},
+		{"OID lt", Variable{Type: TypeObjectIdentifier, Value: MustParseOID("1.3.6.1.4.1.9.1.2")}, ThresholdLT, "1.3.6.1.4.1.9.1.10", true},
+		{"IP address eq", Variable{Type: TypeIPAddress, Value: net.IP{192, 0, 2, 1}}, ThresholdEQ, "192.0.2.1", true},
+		{"IP address gt", Variable{Type: TypeIPAddress, Value: net.IP{192, 0, 2, 10}}, ThresholdGT, "192.0.2.9", true},
+
+		{"exception", Variable{Type: TypeNoSuchInstance}, ThresholdNE, "0", false},
+		{"unknown op", Variable{Type: TypeInteger, Value: 5}, "ge", "5", false},
+	}
+	for _, tt := range tests {
+		tt.v.OID = oid
+		rule := ThresholdRule{OID: oid, Op: tt.op, Value: tt.value}
+		alarms := EvaluateThresholds([]Variable{tt.v}, []ThresholdRule{rule})
+		if got := len(alarms) == 1; got != tt.want {
+			t.Errorf("%s: %v %s %q raised %d alarms, want alarm = %t", tt.name, tt.v.Value, tt.op, tt.value, len(alarms), tt.want)
+		}
+	}
+}
+
+func TestEvaluateThresholdsPrefix(t *testing.T) {
+	column := MustParseOID("1.3.6.1.2.1.2.2.1.8") // ifOperStatus
+	vars := []Variable{
+		{OID: column.Append(1), Type: TypeInteger, Value: 1},
+		{OID: column.Append(2), Type: TypeInteger, Value: 2},
+		{OID: MustParseOID("1.3.6.1.2.1.2.2.1.7.3"), Type: TypeInteger, Value: 2},
+		{OID: column.Append(3), Type: TypeInteger, Value: 2},
+	}
+	rules := []ThresholdRule{
+		{Name: "link down", OID: column, Prefix: true, Op: ThresholdNE, Value: "1"},
+		{Name: "exact", OID: column, Op: ThresholdNE, Value: "1"},
+	}
+
+	alarms := EvaluateThresholds(vars, rules)
+	if len(alarms) != 2 {
+		t.Fatalf("raised %d alarms, want 2: %v", len(alarms), alarms)
+	}
+	for i, index := range []int{2, 3} {
+		if a := alarms[i]; a.Rule.Name != "link down" || !a.Variable.OID.Equal(column.Append(index)) {
+			t.Errorf("alarm %d = %s", i, a)
+		}
+	}
+}
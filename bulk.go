@@ -0,0 +1,71 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+// bulkMessageOverhead is a conservative estimate of the bytes a response
+// spends outside its varbinds: message header, community and PDU fields.
+const bulkMessageOverhead = 100
+
+// bulkTuner adapts the max-repetitions of a bulk walk to a message size
+// budget. It estimates the varbind size from previous responses and backs
+// off when the agent answers tooBig.
+type bulkTuner struct {
+	limit       int        // configured max-repetitions
+	maxSize     func() int // byte budget, 0 for none
+	repetitions int        // max-repetitions for the next request
+}
+
+// newBulkTuner returns a tuner for a walk. maxMessageSize is asked for the
+// budget before every request, since an SNMPv3 agent only advertises its
+// msgMaxSize once the walk has started.
+func newBulkTuner(maxRepetitions int, maxMessageSize func() int) *bulkTuner {
+	return &bulkTuner{
+		limit:       maxRepetitions,
+		maxSize:     maxMessageSize,
+		repetitions: maxRepetitions,
+	}
+}
+
+// observe sizes the next request from the average varbind size of vars.
+func (t *bulkTuner) observe(vars []Variable) {
+	maxSize := t.maxSize()
+	if maxSize <= 0 || len(vars) == 0 {
+		return
+	}
+
+	size := 0
+	for i := range vars {
+		data, err := encodeVariable(&vars[i])
+		if err != nil {
+			return
+		}
+		size += len(data) + 4 // SEQUENCE header
+	}
+	avg := (size + len(vars) - 1) / len(vars)
+
+	t.repetitions = max(1, min(t.limit, (maxSize-bulkMessageOverhead)/avg))
+}
+
+// shrink halves max-repetitions after a tooBig response. It returns false
+// when there is nothing left to shrink.
+func (t *bulkTuner) shrink() bool {
+	if t.repetitions <= 1 {
+		return false
+	}
+	t.repetitions /= 2
+	// Keep the smaller size for the rest of the walk.
+	t.limit = t.repetitions
+	return true
+}
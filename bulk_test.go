@@ -0,0 +1,122 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+// TestWalkAgentMaxMessageSize checks that a bulk walk sizes its requests for
+// the msgMaxSize an SNMPv3 agent advertises during discovery.
+func TestWalkAgentMaxMessageSize(t *testing.T) {
+	root := OID{1, 3, 6, 1, 4, 1, 99999, 1}
+	const rows = 200
+	value := bytes.Repeat([]byte("x"), 40)
+
+	agent := &v3Agent{
+		engineID: testEngineID,
+		boots:    3,
+		time:     1000,
+		maxSize:  1000,
+		user:     newUSMUser("monitor", SHA, "authpass1", NoPriv, ""),
+		handler: func(req *ScopedPDU) *PDU {
+			next := 1
+			if pdu := req.PDU; len(pdu.Variables) > 0 && len(pdu.Variables[0].OID) > len(root) {
+				next = pdu.Variables[0].OID[len(root)] + 1
+			}
+			var vars []Variable
+			for i := 0; i < req.PDU.MaxRepetitions; i++ {
+				if next+i > rows {
+					vars = append(vars, Variable{OID: OID{1, 3, 6, 1, 4, 1, 99999, 2}, Type: TypeInteger, Value: 0})
+					break
+				}
+				vars = append(vars, Variable{OID: append(root.Copy(), next+i), Type: TypeOctetString, Value: value})
+			}
+			return &PDU{Type: PDUGetResponse, Variables: vars}
+		},
+	}
+	port := agent.start(t)
+	c := newTestClient(t, port,
+		WithVersion(Version3),
+		WithSecurityLevel(AuthNoPriv),
+		WithSecurityName("monitor"),
+		WithAuth(SHA, "authpass1"),
+		WithMaxRepetitions(50))
+
+	vars, stats, err := c.WalkWithStats(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != rows {
+		t.Fatalf("walk returned %d variables, want %d", len(vars), rows)
+	}
+	if got := c.AgentMaxMessageSize(); got != 1000 {
+		t.Errorf("AgentMaxMessageSize = %d, want 1000", got)
+	}
+	if got := c.MaxMessageSize(); got != 1000 {
+		t.Errorf("MaxMessageSize = %d, want 1000", got)
+	}
+	if stats.MaxRepetitions == 0 || stats.MaxRepetitions >= 50 {
+		t.Errorf("last max-repetitions = %d, want it lowered for a 1000 byte agent", stats.MaxRepetitions)
+	}
+}
+
+// TestWalkBulkFallback checks that a walk falls back to GETNEXT when the
+// agent answers GETBULK with genErr, and that later walks on the same
+// connection skip GETBULK.
+func TestWalkBulkFallback(t *testing.T) {
+	root := OID{1, 3, 6, 1, 4, 1, 99999, 1}
+	var bulks atomic.Int32
+	port := mockAgent(t, func(req *Message) *Message {
+		if req.PDU.Type == PDUGetBulkRequest {
+			bulks.Add(1)
+			resp := reply(req, req.PDU.Variables...)
+			resp.PDU.ErrorStatus = GenErr
+			resp.PDU.ErrorIndex = 1
+			return resp
+		}
+		next := 1
+		if oid := req.PDU.Variables[0].OID; len(oid) > len(root) {
+			next = oid[len(root)] + 1
+		}
+		if next > 3 {
+			return reply(req, Variable{OID: OID{1, 3, 6, 1, 4, 1, 99999, 2}, Type: TypeInteger, Value: 0})
+		}
+		return reply(req, Variable{OID: append(root.Copy(), next), Type: TypeInteger, Value: next})
+	})
+	c := newTestClient(t, port)
+
+	for i := 0; i < 2; i++ {
+		vars, stats, err := c.WalkWithStats(context.Background(), root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(vars) != 3 {
+			t.Errorf("walk %d returned %d variables, want 3", i+1, len(vars))
+		}
+		if !stats.BulkFallback {
+			t.Errorf("walk %d: BulkFallback not set", i+1)
+		}
+	}
+	if got := bulks.Load(); got != 1 {
+		t.Errorf("agent received %d GETBULK requests, want 1", got)
+	}
+	if !c.bulkUnsupported.Load() {
+		t.Error("GETBULK not marked unsupported for the connection")
+	}
+}
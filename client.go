@@ -15,13 +15,19 @@
 package snmp
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand"
 	"net"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -41,8 +47,60 @@ type Client struct {
 	requestIDLock sync.Mutex
 
 	// Pending requests
-	pending     map[int32]chan *PDU
+	pending     map[int32]*pendingRequest
 	pendingLock sync.RWMutex
+
+	// Incremented on every (re)connect, so responses read from one
+	// connection are never delivered to requests sent on another
+	generation atomic.Uint64
+
+	// Local socket path bound for unixgram transports
+	localSocket string
+
+	// Closed when an in-progress reconnection finishes, and the function
+	// that cancels it (guarded by mu)
+	reconnected     chan struct{}
+	cancelReconnect context.CancelFunc
+
+	// Set once the first connection succeeds
+	everConnected atomic.Bool
+
+	// Responses that matched no pending request; unlike the metric it is
+	// never reset
+	mismatches atomic.Int64
+
+	// Local SNMPv3 engine, for notifications this client originates
+	local atomic.Pointer[localEngine]
+
+	// Set when the agent failed GETBULK but served GETNEXT; walks then use
+	// GETNEXT until the next (re)connect
+	bulkUnsupported atomic.Bool
+
+	// Time of the last datagram sent, in Unix nanoseconds
+	lastSent atomic.Int64
+
+	// SNMPv3 user, and what is known of the agent engine (guarded by
+	// engineMu); discoverMu lets a single request discover the engine
+	usm        *usmUser
+	engine     agentEngine
+	engineMu   sync.Mutex
+	discoverMu sync.Mutex
+}
+
+// pendingRequest is a request awaiting its response.
+type pendingRequest struct {
+	ch chan response
+	// gen is the connection generation the request was last sent on.
+	gen atomic.Uint64
+}
+
+// response is delivered to a pending request by the read loop.
+type response struct {
+	pdu  *PDU
+	size int
+	err  error
+	// v3 is the SNMPv3 message the PDU came in, nil for SNMPv1/v2c.
+	v3 *v3Message
 }
 
 // NewClient creates a new SNMP client.
@@ -51,58 +109,143 @@ func NewClient(opts ...Option) *Client {
 	for _, opt := range opts {
 		opt(options)
 	}
+	options.applyVersionDefaults()
+	// Invalid communities are reported by Connect.
+	options.Community, options.NotifyCommunity, _ = options.communities()
 
 	logger := options.Logger
 	if logger == nil {
 		logger = slog.Default()
 	}
 
+	metrics := NewMetrics()
+	if options.LatencyBuckets != nil {
+		// Invalid bounds are reported by Connect.
+		if h, err := NewLatencyHistogramWithBounds(options.LatencyBuckets); err == nil {
+			metrics.RequestLatency = h
+		}
+	}
+
 	c := &Client{
 		opts:      options,
 		done:      make(chan struct{}),
-		metrics:   NewMetrics(),
+		metrics:   metrics,
 		logger:    logger,
-		pending:   make(map[int32]chan *PDU),
+		pending:   make(map[int32]*pendingRequest),
 		requestID: rand.Int31(),
 	}
+	if options.Version == Version3 {
+		c.usm = newUSMUser(options.SecurityName, options.AuthProtocol, options.AuthPassphrase,
+			options.PrivProtocol, options.PrivPassphrase)
+	}
 
 	return c
 }
 
+// Derive creates a new, unconnected client that starts from a copy of the
+// options of c and applies opts on top, for example to query the same
+// agent with another community. c is not affected, and the new client has
+// its own metrics.
+func (c *Client) Derive(opts ...Option) *Client {
+	base := c.opts.Clone()
+	return NewClient(append([]Option{func(o *ClientOptions) { *o = *base }}, opts...)...)
+}
+
 // Connect establishes a connection to the SNMP agent.
 func (c *Client) Connect(ctx context.Context) error {
 	if !c.state.CompareAndSwap(int32(StateDisconnected), int32(StateConnecting)) {
 		return ErrAlreadyConnected
 	}
 
-	if c.opts.Target == "" {
+	if c.opts.Target == "" && c.opts.UnixSocket == "" {
 		c.state.Store(int32(StateDisconnected))
 		return fmt.Errorf("snmp: no target configured")
 	}
 
-	c.metrics.ConnectionAttempts.Add(1)
+	if _, _, err := c.opts.communities(); err != nil {
+		if !c.opts.LenientCommunity {
+			c.state.Store(int32(StateDisconnected))
+			return err
+		}
+		c.logger.Warn("using invalid community string", "error", err)
+	}
+
+	if c.opts.LatencyBuckets != nil {
+		if err := validateLatencyBounds(c.opts.LatencyBuckets); err != nil {
+			c.state.Store(int32(StateDisconnected))
+			return err
+		}
+	}
+
+	if err := c.opts.validateRequestValueType(); err != nil {
+		c.state.Store(int32(StateDisconnected))
+		return err
+	}
+
+	if _, err := c.localEngine(); err != nil {
+		c.state.Store(int32(StateDisconnected))
+		return err
+	}
 
-	// Build address
-	addr := fmt.Sprintf("%s:%d", c.opts.Target, c.opts.Port)
+	c.metrics.ConnectionAttempts.Add(1)
 
-	// Connect with timeout
-	dialer := net.Dialer{Timeout: c.opts.Timeout}
-	conn, err := dialer.DialContext(ctx, "udp", addr)
+	var (
+		addr string
+		conn net.Conn
+		err  error
+	)
+
+	if c.opts.UnixSocket != "" {
+		addr = c.opts.UnixSocket
+		conn, err = c.dialUnix()
+	} else {
+		// Build address
+		addr = fmt.Sprintf("%s:%d", c.opts.Target, c.opts.Port)
+
+		// Connect with timeout
+		dialer := net.Dialer{Timeout: c.opts.Timeout}
+		if c.opts.LocalAddr != "" {
+			local, resolveErr := net.ResolveUDPAddr("udp", c.opts.LocalAddr)
+			if resolveErr != nil {
+				c.state.Store(int32(StateDisconnected))
+				return fmt.Errorf("snmp: invalid local address %q: %w", c.opts.LocalAddr, resolveErr)
+			}
+			dialer.LocalAddr = local
+		}
+		conn, err = dialer.DialContext(ctx, "udp", addr)
+	}
 	if err != nil {
 		c.state.Store(int32(StateDisconnected))
 		return fmt.Errorf("snmp: connection failed: %w", err)
 	}
 
+	if c.opts.DontFragment && c.opts.UnixSocket == "" {
+		if err := setDontFragment(conn); err != nil {
+			conn.Close()
+			c.state.Store(int32(StateDisconnected))
+			return err
+		}
+	}
+
 	c.conn = conn
+	gen := c.generation.Add(1)
+	c.bulkUnsupported.Store(false)
 	c.state.Store(int32(StateConnected))
+	c.everConnected.Store(true)
 	c.metrics.ActiveConnections.Add(1)
 
 	// Reset channels
 	c.done = make(chan struct{})
+	c.lastSent.Store(time.Now().UnixNano())
 
 	// Start response reader
 	c.wg.Add(1)
-	go c.readLoop()
+	go c.readLoop(gen)
+
+	if c.opts.KeepaliveInterval > 0 {
+		c.wg.Add(1)
+		go c.keepalive(c.done)
+	}
 
 	// Call OnConnect callback
 	if c.opts.OnConnect != nil {
@@ -116,22 +259,34 @@ func (c *Client) Connect(ctx context.Context) error {
 	return nil
 }
 
-// Disconnect closes the connection.
+// Disconnect closes the connection. Disconnecting a client that is already
+// disconnected is a no-op; ErrNotConnected is only returned if the client
+// was never connected. An automatic reconnection in progress is cancelled,
+// and Disconnect waits for it to stop or for ctx to be done.
 func (c *Client) Disconnect(ctx context.Context) error {
+	if err := c.stopReconnect(ctx); err != nil {
+		return err
+	}
+
 	if !c.state.CompareAndSwap(int32(StateConnected), int32(StateDisconnecting)) {
+		if c.everConnected.Load() {
+			return nil
+		}
 		return ErrNotConnected
 	}
 
 	c.state.Store(int32(StateDisconnected))
 	c.metrics.ActiveConnections.Add(-1)
 
+	// Closing the connection unblocks the read loop
 	close(c.done)
-	c.wg.Wait()
-
 	if c.conn != nil {
 		c.conn.Close()
-		c.conn = nil
 	}
+	c.wg.Wait()
+
+	c.conn = nil
+	c.removeLocalSocket()
 
 	// Fail pending requests
 	c.failPending(ErrClientClosed)
@@ -140,7 +295,39 @@ func (c *Client) Disconnect(ctx context.Context) error {
 	return nil
 }
 
-func (c *Client) readLoop() {
+// dialUnix connects to the agent's Unix datagram socket. Unlike UDP, an
+// unbound unixgram socket cannot receive replies, so a temporary local
+// socket is bound and removed again on disconnect.
+func (c *Client) dialUnix() (net.Conn, error) {
+	local := filepath.Join(os.TempDir(), fmt.Sprintf("edgeo-snmp-%d-%d.sock", os.Getpid(), rand.Int63()))
+	conn, err := net.DialUnix("unixgram",
+		&net.UnixAddr{Name: local, Net: "unixgram"},
+		&net.UnixAddr{Name: c.opts.UnixSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	c.localSocket = local
+	return conn, nil
+}
+
+func (c *Client) removeLocalSocket() {
+	if c.localSocket != "" {
+		os.Remove(c.localSocket)
+		c.localSocket = ""
+	}
+}
+
+// Close disconnects the client. Unlike Disconnect it never reports that the
+// client was not connected, so it can be deferred right after NewClient.
+func (c *Client) Close() error {
+	if err := c.Disconnect(context.Background()); err != nil && !errors.Is(err, ErrNotConnected) {
+		return err
+	}
+	return nil
+}
+
+// readLoop reads responses from the connection of generation gen.
+func (c *Client) readLoop(gen uint64) {
 	defer c.wg.Done()
 
 	buf := make([]byte, 65535)
@@ -154,7 +341,7 @@ func (c *Client) readLoop() {
 		// Set read deadline
 		c.conn.SetReadDeadline(time.Now().Add(c.opts.Timeout * 2))
 
-		n, err := c.conn.Read(buf)
+		n, src, err := c.readFrom(buf)
 		if err != nil {
 			select {
 			case <-c.done:
@@ -163,34 +350,248 @@ func (c *Client) readLoop() {
 				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 					continue
 				}
+				// An ICMP port-unreachable for a previous datagram; the
+				// socket itself is still usable.
+				if errors.Is(err, syscall.ECONNREFUSED) {
+					c.failPending(ErrConnectionRefused)
+					continue
+				}
 				c.handleConnectionLost(err)
 				return
 			}
 		}
 
+		c.dumpPacket(PacketReceived, buf[:n])
+
 		// Decode message
-		msg, err := DecodeMessage(buf[:n])
+		var (
+			msg *Message
+			v3  *v3Message
+		)
+		if c.opts.Version == Version3 {
+			msg, v3, err = c.decodeV3Response(buf[:n])
+		} else {
+			msg, err = DecodeMessage(buf[:n])
+			if err != nil && c.opts.LenientDecode {
+				if partial, truncated, perr := decodeTruncatedMessage(buf[:n]); perr == nil && truncated {
+					c.logger.Warn("recovered varbinds from truncated response",
+						"request_id", partial.PDU.RequestID,
+						"varbinds", len(partial.PDU.Variables),
+						"size", n)
+					msg, err = partial, nil
+				}
+			}
+		}
 		if err != nil {
 			c.logger.Warn("failed to decode response", "error", err)
 			c.metrics.Errors.Add(1)
 			continue
 		}
 
+		if c.opts.ResponseValidator != nil {
+			if err := c.opts.ResponseValidator(src, msg); err != nil {
+				c.logger.Warn("response rejected", "error", err, "source", src)
+				c.metrics.ResponsesRejected.Add(1)
+				continue
+			}
+		}
+
 		c.metrics.ResponsesReceived.Add(1)
 		c.metrics.VarbindsReceived.Add(int64(len(msg.PDU.Variables)))
 
-		// Find pending request
+		if c.opts.DecodeOpaqueSpecial {
+			for i := range msg.PDU.Variables {
+				v := &msg.PDU.Variables[i]
+				if v.Type != TypeOpaque {
+					continue
+				}
+				if n, ok := decodeOpaqueCounter64(v.AsBytes()); ok {
+					v.Type = TypeCounter64
+					v.Value = n
+				}
+			}
+		}
+
+		if !c.opts.KeepRawValues {
+			for i := range msg.PDU.Variables {
+				msg.PDU.Variables[i].RawValue = nil
+			}
+		}
+
+		// Find pending request. SNMPv3 requests are sent with the
+		// request ID as msgID, which reports carry even when they
+		// cannot echo the request ID.
+		id := msg.PDU.RequestID
+		if v3 != nil {
+			id = v3.MsgID
+		}
 		c.pendingLock.RLock()
-		ch, ok := c.pending[msg.PDU.RequestID]
+		req, ok := c.pending[id]
 		c.pendingLock.RUnlock()
 
+		if ok && req.gen.Load() != gen {
+			// A late response to a request sent before a reconnect; its
+			// request ID may since have been reused.
+			c.mismatches.Add(1)
+			c.metrics.ResponseMismatches.Add(1)
+			c.logger.Debug("dropping response from a previous connection",
+				"request_id", id)
+			continue
+		}
+
 		if ok {
 			select {
-			case ch <- msg.PDU:
+			case req.ch <- response{pdu: msg.PDU, size: n, v3: v3}:
 			default:
 			}
+		} else {
+			c.mismatches.Add(1)
+			c.metrics.ResponseMismatches.Add(1)
+			if c.logger.Enabled(context.Background(), slog.LevelDebug) {
+				c.logger.Debug("response does not match any pending request",
+					"request_id", id,
+					"pending", c.pendingIDs())
+			}
+		}
+	}
+}
+
+// keepalive sends a GET whenever nothing has been sent for the keepalive
+// interval, until done is closed.
+func (c *Client) keepalive(done chan struct{}) {
+	defer c.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	oid := c.opts.KeepaliveOID
+	if len(oid) == 0 {
+		oid = OIDSysUpTime
+	}
+
+	interval := c.opts.KeepaliveInterval
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+		}
+
+		idle := time.Since(time.Unix(0, c.lastSent.Load()))
+		if idle < interval {
+			timer.Reset(interval - idle)
+			continue
+		}
+
+		c.logger.Debug("sending keepalive", "oid", oid, "idle", idle)
+		if _, err := c.sendRequest(ctx, NewGetRequest(c.nextRequestID(), oid)); err != nil && ctx.Err() == nil {
+			c.logger.Debug("keepalive failed", "error", err)
+		}
+		timer.Reset(interval)
+	}
+}
+
+// readFrom reads a datagram and the address it came from, falling back to
+// the connected peer for sockets that do not report a sender.
+func (c *Client) readFrom(buf []byte) (int, net.Addr, error) {
+	if pc, ok := c.conn.(net.PacketConn); ok {
+		n, src, err := pc.ReadFrom(buf)
+		if err == nil && src == nil {
+			src = c.conn.RemoteAddr()
+		}
+		return n, src, err
+	}
+	n, err := c.conn.Read(buf)
+	return n, c.conn.RemoteAddr(), err
+}
+
+// parseOIDStrings parses dotted OIDs, reporting all malformed ones.
+func parseOIDStrings(oids []string) ([]OID, error) {
+	parsed := make([]OID, 0, len(oids))
+	var errs []error
+	for _, s := range oids {
+		oid, err := ParseOID(s)
+		if err != nil {
+			errs = append(errs, invalidOIDError(s, err))
+			continue
+		}
+		parsed = append(parsed, oid)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return parsed, nil
+}
+
+// invalidOIDError reports that s failed to parse as an OID.
+func invalidOIDError(s string, err error) error {
+	if errors.Is(err, ErrInvalidOID) {
+		return fmt.Errorf("%w %q", ErrInvalidOID, s)
+	}
+	return fmt.Errorf("%w %q: %v", ErrInvalidOID, s, err)
+}
+
+// withRequestValueType returns pdu with the placeholder values of a read
+// request encoded as the configured RequestValueType instead of NULL.
+func (c *Client) withRequestValueType(pdu *PDU) *PDU {
+	t := c.opts.RequestValueType
+	if t == 0 || t == TypeNull {
+		return pdu
+	}
+	switch pdu.Type {
+	case PDUGetRequest, PDUGetNextRequest, PDUGetBulkRequest:
+	default:
+		return pdu
+	}
+
+	out := *pdu
+	out.Variables = make([]Variable, len(pdu.Variables))
+	for i, v := range pdu.Variables {
+		if v.Type == TypeNull {
+			v.Type, v.Value = t, []byte{}
 		}
+		out.Variables[i] = v
+	}
+	return &out
+}
+
+// logVarbinds logs the encoded variable bindings of an outgoing PDU at
+// debug level, to diagnose agents that reject a particular encoding.
+func (c *Client) logVarbinds(pdu *PDU) {
+	if !c.logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	data, err := encodeVariableBindings(pdu.Variables)
+	if err != nil {
+		return
 	}
+	c.logger.Debug("encoded variable bindings",
+		"request_id", pdu.RequestID,
+		"pdu_type", pdu.Type,
+		"bytes", fmt.Sprintf("% x", data))
+}
+
+// pendingIDs returns the request IDs awaiting a response, in order.
+func (c *Client) pendingIDs() []int32 {
+	c.pendingLock.RLock()
+	defer c.pendingLock.RUnlock()
+
+	ids := make([]int32, 0, len(c.pending))
+	for id := range c.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
 }
 
 func (c *Client) handleConnectionLost(err error) {
@@ -205,6 +606,7 @@ func (c *Client) handleConnectionLost(err error) {
 		c.conn.Close()
 		c.conn = nil
 	}
+	c.removeLocalSocket()
 
 	c.logger.Info("connection lost", "error", err)
 
@@ -212,23 +614,56 @@ func (c *Client) handleConnectionLost(err error) {
 		go c.opts.OnConnectionLost(c, err)
 	}
 
-	c.failPending(err)
+	c.failPending(fmt.Errorf("%w: %v", ErrConnectionLost, err))
 
 	if c.opts.AutoReconnect {
-		go c.reconnect()
+		ctx, cancel := context.WithCancel(context.Background())
+		c.mu.Lock()
+		ch := make(chan struct{})
+		c.reconnected, c.cancelReconnect = ch, cancel
+		c.mu.Unlock()
+		go c.reconnect(ctx, ch)
 	}
 }
 
+// CancelPending aborts every request waiting for a response, which then
+// fails with ErrRequestCanceled. The connection stays open and requests
+// issued afterwards proceed normally; a late response to a cancelled
+// request is dropped like any other unmatched response.
+func (c *Client) CancelPending() {
+	c.failPending(ErrRequestCanceled)
+}
+
+// failPending fails and forgets all pending requests. A request's own
+// deferred removal from c.pending is then a no-op, and request IDs are not
+// reused, so it cannot remove a newer request.
 func (c *Client) failPending(err error) {
 	c.pendingLock.Lock()
-	for id, ch := range c.pending {
-		close(ch)
+	for id, req := range c.pending {
+		select {
+		case req.ch <- response{err: err}:
+		default:
+		}
 		delete(c.pending, id)
 	}
 	c.pendingLock.Unlock()
 }
 
-func (c *Client) reconnect() {
+// reconnect connects again after the connection was lost, until it
+// succeeds, gives up or ctx is cancelled, and closes ch when it is done.
+// By then a newer reconnection may already have replaced ch in
+// c.reconnected, so the field is only cleared if it still holds ch.
+func (c *Client) reconnect(ctx context.Context, ch chan struct{}) {
+	defer func() {
+		c.mu.Lock()
+		close(ch)
+		if c.reconnected == ch {
+			c.cancelReconnect()
+			c.reconnected, c.cancelReconnect = nil, nil
+		}
+		c.mu.Unlock()
+	}()
+
 	backoff := c.opts.ConnectRetryInterval
 	retries := 0
 
@@ -237,10 +672,13 @@ func (c *Client) reconnect() {
 			c.opts.OnReconnecting(c, c.opts)
 		}
 
+		if ctx.Err() != nil {
+			return
+		}
 		c.metrics.ReconnectAttempts.Add(1)
 
-		ctx, cancel := context.WithTimeout(context.Background(), c.opts.Timeout)
-		err := c.Connect(ctx)
+		connectCtx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+		err := c.Connect(connectCtx)
 		cancel()
 
 		if err == nil {
@@ -255,7 +693,13 @@ func (c *Client) reconnect() {
 			return
 		}
 
-		time.Sleep(backoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
 
 		// Exponential backoff with jitter
 		backoff = time.Duration(float64(backoff) * (1.5 + rand.Float64()*0.5))
@@ -265,6 +709,75 @@ func (c *Client) reconnect() {
 	}
 }
 
+// stopReconnect cancels an in-progress automatic reconnection and waits
+// until it has stopped, so that it cannot connect the client again after
+// Disconnect.
+func (c *Client) stopReconnect(ctx context.Context) error {
+	c.mu.RLock()
+	ch, cancel := c.reconnected, c.cancelReconnect
+	c.mu.RUnlock()
+
+	if ch == nil {
+		return nil
+	}
+	cancel()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitReconnect blocks until an in-progress reconnection finishes. It
+// returns ErrNotConnected immediately when FailFast is set or when no
+// reconnection is pending.
+func (c *Client) waitReconnect(ctx context.Context) error {
+	if c.opts.FailFast {
+		return ErrNotConnected
+	}
+
+	c.mu.RLock()
+	ch := c.reconnected
+	c.mu.RUnlock()
+
+	if ch == nil {
+		return ErrNotConnected
+	}
+
+	select {
+	case <-ch:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if c.State() != StateConnected {
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// dumpPacket passes a copy of a raw packet to the packet dump callback.
+func (c *Client) dumpPacket(dir PacketDirection, data []byte) {
+	if c.opts.OnPacket == nil {
+		return
+	}
+
+	var dump []byte
+	if c.opts.RedactSecrets {
+		dump = redactCommunity(data)
+		if dump == nil {
+			return
+		}
+	} else {
+		dump = make([]byte, len(data))
+		copy(dump, data)
+	}
+
+	c.opts.OnPacket(dir, dump)
+}
+
 func (c *Client) nextRequestID() int32 {
 	c.requestIDLock.Lock()
 	defer c.requestIDLock.Unlock()
@@ -277,14 +790,50 @@ func (c *Client) nextRequestID() int32 {
 }
 
 func (c *Client) sendRequest(ctx context.Context, pdu *PDU) (*PDU, error) {
+	resp, err := c.exchange(ctx, pdu, c.opts.Retries, 1)
+	for i := 0; i < c.opts.RetryOnGenErr && hasErrorStatus(err, GenErr); i++ {
+		c.metrics.GenErrRetries.Add(1)
+		c.logger.Debug("retrying request after genErr", "retry", i+1, "request_id", pdu.RequestID)
+
+		// A fresh request ID keeps a late reply to the failed attempt
+		// from being taken for the answer to this one.
+		pdu.RequestID = c.nextRequestID()
+		resp, err = c.exchange(ctx, pdu, c.opts.Retries, 1)
+	}
+	return resp, err
+}
+
+// exchange sends pdu and waits for the matching response, retransmitting
+// the same message up to retries times. The wait for each attempt starts at
+// the client timeout and is multiplied by backoff after every attempt.
+func (c *Client) exchange(ctx context.Context, pdu *PDU, retries int, backoff float64) (*PDU, error) {
+	raw := isRawRequest(ctx)
+	if !raw {
+		if err := validatePDU(c.opts.Version, pdu); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.opts.validateSecurity(); err != nil {
+		return nil, err
+	}
+
 	if c.State() != StateConnected {
-		return nil, ErrNotConnected
+		if err := c.waitReconnect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.opts.Version == Version3 && !isDiscovery(ctx) {
+		if err := c.ensureEngine(ctx); err != nil {
+			return nil, err
+		}
 	}
 
 	// Create response channel
-	respCh := make(chan *PDU, 1)
+	respCh := make(chan response, 1)
+	req := &pendingRequest{ch: respCh}
 	c.pendingLock.Lock()
-	c.pending[pdu.RequestID] = respCh
+	c.pending[pdu.RequestID] = req
 	c.pendingLock.Unlock()
 
 	defer func() {
@@ -294,45 +843,97 @@ func (c *Client) sendRequest(ctx context.Context, pdu *PDU) (*PDU, error) {
 	}()
 
 	// Encode message
-	msg := &Message{
-		Version:   c.opts.Version,
-		Community: c.opts.Community,
-		PDU:       pdu,
+	out := pdu
+	if !raw {
+		out = c.withRequestValueType(pdu)
 	}
-
-	data, err := msg.Encode()
+	data, err := c.encodeMessage(ctx, out)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encode message: %w", err)
+		return nil, err
 	}
+	c.logVarbinds(out)
+
+	stats := requestStatsFromContext(ctx)
 
 	// Send with retries
 	var lastErr error
-	for retry := 0; retry <= c.opts.Retries; retry++ {
+	mismatches := c.mismatches.Load()
+	wait := c.opts.Timeout
+	for retry := 0; retry <= retries; retry++ {
 		if retry > 0 {
 			c.metrics.Retries.Add(1)
-			c.logger.Debug("retrying request", "retry", retry, "request_id", pdu.RequestID)
+			wait = time.Duration(float64(wait) * backoff)
+			c.logger.Debug("retrying request", "retry", retry, "request_id", pdu.RequestID, "timeout", wait)
 		}
 
 		start := time.Now()
 
 		// Set write deadline
+		req.gen.Store(c.generation.Load())
 		c.conn.SetWriteDeadline(time.Now().Add(c.opts.Timeout))
+		c.dumpPacket(PacketSent, data)
 		_, err := c.conn.Write(data)
 		if err != nil {
+			// Nobody is listening on the agent port; retrying won't help.
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				return nil, ErrConnectionRefused
+			}
+			// Larger than the path MTU with don't-fragment set.
+			if errors.Is(err, syscall.EMSGSIZE) {
+				return nil, fmt.Errorf("%w: %v", ErrPacketTooLarge, err)
+			}
 			lastErr = fmt.Errorf("write failed: %w", err)
 			continue
 		}
 
+		c.lastSent.Store(time.Now().UnixNano())
 		c.metrics.RequestsSent.Add(1)
 		c.metrics.VarbindsSent.Add(int64(len(pdu.Variables)))
+		if stats != nil {
+			if stats.SentAt.IsZero() {
+				stats.SentAt = start
+			}
+			if retry > 0 {
+				stats.Retries++
+			}
+			stats.BytesSent += len(data)
+		}
 
 		// Wait for response
 		select {
-		case resp, ok := <-respCh:
-			if !ok {
-				return nil, ErrClientClosed
+		case r := <-respCh:
+			if r.err != nil {
+				return nil, r.err
+			}
+			resp := r.pdu
+			rtt := time.Since(start)
+			c.metrics.RequestLatency.ObserveDuration(rtt)
+			if stats != nil {
+				stats.RTT = rtt
+				stats.BytesReceived += r.size
+			}
+
+			if r.v3 != nil {
+				c.observeEngine(r.v3)
+				if resp.Type == PDUReport && c.learnEngine(r.v3, resp) {
+					if isDiscovery(ctx) {
+						return resp, nil
+					}
+					// Up to twice: a new engine ID, then its time.
+					if n := engineResyncs(ctx); n < 2 {
+						c.logger.Debug("resending request with updated engine",
+							"request_id", pdu.RequestID, "report", resp.Variables[0].OID)
+						ctx = context.WithValue(ctx, engineResyncKey{}, n+1)
+						return c.exchange(ctx, pdu, retries, backoff)
+					}
+				}
+			}
+
+			if resp.Type == PDUReport {
+				if err := c.reportError(resp); err != nil {
+					return nil, err
+				}
 			}
-			c.metrics.RequestLatency.ObserveDuration(time.Since(start))
 
 			// Check for errors
 			if resp.ErrorStatus != NoError {
@@ -345,7 +946,7 @@ func (c *Client) sendRequest(ctx context.Context, pdu *PDU) (*PDU, error) {
 
 			return resp, nil
 
-		case <-time.After(c.opts.Timeout):
+		case <-time.After(wait):
 			lastErr = ErrTimeout
 			c.metrics.Timeouts.Add(1)
 
@@ -354,9 +955,214 @@ func (c *Client) sendRequest(ctx context.Context, pdu *PDU) (*PDU, error) {
 		}
 	}
 
+	// Responses that matched no pending request most likely answered this
+	// one with a mangled request ID; say so instead of a bare timeout.
+	if lastErr == ErrTimeout && c.mismatches.Load() > mismatches {
+		return nil, fmt.Errorf("%w: %w: received responses with unexpected request IDs",
+			ErrTimeout, ErrRequestIDMismatch)
+	}
+
 	return nil, lastErr
 }
 
+// encodeMessage encodes pdu in a message of the client version, with the
+// community of the request or, for SNMPv3, secured for the client user.
+func (c *Client) encodeMessage(ctx context.Context, pdu *PDU) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if c.opts.Version == Version3 {
+		data, err = c.encodeV3(ctx, pdu)
+	} else {
+		community, cerr := c.community(ctx, pdu.Type)
+		if cerr != nil {
+			return nil, cerr
+		}
+		msg := &Message{
+			Version:   c.opts.Version,
+			Community: community,
+			PDU:       pdu,
+		}
+		data, err = msg.Encode()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+	return data, nil
+}
+
+// community returns the community string for a PDU, honoring a
+// per-request override carried by ctx and NotifyCommunity for traps and
+// informs.
+func (c *Client) community(ctx context.Context, pduType PDUType) (string, error) {
+	ro := requestOptionsFromContext(ctx)
+	if ro != nil && ro.contextNameSet && c.opts.Version != Version3 && !c.opts.ContextCommunitySuffix {
+		return "", fmt.Errorf("%w: contexts require SNMPv3 or WithContextCommunitySuffix", ErrInvalidVersion)
+	}
+
+	if ro != nil && ro.communitySet {
+		community, err := normalizeCommunity("request community", ro.Community, c.opts.LenientCommunity)
+		if err != nil && c.opts.Version != Version3 {
+			if !c.opts.LenientCommunity {
+				return "", err
+			}
+			c.logger.Warn("using invalid community string", "error", err)
+		}
+		return c.withContextSuffix(ctx, community), nil
+	}
+	switch pduType {
+	case PDUTrapV1, PDUTrapV2, PDUInformRequest:
+		if c.opts.NotifyCommunity != "" {
+			return c.opts.NotifyCommunity, nil
+		}
+		return c.opts.Community, nil
+	}
+	return c.withContextSuffix(ctx, c.opts.Community), nil
+}
+
+// withContextSuffix appends "@context" to community when the client uses
+// the community@context convention and a context is in effect.
+func (c *Client) withContextSuffix(ctx context.Context, community string) string {
+	if !c.opts.ContextCommunitySuffix || c.opts.Version == Version3 {
+		return community
+	}
+	if name := c.contextName(ctx); name != "" {
+		return community + "@" + name
+	}
+	return community
+}
+
+// contextName returns the context name for a request, honoring a
+// per-request override carried by ctx.
+func (c *Client) contextName(ctx context.Context) string {
+	if ro := requestOptionsFromContext(ctx); ro != nil && ro.contextNameSet {
+		return ro.ContextName
+	}
+	return c.opts.ContextName
+}
+
+// SendRaw sends a pre-built PDU and returns the response with the same
+// request ID. It is meant for protocol testing, such as sending unusual
+// request IDs or error fields to an agent.
+//
+// The PDU is encoded as-is, with the client's version and community, and
+// is neither validated against the SNMP version nor retried with a new
+// request ID on genErr. The caller owns its correctness: in particular,
+// the request ID must not collide with another request in flight, and the
+// PDU must be of a type the agent answers, or SendRaw waits until the
+// retries are exhausted. Like other requests, a response with an error
+// status is returned together with an *SNMPError.
+func (c *Client) SendRaw(ctx context.Context, pdu *PDU) (*PDU, error) {
+	if pdu == nil {
+		return nil, fmt.Errorf("%w: nil PDU", ErrInvalidPDU)
+	}
+	ctx = context.WithValue(ctx, rawRequestKey{}, true)
+	return c.exchange(ctx, pdu, c.opts.Retries, 1)
+}
+
+// SendTrap sends an SNMPv2c trap to the target, which is normally a trap
+// receiver on port 162. Traps are not acknowledged; use Inform when
+// delivery must be confirmed. An SNMPv3 trap is sent from the local engine
+// and requires WithLocalEngineID.
+func (c *Client) SendTrap(ctx context.Context, sysUpTime uint32, trapOID OID, variables ...Variable) error {
+	pdu := NewTrapV2(c.nextRequestID(), sysUpTime, trapOID, variables...)
+	if err := validatePDU(c.opts.Version, pdu); err != nil {
+		return err
+	}
+
+	if c.opts.Version == Version3 {
+		data, err := c.encodeV3Trap(ctx, pdu)
+		if err != nil {
+			return err
+		}
+		return c.sendUnconfirmed(ctx, data)
+	}
+
+	community, err := c.community(ctx, pdu.Type)
+	if err != nil {
+		return err
+	}
+
+	msg := &Message{
+		Version:   c.opts.Version,
+		Community: community,
+		PDU:       pdu,
+	}
+	data, err := msg.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	return c.sendUnconfirmed(ctx, data)
+}
+
+// SendTrapV1 sends an SNMPv1 trap to the target. The client must be
+// configured for SNMPv1.
+func (c *Client) SendTrapV1(ctx context.Context, trap *TrapV1PDU) error {
+	if c.opts.Version != Version1 {
+		return fmt.Errorf("%w: %s not supported in %s", ErrInvalidPDU, PDUTrapV1, c.opts.Version)
+	}
+
+	community, err := c.community(ctx, PDUTrapV1)
+	if err != nil {
+		return err
+	}
+
+	msg := &TrapV1Message{
+		Version:   Version1,
+		Community: community,
+		PDU:       trap,
+	}
+	data, err := msg.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	return c.sendUnconfirmed(ctx, data)
+}
+
+// sendUnconfirmed writes a message that expects no response.
+func (c *Client) sendUnconfirmed(ctx context.Context, data []byte) error {
+	if c.State() != StateConnected {
+		if err := c.waitReconnect(ctx); err != nil {
+			return err
+		}
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.opts.Timeout))
+	c.dumpPacket(PacketSent, data)
+	if _, err := c.conn.Write(data); err != nil {
+		c.metrics.Errors.Add(1)
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return ErrConnectionRefused
+		}
+		if errors.Is(err, syscall.EMSGSIZE) {
+			return fmt.Errorf("%w: %v", ErrPacketTooLarge, err)
+		}
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	c.lastSent.Store(time.Now().UnixNano())
+	c.metrics.TrapsSent.Add(1)
+	return nil
+}
+
+// scopedPDU wraps pdu in the SNMPv3 context of the request. A configured
+// ContextEngineID is used verbatim; otherwise the context defaults to the
+// authoritative engine ID.
+func (c *Client) scopedPDU(ctx context.Context, pdu *PDU, authoritativeEngineID []byte) *ScopedPDU {
+	engineID := authoritativeEngineID
+	if c.opts.ContextEngineID != "" {
+		engineID = []byte(c.opts.ContextEngineID)
+	}
+	return &ScopedPDU{
+		ContextEngineID: engineID,
+		ContextName:     c.contextName(ctx),
+		PDU:             pdu,
+	}
+}
+
 // Get performs an SNMP GET request.
 func (c *Client) Get(ctx context.Context, oids ...OID) ([]Variable, error) {
 	c.metrics.GetRequests.Add(1)
@@ -368,7 +1174,133 @@ func (c *Client) Get(ctx context.Context, oids ...OID) ([]Variable, error) {
 		return nil, err
 	}
 
-	return resp.Variables, nil
+	vars := resp.Variables
+	if len(vars) < len(oids) {
+		if c.opts.StrictResponseCount {
+			c.metrics.Errors.Add(1)
+			return nil, fmt.Errorf("%w: %d varbinds in response to %d requested",
+				ErrMalformedPacket, len(vars), len(oids))
+		}
+		c.logger.Warn("short GET response, padding with noSuchInstance",
+			"requested", len(oids), "received", len(vars))
+		for _, oid := range oids[len(vars):] {
+			vars = append(vars, Variable{OID: oid.Copy(), Type: TypeNoSuchInstance})
+		}
+	}
+
+	return vars, nil
+}
+
+// GetInContext performs an SNMP GET request in the named context, such as
+// the context of one VRF on a multi-VRF router, without changing the
+// client context. SNMPv3 clients send the context name in the scoped PDU;
+// SNMPv1/v2c clients need WithContextCommunitySuffix and fail with
+// ErrInvalidVersion otherwise.
+func (c *Client) GetInContext(ctx context.Context, contextName string, oids ...OID) ([]Variable, error) {
+	return c.Get(WithRequestOptions(ctx, WithRequestContextName(contextName)), oids...)
+}
+
+// GetWithStats performs an SNMP GET request like Get and also returns
+// timing and size statistics for it.
+func (c *Client) GetWithStats(ctx context.Context, oids ...OID) ([]Variable, RequestStats, error) {
+	var stats RequestStats
+	vars, err := c.Get(context.WithValue(ctx, requestStatsKey{}, &stats), oids...)
+	return vars, stats, err
+}
+
+// GetStr performs an SNMP GET request for OIDs in dotted string form. If
+// any OID is malformed no request is sent, and the error names every bad
+// OID.
+func (c *Client) GetStr(ctx context.Context, oids ...string) ([]Variable, error) {
+	parsed, err := parseOIDStrings(oids)
+	if err != nil {
+		return nil, err
+	}
+	return c.Get(ctx, parsed...)
+}
+
+// GetMap performs an SNMP GET request and returns the variables keyed by
+// OID string, so callers don't depend on the agent returning varbinds in
+// request order.
+func (c *Client) GetMap(ctx context.Context, oids ...OID) (map[string]Variable, error) {
+	vars, err := c.Get(ctx, oids...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]Variable, len(vars))
+	for _, v := range vars {
+		result[v.OID.String()] = v
+	}
+	return result, nil
+}
+
+// GetPipelined performs one GET per OID, sending the requests back-to-back
+// without waiting for each response, with up to PipelineWindow requests in
+// flight. On a low-latency link this is much faster than issuing the GETs
+// one after another, and unlike a single multi-OID GET a missing object
+// doesn't fail the others under SNMPv1: an SNMPv1 noSuchName answer is
+// returned as a noSuchObject variable, as SNMPv2c reports it. Results are
+// returned in the order of oids. The first failing request cancels the
+// rest.
+func (c *Client) GetPipelined(ctx context.Context, oids ...OID) ([]Variable, error) {
+	window := c.opts.PipelineWindow
+	if window < 1 {
+		window = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]Variable, len(oids))
+	sem := make(chan struct{}, window)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+
+send:
+	for i, oid := range oids {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break send
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			vars, err := c.Get(ctx, oid)
+			if IsNoSuchName(err) {
+				results[i] = Variable{OID: oid, Type: TypeNoSuchObject}
+				return
+			}
+			if err == nil && len(vars) != 1 {
+				err = fmt.Errorf("%w: expected 1 variable, got %d", ErrInvalidPDU, len(vars))
+			}
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			results[i] = vars[0]
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
 }
 
 // GetNext performs an SNMP GET-NEXT request.
@@ -387,10 +1319,6 @@ func (c *Client) GetNext(ctx context.Context, oids ...OID) ([]Variable, error) {
 
 // GetBulk performs an SNMP GET-BULK request (v2c/v3 only).
 func (c *Client) GetBulk(ctx context.Context, nonRepeaters, maxRepetitions int, oids ...OID) ([]Variable, error) {
-	if c.opts.Version == Version1 {
-		return nil, fmt.Errorf("snmp: GetBulk not supported in SNMPv1")
-	}
-
 	c.metrics.GetBulkRequests.Add(1)
 
 	pdu := NewGetBulkRequest(c.nextRequestID(), nonRepeaters, maxRepetitions, oids...)
@@ -414,127 +1342,483 @@ func (c *Client) Set(ctx context.Context, variables ...Variable) ([]Variable, er
 		return nil, err
 	}
 
+	if c.opts.VerifySet {
+		if err := c.verifySet(ctx, variables); err != nil {
+			return nil, err
+		}
+	}
+
 	return resp.Variables, nil
 }
 
-// Walk performs an SNMP walk starting from the given OID.
-func (c *Client) Walk(ctx context.Context, rootOID OID) ([]Variable, error) {
-	c.metrics.WalkRequests.Add(1)
+// verifySet reads back the variables of a successful SET and returns a
+// VerifyError for those that do not hold the value set.
+func (c *Client) verifySet(ctx context.Context, variables []Variable) error {
+	oids := make([]OID, len(variables))
+	for i, v := range variables {
+		oids[i] = v.OID
+	}
 
-	var results []Variable
-	currentOID := rootOID.Copy()
+	current, err := c.Get(ctx, oids...)
+	if err != nil {
+		return fmt.Errorf("snmp: set verification failed: %w", err)
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return results, ctx.Err()
-		default:
+	var mismatches []VerifyMismatch
+	for i := range variables {
+		if i >= len(current) || !sameValue(&current[i], &variables[i]) {
+			actual := Variable{OID: variables[i].OID, Type: TypeNoSuchInstance}
+			if i < len(current) {
+				actual = current[i]
+			}
+			mismatches = append(mismatches, VerifyMismatch{Expected: variables[i], Actual: actual})
 		}
+	}
+	if len(mismatches) > 0 {
+		return &VerifyError{Mismatches: mismatches}
+	}
+	return nil
+}
 
-		var vars []Variable
-		var err error
+// Inform sends an SNMPv2 InformRequest and waits for the manager to
+// acknowledge it. Unacknowledged informs are retransmitted with the same
+// request ID up to InformRetries times, with the wait between attempts
+// growing by InformBackoff, independently of the polling retry settings.
+func (c *Client) Inform(ctx context.Context, sysUpTime uint32, trapOID OID, variables ...Variable) error {
+	c.metrics.InformRequests.Add(1)
 
-		if c.opts.Version == Version1 {
-			vars, err = c.GetNext(ctx, currentOID)
-		} else {
-			vars, err = c.GetBulk(ctx, c.opts.NonRepeaters, c.opts.MaxRepetitions, currentOID)
-		}
+	pdu := NewInformRequest(c.nextRequestID(), sysUpTime, trapOID, variables...)
+	_, err := c.exchange(ctx, pdu, c.opts.InformRetries, c.opts.InformBackoff)
+	if err != nil {
+		c.metrics.Errors.Add(1)
+		return err
+	}
 
-		if err != nil {
-			// Check if it's an expected end condition
-			if IsEndOfMIB(err) || IsNoSuchObject(err) || IsNoSuchInstance(err) {
-				break
-			}
-			c.metrics.Errors.Add(1)
-			return results, err
-		}
+	return nil
+}
 
-		if len(vars) == 0 {
-			break
+// SetIf performs an SNMP SET only if the guard OID currently holds the
+// expected type and value; otherwise it returns ErrGuardMismatch. The check
+// and the SET are separate requests, so this is not atomic on the wire, but
+// it prevents clobbering a concurrent change in the common case.
+func (c *Client) SetIf(ctx context.Context, guard OID, expected Variable, variables ...Variable) ([]Variable, error) {
+	vars, err := c.Get(ctx, guard)
+	if err != nil {
+		if IsNoSuchName(err) {
+			return nil, fmt.Errorf("%w: %s does not exist", ErrGuardMismatch, guard)
 		}
+		return nil, err
+	}
+	if len(vars) == 0 {
+		return nil, ErrNoResponse
+	}
 
-		for _, v := range vars {
-			// Check if we're still under the root OID
-			if !v.OID.HasPrefix(rootOID) {
-				return results, nil
-			}
+	current := vars[0]
+	if current.Type.IsException() {
+		return nil, fmt.Errorf("%w: %s does not exist", ErrGuardMismatch, guard)
+	}
 
-			// Check for end-of-mib markers
-			if v.Type == TypeEndOfMibView || v.Type == TypeNoSuchObject || v.Type == TypeNoSuchInstance {
-				return results, nil
-			}
+	if !sameValue(&current, &expected) {
+		return nil, fmt.Errorf("%w: %s is %s: %v", ErrGuardMismatch, guard, current.Type, current.Value)
+	}
 
-			results = append(results, v)
-			currentOID = v.OID
-		}
+	return c.Set(ctx, variables...)
+}
 
-		// For v1, we only get one result per request
-		if c.opts.Version == Version1 && len(vars) > 0 {
-			currentOID = vars[0].OID
-		} else if len(vars) > 0 {
-			currentOID = vars[len(vars)-1].OID
-		}
+// sameValue reports whether two variables have the same type and value,
+// regardless of the Go type used to hold the value.
+func sameValue(a, b *Variable) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	if a.Type == TypeNull || a.Type.IsException() {
+		return true
 	}
 
-	return results, nil
+	switch a.Type {
+	case TypeInteger:
+		x, okA := a.AsInt()
+		y, okB := b.AsInt()
+		return okA && okB && x == y
+	case TypeCounter32, TypeGauge32, TypeTimeTicks, TypeUInteger32, TypeCounter64:
+		x, okA := a.AsUint()
+		y, okB := b.AsUint()
+		return okA && okB && x == y
+	case TypeObjectIdentifier:
+		x, okA := a.Value.(OID)
+		y, okB := b.Value.(OID)
+		return okA && okB && x.Equal(y)
+	case TypeIPAddress:
+		x := toIP(a.Value)
+		y := toIP(b.Value)
+		return x != nil && x.Equal(y)
+	default:
+		return bytes.Equal(a.AsBytes(), b.AsBytes())
+	}
+}
+
+func toIP(value interface{}) net.IP {
+	switch val := value.(type) {
+	case net.IP:
+		return val
+	case []byte:
+		return net.IP(val)
+	case string:
+		return net.ParseIP(val)
+	default:
+		return nil
+	}
+}
+
+// Walk performs an SNMP walk starting from the given OID.
+func (c *Client) Walk(ctx context.Context, rootOID OID) ([]Variable, error) {
+	var results []Variable
+	err := c.WalkFunc(ctx, rootOID, func(v Variable) error {
+		results = append(results, v)
+		return nil
+	})
+	return results, err
+}
+
+// WalkStr performs an SNMP walk from an OID in dotted string form.
+func (c *Client) WalkStr(ctx context.Context, rootOID string) ([]Variable, error) {
+	oid, err := ParseOID(rootOID)
+	if err != nil {
+		return nil, invalidOIDError(rootOID, err)
+	}
+	return c.Walk(ctx, oid)
+}
+
+// WalkOrdered walks the subtree under rootOID like Walk and returns the
+// variables in tree order, indexed for lookup by OID.
+func (c *Client) WalkOrdered(ctx context.Context, rootOID OID) (*OrderedVarbinds, error) {
+	results := &OrderedVarbinds{}
+	err := c.WalkFunc(ctx, rootOID, func(v Variable) error {
+		results.Add(v)
+		return nil
+	})
+	return results, err
+}
+
+// WalkWithStats performs an SNMP walk like Walk and also reports what it
+// cost on the wire: the requests and retransmissions sent, the varbinds
+// received, whether it fell back from GETBULK to GETNEXT and the
+// max-repetitions bulk tuning settled on.
+func (c *Client) WalkWithStats(ctx context.Context, rootOID OID) ([]Variable, WalkStats, error) {
+	var stats WalkStats
+	vars, err := c.Walk(context.WithValue(ctx, walkStatsKey{}, &stats), rootOID)
+	return vars, stats, err
 }
 
 // WalkFunc walks the MIB tree and calls fn for each variable.
+//
+// fn is only called for variables under rootOID. A GETBULK response that
+// runs past the end of the subtree ends the walk at the first varbind
+// outside it; the varbinds before it are delivered and the rest dropped.
+//
+// An agent that echoes the previous OID back unchanged has nothing more to
+// return, so that ends the walk cleanly. Otherwise the agent must return
+// OIDs in strictly increasing order; a non-increasing OID aborts the walk
+// with ErrOIDNotIncreasing. With LenientWalk enabled the offending varbind
+// is skipped instead, counted in Metrics.WalkAnomalies and
+// WalkStats.Anomalies, and the walk continues from the highest OID seen so
+// far. It still fails after MaxWalkRegressions consecutive anomalies.
+//
+// The MaxWalkOIDs and MaxWalkDuration request options bound the walk; it
+// then stops with ErrWalkLimitExceeded.
+//
+// Under SNMPv2c and v3 the walk uses GETBULK. Some agents claim v2c but
+// fail GETBULK with genErr or never answer it; if the first GETBULK of a
+// walk fails that way the walk continues with GETNEXT, and once GETNEXT
+// works the client keeps using it for the rest of the connection.
 func (c *Client) WalkFunc(ctx context.Context, rootOID OID, fn func(Variable) error) error {
+	_, err := c.WalkFrom(ctx, rootOID, nil, fn)
+	return err
+}
+
+// WalkMultiStream walks the subtrees under roots and calls fn for each
+// variable in global OID order, as the variables are retrieved, giving a
+// single sorted dump of several subtrees. A root nested in another is
+// walked once as part of the outer one.
+//
+// Subtrees whose roots are not nested do not overlap, so walking them one
+// after the other in root order already yields sorted output; no buffering
+// across roots is needed.
+func (c *Client) WalkMultiStream(ctx context.Context, roots []OID, fn func(Variable) error) error {
+	sorted := make([]OID, len(roots))
+	copy(sorted, roots)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Compare(sorted[j]) < 0
+	})
+
+	var prev OID
+	for _, root := range sorted {
+		if prev != nil && root.HasPrefix(prev) {
+			continue
+		}
+		if err := c.WalkFunc(ctx, root, fn); err != nil {
+			return err
+		}
+		prev = root
+	}
+	return nil
+}
+
+// WalkFrom walks the subtree under rootOID like WalkFunc, starting after
+// start instead of at the beginning of the subtree. It returns the last OID
+// passed to fn without error (or start if there was none), so an
+// interrupted walk can be checkpointed and later resumed by passing that
+// OID as start. A nil start walks the whole subtree.
+func (c *Client) WalkFrom(ctx context.Context, rootOID, start OID, fn func(Variable) error) (OID, error) {
+	return c.walk(ctx, rootOID, start, nil, fn)
+}
+
+// WalkRange walks the variables from start up to, but not including, end,
+// such as a slice of table rows, regardless of subtree boundaries. start
+// itself is fetched with a GET and included if it exists.
+//
+// The walk stops at the first OID at or beyond end. A GETBULK response may
+// still carry a few variables past end; they are discarded.
+func (c *Client) WalkRange(ctx context.Context, start, end OID, fn func(Variable) error) error {
+	if start.Compare(end) >= 0 {
+		return fmt.Errorf("%w: range start %s is not before end %s", ErrInvalidOID, start, end)
+	}
+
+	vars, err := c.Get(ctx, start)
+	switch {
+	case err == nil:
+		if !vars[0].Type.IsException() {
+			if err := fn(vars[0]); err != nil {
+				return err
+			}
+		}
+	case IsNoSuchName(err) || IsNoSuchObject(err) || IsNoSuchInstance(err):
+	default:
+		return err
+	}
+
+	_, err = c.walk(ctx, OID{}, start, end, fn)
+	return err
+}
+
+// walk implements WalkFrom, additionally stopping before end if it is
+// not nil.
+func (c *Client) walk(ctx context.Context, rootOID, start, end OID, fn func(Variable) error) (OID, error) {
 	c.metrics.WalkRequests.Add(1)
 
-	currentOID := rootOID.Copy()
+	nonRepeaters, maxRepetitions := c.opts.NonRepeaters, c.opts.MaxRepetitions
+	var maxOIDs int
+	var maxDuration time.Duration
+	if ro := requestOptionsFromContext(ctx); ro != nil {
+		if ro.nonRepeatersSet {
+			nonRepeaters = ro.NonRepeaters
+		}
+		if ro.maxRepetitionsSet {
+			maxRepetitions = ro.MaxRepetitions
+		}
+		maxOIDs, maxDuration = ro.MaxWalkOIDs, ro.MaxWalkDuration
+	}
+
+	// limitErr reports err as a walk limit when our own deadline, rather
+	// than the caller's context, cut the walk short.
+	parent := ctx
+	limitErr := func(err error) error { return err }
+	if maxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, maxDuration)
+		defer cancel()
+		limitErr = func(err error) error {
+			if ctx.Err() != nil && parent.Err() == nil {
+				return fmt.Errorf("%w: ran for %s", ErrWalkLimitExceeded, maxDuration)
+			}
+			return err
+		}
+	}
+	delivered := 0
+
+	if start == nil {
+		start = rootOID
+	} else if !start.HasPrefix(rootOID) {
+		return start, fmt.Errorf("%w: %s is not within %s", ErrInvalidOID, start, rootOID)
+	}
+
+	lastOID := start.Copy()
+	regressions := 0
+	tuner := newBulkTuner(maxRepetitions, c.MaxMessageSize)
+	stats := walkStatsFromContext(ctx)
+	useBulk := c.opts.Version != Version1 && !c.bulkUnsupported.Load()
+	fellBack := false
+	bulkRequests := 0
+	if stats != nil && c.opts.Version != Version1 && !useBulk {
+		stats.BulkFallback = true
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return lastOID, limitErr(ctx.Err())
 		default:
 		}
 
 		var vars []Variable
 		var err error
 
-		if c.opts.Version == Version1 {
-			vars, err = c.GetNext(ctx, currentOID)
+		reqCtx := ctx
+		var reqStats RequestStats
+		if stats != nil {
+			stats.Requests++
+			reqCtx = context.WithValue(ctx, requestStatsKey{}, &reqStats)
+		}
+
+		if !useBulk {
+			vars, err = c.GetNext(reqCtx, lastOID)
+			stats.record(&reqStats, vars)
+			if err == nil && fellBack {
+				c.bulkUnsupported.Store(true)
+			}
 		} else {
-			vars, err = c.GetBulk(ctx, c.opts.NonRepeaters, c.opts.MaxRepetitions, currentOID)
+			bulkRequests++
+			if stats != nil {
+				stats.MaxRepetitions = tuner.repetitions
+			}
+			vars, err = c.GetBulk(reqCtx, nonRepeaters, tuner.repetitions, lastOID)
+			stats.record(&reqStats, vars)
+			if err == nil {
+				tuner.observe(vars)
+			} else if IsTooBig(err) && tuner.shrink() {
+				c.logger.Debug("bulk walk response too big, lowering max-repetitions",
+					"max_repetitions", tuner.repetitions)
+				continue
+			} else if bulkRequests == 1 && ctx.Err() == nil &&
+				(hasErrorStatus(err, GenErr) || errors.Is(err, ErrTimeout)) {
+				c.logger.Warn("GETBULK failed, falling back to GETNEXT", "error", err)
+				useBulk, fellBack = false, true
+				if stats != nil {
+					stats.BulkFallback = true
+				}
+				continue
+			}
 		}
 
 		if err != nil {
+			// Check if it's an expected end condition
 			if IsEndOfMIB(err) || IsNoSuchObject(err) || IsNoSuchInstance(err) {
-				return nil
+				return lastOID, nil
 			}
 			c.metrics.Errors.Add(1)
-			return err
+			return lastOID, limitErr(err)
 		}
 
-		if len(vars) == 0 {
-			return nil
-		}
+		holes := 0
+		for i, v := range vars {
+			if c.opts.SkipHoles && (v.Type == TypeNoSuchObject || v.Type == TypeNoSuchInstance) {
+				holes++
+				continue
+			}
 
-		for _, v := range vars {
-			if !v.OID.HasPrefix(rootOID) {
-				return nil
+			// Boundary checks come before anything that delivers v, so
+			// fn never sees a varbind past the subtree or end.
+			if isWalkDone(rootOID, lastOID, vars[i:]) {
+				return lastOID, nil
 			}
+			if end != nil && v.OID.Compare(end) >= 0 {
+				return lastOID, nil
+			}
+
+			if v.OID.Compare(lastOID) <= 0 {
+				// SNMPv1 has no endOfMibView, and some v1 agents answer a
+				// GETNEXT past their last object with the requested or an
+				// earlier OID instead of noSuchName.
+				if c.opts.Version == Version1 {
+					c.logger.Debug("SNMPv1 agent returned a non-increasing OID, ending walk",
+						"oid", v.OID,
+						"previous", lastOID)
+					return lastOID, nil
+				}
+				if !c.opts.LenientWalk {
+					return lastOID, fmt.Errorf("%w: %s after %s", ErrOIDNotIncreasing, v.OID, lastOID)
+				}
+
+				c.metrics.WalkAnomalies.Add(1)
+				if stats != nil {
+					stats.Anomalies++
+				}
+				c.logger.Warn("skipping non-increasing OID in walk",
+					"oid", v.OID,
+					"previous", lastOID)
+
+				regressions++
+				if regressions > c.opts.MaxWalkRegressions {
+					return lastOID, fmt.Errorf("%w: %d consecutive regressions at %s",
+						ErrOIDNotIncreasing, regressions, lastOID)
+				}
+				continue
+			}
+			regressions = 0
 
-			if v.Type == TypeEndOfMibView || v.Type == TypeNoSuchObject || v.Type == TypeNoSuchInstance {
-				return nil
+			if maxOIDs > 0 && delivered == maxOIDs {
+				return lastOID, fmt.Errorf("%w: more than %d variables", ErrWalkLimitExceeded, maxOIDs)
 			}
 
 			if err := fn(v); err != nil {
-				return err
+				return lastOID, err
+			}
+
+			lastOID = v.OID
+			delivered++
+			if stats != nil {
+				stats.Variables++
 			}
+		}
+
+		// An empty response, or nothing but holes: asking again from the
+		// same OID would loop
+		if holes == len(vars) {
+			return lastOID, nil
+		}
+	}
+}
+
+// isWalkDone reports whether a walk of root that last returned prev ends
+// at the head of vars: the list is empty, the first varbind is an
+// exception (endOfMibView, noSuchObject, noSuchInstance), it has left the
+// subtree, or the agent echoed prev back unchanged.
+func isWalkDone(root, prev OID, vars []Variable) bool {
+	if len(vars) == 0 {
+		return true
+	}
+
+	v := vars[0]
+	if v.Type.IsException() {
+		return true
+	}
+	return !v.OID.HasPrefix(root) || v.OID.Equal(prev)
+}
 
-			currentOID = v.OID
+// reportError returns the *ReportError for a report PDU, calling the
+// OnAuthFailure callback for authentication failures. A report is never a
+// successful response, even when it carries no known counter.
+func (c *Client) reportError(report *PDU) error {
+	for _, v := range report.Variables {
+		rerr := newReportError(v)
+		if rerr == nil {
+			continue
 		}
 
-		if c.opts.Version == Version1 && len(vars) > 0 {
-			currentOID = vars[0].OID
-		} else if len(vars) > 0 {
-			currentOID = vars[len(vars)-1].OID
+		if errors.Is(rerr, ErrAuthFailure) {
+			c.logger.Warn("authentication failure reported by agent", "reason", rerr.Reason)
+			if c.opts.OnAuthFailure != nil {
+				c.opts.OnAuthFailure(c, rerr.Reason)
+			}
+		} else {
+			c.logger.Warn("report received from agent", "reason", rerr.Reason, "oid", rerr.Report.OID)
 		}
+		return rerr
 	}
+
+	rerr := unknownReportError(report)
+	c.logger.Warn("report received from agent", "reason", rerr.Reason, "oid", rerr.Report.OID)
+	return rerr
 }
 
 // State returns the current connection state.
@@ -552,7 +1836,31 @@ func (c *Client) Metrics() *Metrics {
 	return c.metrics
 }
 
-// Options returns the client options.
+// MaxMessageSize returns the response size, in bytes, that bulk walks size
+// their requests for, or zero if there is no limit: the smaller of the
+// WithMaxMessageSize option and, for SNMPv3, the msgMaxSize the agent
+// advertised.
+func (c *Client) MaxMessageSize() int {
+	limit := c.opts.MaxMessageSize
+	if agent := c.AgentMaxMessageSize(); agent > 0 && (limit <= 0 || agent < limit) {
+		limit = agent
+	}
+	return limit
+}
+
+// AgentMaxMessageSize returns the msgMaxSize the SNMPv3 agent advertised in
+// its last message, which is learned during engine discovery, or zero if
+// it is not known yet or the client does not use SNMPv3.
+func (c *Client) AgentMaxMessageSize() int {
+	c.engineMu.Lock()
+	defer c.engineMu.Unlock()
+	return c.engine.maxSize
+}
+
+// Options returns the client options. They are shared by every request
+// and must not be modified once the client is in use: pass per-request
+// settings such as walk max-repetitions with WithRequestOptions, or use
+// Derive for a client with different settings.
 func (c *Client) Options() *ClientOptions {
 	return c.opts
 }
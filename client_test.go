@@ -0,0 +1,816 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetInContextV3(t *testing.T) {
+	agent := sysDescrAgent(newUSMUser("monitor", SHA, "authpass1", AES, "privpass1"))
+	port := agent.start(t)
+	c := newTestClient(t, port,
+		WithVersion(Version3),
+		WithSecurityLevel(AuthPriv),
+		WithSecurityName("monitor"),
+		WithAuth(SHA, "authpass1"),
+		WithPrivacy(AES, "privpass1"),
+		WithContextName("default"))
+
+	ctx := context.Background()
+	if _, err := c.GetInContext(ctx, "vrfB", OIDSysDescr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, OIDSysDescr); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := agent.received()
+	if len(reqs) != 3 {
+		t.Fatalf("agent received %d messages, want 3", len(reqs))
+	}
+	if got := reqs[1].ScopedPDU.ContextName; got != "vrfB" {
+		t.Errorf("GetInContext sent context %q, want vrfB", got)
+	}
+	if got := reqs[2].ScopedPDU.ContextName; got != "default" {
+		t.Errorf("Get after GetInContext sent context %q, want the client context", got)
+	}
+}
+
+func TestGetInContextCommunitySuffix(t *testing.T) {
+	communities := make(chan string, 2)
+	port := mockAgent(t, func(req *Message) *Message {
+		communities <- req.Community
+		return reply(req, Variable{OID: OIDSysDescr, Type: TypeOctetString, Value: []byte("x")})
+	})
+	c := newTestClient(t, port, WithCommunity("public"), WithContextCommunitySuffix(true))
+
+	ctx := context.Background()
+	if _, err := c.GetInContext(ctx, "vrfA", OIDSysDescr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, OIDSysDescr); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-communities; got != "public@vrfA" {
+		t.Errorf("GetInContext sent community %q, want public@vrfA", got)
+	}
+	if got := <-communities; got != "public" {
+		t.Errorf("Get sent community %q, want public", got)
+	}
+}
+
+func TestGetInContextRequiresContextSupport(t *testing.T) {
+	port := mockAgent(t, func(req *Message) *Message { return reply(req) })
+	c := newTestClient(t, port)
+
+	if _, err := c.GetInContext(context.Background(), "vrfA", OIDSysDescr); err == nil {
+		t.Fatal("GetInContext succeeded on a v2c client without WithContextCommunitySuffix")
+	}
+}
+
+func TestDisconnectStopsReconnect(t *testing.T) {
+	tests := []struct {
+		name string
+		// onReconnecting runs before each reconnection attempt.
+		onReconnecting ReconnectHandler
+	}{
+		// Disconnect lands while an attempt that would succeed is
+		// about to dial.
+		{"pending attempt", func(c *Client, opts *ClientOptions) { time.Sleep(50 * time.Millisecond) }},
+		// Disconnect lands while failed attempts back off.
+		{"backing off", func(c *Client, opts *ClientOptions) { opts.Target = "" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := mockAgent(t, func(req *Message) *Message { return nil })
+			c := newTestClient(t, port,
+				WithAutoReconnect(true),
+				WithConnectRetryInterval(10*time.Millisecond),
+				WithOnReconnecting(tt.onReconnecting))
+
+			c.handleConnectionLost(errors.New("link down"))
+			time.Sleep(20 * time.Millisecond)
+			if err := c.Disconnect(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			attempts := c.metrics.ReconnectAttempts.Value()
+			time.Sleep(100 * time.Millisecond)
+			if got := c.metrics.ReconnectAttempts.Value(); got != attempts {
+				t.Errorf("%d reconnection attempts after Disconnect", got-attempts)
+			}
+			if got := c.State(); got != StateDisconnected {
+				t.Errorf("state after Disconnect = %s, want %s", got, StateDisconnected)
+			}
+		})
+	}
+}
+
+func TestGetPipelinedNoSuchName(t *testing.T) {
+	missing := MustParseOID("1.3.6.1.2.1.1.99.0")
+	port := mockAgent(t, func(req *Message) *Message {
+		oid := req.PDU.Variables[0].OID
+		if oid.Equal(missing) {
+			resp := reply(req, Variable{OID: oid, Type: TypeNull})
+			resp.PDU.ErrorStatus, resp.PDU.ErrorIndex = NoSuchName, 1
+			return resp
+		}
+		return reply(req, Variable{OID: oid, Type: TypeOctetString, Value: []byte("test agent")})
+	})
+	c := newTestClient(t, port, WithVersion(Version1))
+
+	vars, err := c.GetPipelined(context.Background(), OIDSysDescr, missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != 2 || string(vars[0].AsBytes()) != "test agent" {
+		t.Fatalf("got %v", vars)
+	}
+	if !vars[1].OID.Equal(missing) || vars[1].Type != TypeNoSuchObject {
+		t.Errorf("missing object returned as %s %s, want noSuchObject", vars[1].OID, vars[1].Type)
+	}
+}
+
+func TestRequestValueType(t *testing.T) {
+	sent := make(chan BERType, 1)
+	port := mockAgent(t, func(req *Message) *Message {
+		sent <- req.PDU.Variables[0].Type
+		return reply(req, Variable{OID: OIDSysDescr, Type: TypeOctetString, Value: []byte("test agent")})
+	})
+	c := newTestClient(t, port, WithRequestValueType(TypeOctetString))
+	if _, err := c.Get(context.Background(), OIDSysDescr); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-sent; got != TypeOctetString {
+		t.Errorf("request value sent as %s, want %s", got, TypeOctetString)
+	}
+
+	c = NewClient(WithTarget("127.0.0.1"), WithPort(port), WithRequestValueType(TypeInteger))
+	if err := c.Connect(context.Background()); !errors.Is(err, ErrInvalidType) {
+		c.Close()
+		t.Fatalf("Connect with an INTEGER placeholder: %v, want ErrInvalidType", err)
+	}
+}
+
+func TestCommunityNormalization(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		request []RequestOption
+		want    string
+		wantErr error
+	}{
+		{"client trimmed", []Option{WithCommunity(" public\n")}, nil, "public", nil},
+		{"request trimmed", nil, []RequestOption{WithRequestCommunity(" views\t")}, "views", nil},
+		{"request non-printable", nil, []RequestOption{WithRequestCommunity("vi\x00ews")}, "", ErrInvalidCommunity},
+		{"request empty", nil, []RequestOption{WithRequestCommunity("  ")}, "", ErrInvalidCommunity},
+		{"lenient untrimmed", []Option{WithLenientCommunity(true), WithCommunity(" public ")}, nil, " public ", nil},
+		{"lenient request", []Option{WithLenientCommunity(true)}, []RequestOption{WithRequestCommunity("vi\x00ews ")}, "vi\x00ews ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := make(chan string, 1)
+			port := mockAgent(t, func(req *Message) *Message {
+				sent <- req.Community
+				return reply(req, Variable{OID: OIDSysDescr, Type: TypeOctetString, Value: []byte("test agent")})
+			})
+			c := newTestClient(t, port, tt.opts...)
+
+			ctx := WithRequestOptions(context.Background(), tt.request...)
+			_, err := c.Get(ctx, OIDSysDescr)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get: %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := <-sent; got != tt.want {
+				t.Errorf("agent received community %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestCommunity(t *testing.T) {
+	sent := make(chan string, 3)
+	port := mockAgent(t, func(req *Message) *Message {
+		sent <- req.Community
+		return sysDescrHandler(req)
+	})
+	c := newTestClient(t, port, WithCommunity("public"))
+	gen := c.generation.Load()
+
+	// The override applies to its own request only.
+	for _, community := range []string{"", "tenant-a", ""} {
+		ctx := context.Background()
+		want := "public"
+		if community != "" {
+			ctx = WithRequestOptions(ctx, WithRequestCommunity(community))
+			want = community
+		}
+		if _, err := c.Get(ctx, OIDSysDescr); err != nil {
+			t.Fatal(err)
+		}
+		if got := <-sent; got != want {
+			t.Errorf("agent received community %q, want %q", got, want)
+		}
+	}
+	if c.generation.Load() != gen {
+		t.Error("client reconnected to change the community")
+	}
+}
+
+func TestUnknownReport(t *testing.T) {
+	port := mockAgent(t, func(req *Message) *Message {
+		resp := reply(req, Variable{OID: MustParseOID("1.3.6.1.4.1.99999.1.0"), Type: TypeCounter32, Value: uint32(1)})
+		resp.PDU.Type = PDUReport
+		return resp
+	})
+	c := newTestClient(t, port)
+
+	vars, err := c.Get(context.Background(), OIDSysDescr)
+	if !errors.Is(err, ErrReport) {
+		t.Fatalf("Get answered by a report: %v, %v, want ErrReport", vars, err)
+	}
+	var rerr *ReportError
+	if !errors.As(err, &rerr) || rerr.Report.OID.String() != "1.3.6.1.4.1.99999.1.0" {
+		t.Errorf("report error %v does not carry the report varbind", err)
+	}
+}
+
+func TestInformRetransmission(t *testing.T) {
+	type attempt struct {
+		at        time.Time
+		requestID int32
+	}
+	attempts := make(chan attempt, 10)
+	var n atomic.Int32
+	port := mockAgent(t, func(req *Message) *Message {
+		attempts <- attempt{time.Now(), req.PDU.RequestID}
+		if n.Add(1) < 3 {
+			return nil
+		}
+		return reply(req, req.PDU.Variables...)
+	})
+	c := newTestClient(t, port,
+		WithTimeout(50*time.Millisecond),
+		WithInformRetries(5),
+		WithInformBackoff(2))
+
+	if err := c.Inform(context.Background(), 100, OIDSnmpTraps.Append(1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(attempts); got != 3 {
+		t.Fatalf("inform sent %d times, want 3", got)
+	}
+	first := <-attempts
+	prev := first
+	// The wait for an acknowledgment doubles after each attempt.
+	for i, wait := range []time.Duration{50 * time.Millisecond, 100 * time.Millisecond} {
+		a := <-attempts
+		if a.requestID != first.requestID {
+			t.Errorf("attempt %d request ID = %d, want %d", i+2, a.requestID, first.requestID)
+		}
+		if gap := a.at.Sub(prev.at); gap < wait*9/10 || gap > wait*2 {
+			t.Errorf("attempt %d sent %v after the previous one, want about %v", i+2, gap, wait)
+		}
+		prev = a
+	}
+	if got := c.Metrics().Retries.Value(); got != 2 {
+		t.Errorf("Retries = %d, want 2", got)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	const community = "s3cret-community"
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	port := mockAgent(t, func(req *Message) *Message {
+		return reply(req, Variable{OID: OIDSysDescr, Type: TypeOctetString, Value: []byte("test agent")})
+	})
+	c := newTestClient(t, port,
+		WithCommunity(community),
+		WithLogger(logger),
+		WithRedactSecrets(true),
+		WithPacketDump(func(dir PacketDirection, data []byte) {
+			logger.Debug("packet", "dir", dir, "data", string(data))
+		}))
+	if _, err := c.Get(context.Background(), OIDSysDescr); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := NewClientOptions()
+	for _, opt := range []Option{
+		WithCommunity(community),
+		WithVersion(Version3),
+		WithSecurityName("monitor"),
+		WithAuth(SHA, "auth-passphrase"),
+		WithPrivacy(AES, "priv-passphrase"),
+	} {
+		opt(opts)
+	}
+	logger.Info("client options", "options", opts)
+
+	out := buf.String()
+	for _, secret := range []string{community, "auth-passphrase", "priv-passphrase"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log contains %q:\n%s", secret, out)
+		}
+	}
+	if n := strings.Count(out, "msg=packet"); n != 2 {
+		t.Errorf("logged %d packets, want 2:\n%s", n, out)
+	}
+	for _, want := range []string{"community=****", "auth_passphrase=****", "priv_passphrase=****", "****************"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+// sysDescrHandler answers every request with sysDescr.0.
+func sysDescrHandler(req *Message) *Message {
+	return reply(req, Variable{OID: OIDSysDescr, Type: TypeOctetString, Value: []byte("test agent")})
+}
+
+func TestFailFast(t *testing.T) {
+	const reconnectDelay = 100 * time.Millisecond
+	for _, failFast := range []bool{true, false} {
+		t.Run(fmt.Sprintf("failFast=%t", failFast), func(t *testing.T) {
+			// The reconnection is held up until released.
+			release := make(chan struct{})
+			c := newTestClient(t, mockAgent(t, sysDescrHandler),
+				WithAutoReconnect(true),
+				WithFailFast(failFast),
+				WithOnReconnecting(func(*Client, *ClientOptions) { <-release }))
+			if _, err := c.Get(context.Background(), OIDSysDescr); err != nil {
+				t.Fatal(err)
+			}
+
+			dropConnection(t, c)
+			time.AfterFunc(reconnectDelay, func() { close(release) })
+
+			start := time.Now()
+			_, err := c.Get(context.Background(), OIDSysDescr)
+			elapsed := time.Since(start)
+			if failFast {
+				if !errors.Is(err, ErrNotConnected) {
+					t.Errorf("Get error = %v, want %v", err, ErrNotConnected)
+				}
+				if elapsed >= reconnectDelay {
+					t.Errorf("Get took %v, want it to fail before the reconnection", elapsed)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Get after reconnection: %v", err)
+			}
+			if elapsed < reconnectDelay*9/10 {
+				t.Errorf("Get returned after %v, before the reconnection", elapsed)
+			}
+		})
+	}
+}
+
+func TestConnectionRefused(t *testing.T) {
+	// A port nobody listens on.
+	pc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+	pc.Close()
+
+	const timeout = time.Second
+	c := newTestClient(t, port, WithTimeout(timeout), WithRetries(3))
+
+	start := time.Now()
+	_, err = c.Get(context.Background(), OIDSysDescr)
+	if !errors.Is(err, ErrConnectionRefused) {
+		t.Fatalf("Get error = %v, want %v", err, ErrConnectionRefused)
+	}
+	if elapsed := time.Since(start); elapsed >= timeout {
+		t.Errorf("Get took %v, want it to fail before the %v timeout", elapsed, timeout)
+	}
+	if got := c.Metrics().Retries.Value(); got != 0 {
+		t.Errorf("Retries = %d, want 0", got)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	pc, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFromUnix(buf)
+			if err != nil {
+				return
+			}
+			req, err := DecodeMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			data, err := sysDescrHandler(req).Encode()
+			if err != nil {
+				continue
+			}
+			pc.WriteToUnix(data, addr)
+		}
+	}()
+
+	c := NewClient(WithUnixSocket(path), WithTimeout(500*time.Millisecond), WithRetries(0))
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	local := c.localSocket
+
+	vars, err := c.Get(context.Background(), OIDSysDescr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != 1 || !vars[0].OID.Equal(OIDSysDescr) {
+		t.Errorf("Get = %v", vars)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(local); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("local socket %s not removed on close: %v", local, err)
+	}
+}
+
+// TestLateResponseAfterReconnect checks that a response to a request sent
+// before a reconnect is not delivered to a request of the old connection
+// that is still pending under the same request ID.
+func TestLateResponseAfterReconnect(t *testing.T) {
+	// Rebinding the same local port lets the late response reach the
+	// new socket.
+	pc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := pc.LocalAddr().String()
+	pc.Close()
+
+	held := make(chan int32, 1)
+	release := make(chan struct{})
+	var first atomic.Bool
+	port := mockAgent(t, func(req *Message) *Message {
+		if first.CompareAndSwap(false, true) {
+			held <- req.PDU.RequestID
+			<-release
+		}
+		return sysDescrHandler(req)
+	})
+	c := newTestClient(t, port,
+		WithLocalAddr(local),
+		WithAutoReconnect(true),
+		WithConnectRetryInterval(10*time.Millisecond))
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := c.Get(context.Background(), OIDSysDescr)
+		errCh <- err
+	}()
+	id := <-held
+	gen := c.generation.Load()
+
+	dropConnection(t, c)
+	if err := <-errCh; !errors.Is(err, ErrConnectionLost) {
+		t.Fatalf("Get error = %v, want %v", err, ErrConnectionLost)
+	}
+	deadline := time.Now().Add(time.Second)
+	for c.State() != StateConnected || c.generation.Load() == gen {
+		if time.Now().After(deadline) {
+			t.Fatal("client did not reconnect")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// A request of the old connection is still waiting under the ID.
+	stale := &pendingRequest{ch: make(chan response, 1)}
+	stale.gen.Store(gen)
+	c.pendingLock.Lock()
+	c.pending[id] = stale
+	c.pendingLock.Unlock()
+	defer func() {
+		c.pendingLock.Lock()
+		delete(c.pending, id)
+		c.pendingLock.Unlock()
+	}()
+
+	close(release)
+	deadline = time.Now().Add(time.Second)
+	for c.Metrics().ResponseMismatches.Value() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("late response not received")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case resp := <-stale.ch:
+		t.Errorf("late response delivered: %+v", resp)
+	default:
+	}
+
+	if _, err := c.Get(context.Background(), OIDSysDescr); err != nil {
+		t.Errorf("Get on the new connection: %v", err)
+	}
+}
+
+func TestSetIf(t *testing.T) {
+	guard := MustParseOID("1.3.6.1.4.1.99999.1.0")
+	target := MustParseOID("1.3.6.1.4.1.99999.2.0")
+	tests := []struct {
+		name     string
+		expected Variable
+		absent   bool
+		wantErr  error
+	}{
+		{"match", Variable{Type: TypeInteger, Value: int64(1)}, false, nil},
+		{"value mismatch", Variable{Type: TypeInteger, Value: 2}, false, ErrGuardMismatch},
+		{"type mismatch", Variable{Type: TypeGauge32, Value: uint32(1)}, false, ErrGuardMismatch},
+		{"guard absent", Variable{Type: TypeInteger, Value: 1}, true, ErrGuardMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sets atomic.Int32
+			port := mockAgent(t, func(req *Message) *Message {
+				if req.PDU.Type == PDUSetRequest {
+					sets.Add(1)
+					return reply(req, req.PDU.Variables...)
+				}
+				if tt.absent {
+					resp := reply(req, req.PDU.Variables...)
+					resp.PDU.ErrorStatus, resp.PDU.ErrorIndex = NoSuchName, 1
+					return resp
+				}
+				return reply(req, Variable{OID: guard, Type: TypeInteger, Value: 1})
+			})
+			c := newTestClient(t, port)
+
+			_, err := c.SetIf(context.Background(), guard, tt.expected,
+				Variable{OID: target, Type: TypeOctetString, Value: []byte("new")})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetIf error = %v, want %v", err, tt.wantErr)
+			}
+			wantSets := int32(0)
+			if tt.wantErr == nil {
+				wantSets = 1
+			}
+			if got := sets.Load(); got != wantSets {
+				t.Errorf("agent received %d SETs, want %d", got, wantSets)
+			}
+		})
+	}
+}
+
+func TestSameValue(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Variable
+		want bool
+	}{
+		{"integer across Go types", Variable{Type: TypeInteger, Value: 5}, Variable{Type: TypeInteger, Value: int32(5)}, true},
+		{"counter across Go types", Variable{Type: TypeCounter32, Value: uint32(7)}, Variable{Type: TypeCounter32, Value: 7}, true},
+		{"same value, different type", Variable{Type: TypeCounter32, Value: uint32(7)}, Variable{Type: TypeGauge32, Value: uint32(7)}, false},
+		{"octet string and text", Variable{Type: TypeOctetString, Value: []byte("up")}, Variable{Type: TypeOctetString, Value: "up"}, true},
+		{"different strings", Variable{Type: TypeOctetString, Value: []byte("up")}, Variable{Type: TypeOctetString, Value: "down"}, false},
+		{"IP address forms", Variable{Type: TypeIPAddress, Value: net.IP{192, 0, 2, 1}}, Variable{Type: TypeIPAddress, Value: "192.0.2.1"}, true},
+		{"OIDs", Variable{Type: TypeObjectIdentifier, Value: OIDSysDescr}, Variable{Type: TypeObjectIdentifier, Value: OIDSysName}, false},
+		{"non-numeric integer", Variable{Type: TypeInteger, Value: 1}, Variable{Type: TypeInteger, Value: "1"}, false},
+	}
+	for _, tt := range tests {
+		if got := sameValue(&tt.a, &tt.b); got != tt.want {
+			t.Errorf("%s: sameValue = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+// opaqueCounter64Response is an SNMPv1 GetResponse as sent by agents that
+// wrap 64-bit counters in Opaque, here ifHCInOctets.1 = 0x0123456789abcdef.
+// The 4-octet request ID starts at offset opaqueCounter64RequestID.
+const opaqueCounter64Response = "3037" + "020100" + "0406" + "7075626c6963" +
+	"a22a" + "020400000000" + "020100" + "020100" +
+	"301c" + "301a" + "060b" + "2b060102011f0101010601" +
+	"440b" + "9f7608" + "0123456789abcdef"
+
+const opaqueCounter64RequestID = 17
+
+func TestDecodeOpaqueSpecial(t *testing.T) {
+	packet, err := hex.DecodeString(opaqueCounter64Response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			port := rawAgent(t, func(req *Message) []byte {
+				resp := append([]byte(nil), packet...)
+				binary.BigEndian.PutUint32(resp[opaqueCounter64RequestID:], uint32(req.PDU.RequestID))
+				return resp
+			})
+			c := newTestClient(t, port, WithVersion(Version1), WithDecodeOpaqueSpecial(enabled))
+			vars, err := c.Get(context.Background(), MustParseOID("1.3.6.1.2.1.31.1.1.1.6.1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(vars) != 1 {
+				t.Fatalf("Get returned %d variables", len(vars))
+			}
+			v := vars[0]
+			if !enabled {
+				if v.Type != TypeOpaque {
+					t.Errorf("Type = %s, want Opaque", v.Type)
+				}
+				return
+			}
+			if v.Type != TypeCounter64 || v.Value != uint64(0x0123456789abcdef) {
+				t.Errorf("got %s %v, want Counter64 %d", v.Type, v.Value, uint64(0x0123456789abcdef))
+			}
+		})
+	}
+}
+
+func TestLenientDecode(t *testing.T) {
+	oids := []OID{OIDSysDescr, OIDSysUpTime, OIDSysName}
+	for _, lenient := range []bool{false, true} {
+		t.Run(fmt.Sprintf("lenient=%t", lenient), func(t *testing.T) {
+			// The response loses the tail of its last varbind in transit.
+			port := rawAgent(t, func(req *Message) []byte {
+				data, err := reply(req,
+					Variable{OID: OIDSysDescr, Type: TypeOctetString, Value: []byte("edge gateway")},
+					Variable{OID: OIDSysUpTime, Type: TypeTimeTicks, Value: uint32(4200)},
+					Variable{OID: OIDSysName, Type: TypeOctetString, Value: []byte("gw1")},
+				).Encode()
+				if err != nil {
+					t.Error(err)
+					return nil
+				}
+				return data[:len(data)-2]
+			})
+			c := newTestClient(t, port, WithLenientDecode(lenient))
+
+			vars, err := c.Get(context.Background(), oids...)
+			if !lenient {
+				if !errors.Is(err, ErrTimeout) {
+					t.Errorf("Get = %v, %v, want %v", vars, err, ErrTimeout)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The lost varbind reads as missing, as in any short response.
+			if len(vars) != 3 || !vars[0].OID.Equal(OIDSysDescr) || !vars[1].OID.Equal(OIDSysUpTime) || vars[2].Type != TypeNoSuchInstance {
+				t.Errorf("Get = %v, want the first 2 varbinds and a noSuchInstance", vars)
+			}
+		})
+	}
+}
+
+func TestOptionsClone(t *testing.T) {
+	o := NewClientOptions()
+	for _, opt := range []Option{
+		WithEngineID([]byte{0x80, 0x00, 0x1f, 0x88}),
+		WithLocalEngineID([]byte{0x80, 0x00, 0x1f, 0x89}),
+		WithKeepalive(time.Minute, OIDSysUpTime.Copy()),
+		WithLatencyBuckets([]int64{10, 100}),
+	} {
+		opt(o)
+	}
+
+	clone := o.Clone()
+	clone.EngineID[0] = 0
+	clone.LocalEngineID[0] = 0
+	clone.KeepaliveOID[0] = 0
+	clone.LatencyBuckets[0] = 0
+	clone.Community = "private"
+
+	if o.EngineID[0] != 0x80 || o.LocalEngineID[0] != 0x80 || o.KeepaliveOID[0] != 1 || o.LatencyBuckets[0] != 10 {
+		t.Errorf("changing the clone changed the original: %x %x %s %v", o.EngineID, o.LocalEngineID, o.KeepaliveOID, o.LatencyBuckets)
+	}
+	if o.Community != DefaultCommunity {
+		t.Errorf("original community = %q", o.Community)
+	}
+}
+
+func TestClientDerive(t *testing.T) {
+	sent := make(chan string, 1)
+	port := mockAgent(t, func(req *Message) *Message {
+		sent <- req.Community
+		return sysDescrHandler(req)
+	})
+	var authFailures atomic.Int32
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := NewClient(
+		WithTarget("127.0.0.1"),
+		WithPort(port),
+		WithCommunity("public"),
+		WithTimeout(500*time.Millisecond),
+		WithLogger(logger),
+		WithOnAuthFailure(func(*Client, string) { authFailures.Add(1) }))
+
+	d := c.Derive(WithCommunity("tenant-a"))
+	if c.opts.Community != "public" {
+		t.Errorf("original community = %q after Derive", c.opts.Community)
+	}
+	if d.opts.Target != c.opts.Target || d.opts.Port != port || d.opts.Timeout != 500*time.Millisecond {
+		t.Errorf("derived client did not keep the original options: %+v", d.opts)
+	}
+	if d.opts.Logger != logger {
+		t.Error("derived client has another logger")
+	}
+	d.opts.OnAuthFailure(d, "wrong digest")
+	if authFailures.Load() != 1 {
+		t.Error("derived client does not share the auth failure callback")
+	}
+	if d.Metrics() == c.Metrics() {
+		t.Error("derived client shares the original's metrics")
+	}
+
+	// The derived client is unconnected and independent of c, which
+	// is never connected.
+	if d.IsConnected() {
+		t.Fatal("derived client is connected")
+	}
+	if err := d.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := d.Get(context.Background(), OIDSysDescr); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-sent; got != "tenant-a" {
+		t.Errorf("agent received community %q, want %q", got, "tenant-a")
+	}
+	if c.IsConnected() {
+		t.Error("original client connected with the derived one")
+	}
+}
+
+func TestVersionDefaults(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		wantTimeout time.Duration
+		wantRetries int
+	}{
+		{"v2c", nil, DefaultTimeout, DefaultRetries},
+		{"v3", []Option{WithVersion(Version3)}, DefaultTimeout, DefaultRetries},
+		{"v1", []Option{WithVersion(Version1)}, DefaultV1Timeout, DefaultV1Retries},
+		{"v1 timeout before version", []Option{WithTimeout(time.Second), WithVersion(Version1)}, time.Second, DefaultV1Retries},
+		{"v1 retries after version", []Option{WithVersion(Version1), WithRetries(1)}, DefaultV1Timeout, 1},
+		{"v1 explicit v2c values", []Option{WithVersion(Version1), WithTimeout(DefaultTimeout), WithRetries(DefaultRetries)}, DefaultTimeout, DefaultRetries},
+		{"v1 zero retries", []Option{WithVersion(Version1), WithRetries(0)}, DefaultV1Timeout, 0},
+	}
+	for _, tt := range tests {
+		opts := NewClient(tt.opts...).Options()
+		if opts.Timeout != tt.wantTimeout || opts.Retries != tt.wantRetries {
+			t.Errorf("%s: timeout %v, retries %d, want %v, %d", tt.name, opts.Timeout, opts.Retries, tt.wantTimeout, tt.wantRetries)
+		}
+	}
+
+	// A derived client follows the defaults of its own version unless the
+	// original set them explicitly.
+	v2c := NewClient(WithTarget("192.0.2.1"))
+	if opts := v2c.Derive(WithVersion(Version1)).Options(); opts.Timeout != DefaultV1Timeout || opts.Retries != DefaultV1Retries {
+		t.Errorf("derived v1 client: timeout %v, retries %d", opts.Timeout, opts.Retries)
+	}
+	explicit := NewClient(WithTarget("192.0.2.1"), WithTimeout(time.Second))
+	if opts := explicit.Derive(WithVersion(Version1)).Options(); opts.Timeout != time.Second || opts.Retries != DefaultV1Retries {
+		t.Errorf("derived v1 client of an explicit timeout: timeout %v, retries %d", opts.Timeout, opts.Retries)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/edgeo-scada/snmp"
+	"github.com/spf13/cobra"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench OID [OID...]",
+	Short: "Benchmark an agent's GET/walk throughput",
+	Long: `Send requests to an SNMP agent as fast as possible for a fixed duration
+and report throughput, latency percentiles and errors.
+
+Operations:
+  get  - GET all given OIDs in a single request
+  walk - walk the subtree of the first OID (reports varbinds/sec)
+
+Examples:
+  # Benchmark GET of sysUpTime for 10 seconds
+  edgeo-snmp bench -t 192.168.1.1 --op get --duration 10s 1.3.6.1.2.1.1.3.0
+
+  # Benchmark ifTable walks with 4 concurrent workers
+  edgeo-snmp bench -t 192.168.1.1 --op walk --concurrency 4 1.3.6.1.2.1.2.2`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runBench,
+}
+
+var (
+	benchOp          string
+	benchDuration    time.Duration
+	benchConcurrency int
+)
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+
+	benchCmd.Flags().StringVar(&benchOp, "op", "get", "operation to benchmark: get, walk")
+	benchCmd.Flags().DurationVar(&benchDuration, "duration", 10*time.Second, "benchmark duration")
+	benchCmd.Flags().IntVar(&benchConcurrency, "concurrency", 1, "number of concurrent workers")
+}
+
+// benchResult accumulates per-operation results across workers.
+type benchResult struct {
+	mu       sync.Mutex
+	ops      int64
+	varbinds int64
+	errors   map[string]int64
+}
+
+func (r *benchResult) record(varbinds int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		r.errors[benchErrorKind(err)]++
+		return
+	}
+	r.ops++
+	r.varbinds += int64(varbinds)
+}
+
+// benchErrorKind classifies an error for the error breakdown.
+func benchErrorKind(err error) string {
+	var snmpErr *snmp.SNMPError
+	switch {
+	case snmp.IsTimeout(err):
+		return "timeout"
+	case errors.As(err, &snmpErr):
+		return snmpErr.Status.String()
+	case errors.Is(err, snmp.ErrConnectionRefused):
+		return "connection refused"
+	default:
+		return "other"
+	}
+}
+
+func runBench(cmd *cobra.Command, args []string) error {
+	if err := checkTarget(); err != nil {
+		return err
+	}
+
+	if benchOp != "get" && benchOp != "walk" {
+		return fmt.Errorf("unknown operation: %s (use get or walk)", benchOp)
+	}
+	if benchConcurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1")
+	}
+
+	oids, err := parseOIDs(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		fmt.Fprintln(os.Stderr, "\nInterrupted")
+		cancel()
+	}()
+
+	client, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer disconnectClient(client)
+
+	fmt.Fprintf(os.Stderr, "Benchmarking %s against %s for %s with %d worker(s)...\n",
+		benchOp, target, benchDuration, benchConcurrency)
+
+	runCtx, runCancel := context.WithTimeout(ctx, benchDuration)
+	defer runCancel()
+
+	result := &benchResult{errors: make(map[string]int64)}
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < benchConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for runCtx.Err() == nil {
+				var vars []snmp.Variable
+				var err error
+				if benchOp == "walk" {
+					vars, err = client.Walk(runCtx, oids[0])
+				} else {
+					vars, err = client.Get(runCtx, oids...)
+				}
+				// Requests cut short by the end of the run are not errors
+				if runCtx.Err() != nil {
+					return
+				}
+				result.record(len(vars), err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	printBenchReport(client, result, elapsed)
+
+	return nil
+}
+
+func printBenchReport(client *snmp.Client, result *benchResult, elapsed time.Duration) {
+	snap := client.Metrics().Snapshot()
+	latency := client.Metrics().RequestLatency
+	seconds := elapsed.Seconds()
+
+	PrintSection("Benchmark Results")
+	PrintKeyValue("Operation", benchOp)
+	PrintKeyValue("Duration", formatDuration(elapsed))
+	PrintKeyValue("Concurrency", strconv.Itoa(benchConcurrency))
+	PrintKeyValue("Operations", strconv.FormatInt(result.ops, 10))
+	PrintKeyValue("Operations/sec", fmt.Sprintf("%.1f", float64(result.ops)/seconds))
+	PrintKeyValue("Requests sent", strconv.FormatInt(snap.RequestsSent, 10))
+	PrintKeyValue("Requests/sec", fmt.Sprintf("%.1f", float64(snap.RequestsSent)/seconds))
+	if benchOp == "walk" {
+		PrintKeyValue("Varbinds/sec", fmt.Sprintf("%.1f", float64(result.varbinds)/seconds))
+	}
+
+	PrintSection("Latency")
+	PrintKeyValue("Min", fmt.Sprintf("%dms", max(snap.RequestLatency.Min, 0)))
+	PrintKeyValue("Avg", fmt.Sprintf("%.2fms", snap.RequestLatency.Avg))
+	PrintKeyValue("p50", fmt.Sprintf("%dms", latency.Percentile(0.50)))
+	PrintKeyValue("p95", fmt.Sprintf("%dms", latency.Percentile(0.95)))
+	PrintKeyValue("p99", fmt.Sprintf("%dms", latency.Percentile(0.99)))
+	PrintKeyValue("Max", fmt.Sprintf("%dms", snap.RequestLatency.Max))
+
+	PrintSection("Errors")
+	timeoutRate := 0.0
+	if snap.RequestsSent > 0 {
+		timeoutRate = float64(snap.Timeouts) / float64(snap.RequestsSent) * 100
+	}
+	PrintKeyValue("Timeouts", fmt.Sprintf("%d (%.2f%%)", snap.Timeouts, timeoutRate))
+	PrintKeyValue("Retries", strconv.FormatInt(snap.Retries, 10))
+
+	kinds := make([]string, 0, len(result.errors))
+	for kind := range result.errors {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		PrintKeyValue("Failed ("+kind+")", strconv.FormatInt(result.errors[kind], 10))
+	}
+	fmt.Println()
+}
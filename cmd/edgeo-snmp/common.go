@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/edgeo-scada/snmp"
+	"github.com/spf13/viper"
 )
 
 // createClient creates and connects an SNMP client with the current configuration.
@@ -30,7 +31,7 @@ func createClient(ctx context.Context) (*snmp.Client, error) {
 	opts := buildClientOptions()
 	client := snmp.NewClient(opts...)
 
-	connectCtx, cancel := context.WithTimeout(ctx, timeout)
+	connectCtx, cancel := context.WithTimeout(ctx, client.Options().Timeout)
 	defer cancel()
 
 	if err := client.Connect(connectCtx); err != nil {
@@ -46,11 +47,17 @@ func buildClientOptions() []snmp.Option {
 		snmp.WithTarget(target),
 		snmp.WithPort(port),
 		snmp.WithCommunity(community),
-		snmp.WithTimeout(timeout),
-		snmp.WithRetries(retries),
 		snmp.WithAutoReconnect(false),
 	}
 
+	// Left unset, the timeout and retries follow the SNMP version.
+	if explicitlySet("timeout") {
+		opts = append(opts, snmp.WithTimeout(timeout))
+	}
+	if explicitlySet("retries") {
+		opts = append(opts, snmp.WithRetries(retries))
+	}
+
 	// Parse SNMP version
 	switch strings.ToLower(version) {
 	case "1", "v1":
@@ -79,6 +86,13 @@ func buildClientOptions() []snmp.Option {
 	return opts
 }
 
+// explicitlySet reports whether the persistent flag name was given on the
+// command line, in the environment or in the config file, rather than left
+// at its default.
+func explicitlySet(name string) bool {
+	return rootCmd.PersistentFlags().Changed(name) || viper.IsSet(name)
+}
+
 // buildV3Options builds SNMPv3-specific options.
 func buildV3Options() []snmp.Option {
 	var opts []snmp.Option
@@ -100,38 +114,12 @@ func buildV3Options() []snmp.Option {
 
 	// Auth protocol
 	if authProtocol != "" {
-		var proto snmp.AuthProtocol
-		switch strings.ToUpper(authProtocol) {
-		case "MD5":
-			proto = snmp.MD5
-		case "SHA", "SHA-1":
-			proto = snmp.SHA
-		case "SHA-224":
-			proto = snmp.SHA224
-		case "SHA-256":
-			proto = snmp.SHA256
-		case "SHA-384":
-			proto = snmp.SHA384
-		case "SHA-512":
-			proto = snmp.SHA512
-		}
-		opts = append(opts, snmp.WithAuth(proto, authPassphrase))
+		opts = append(opts, snmp.WithAuth(parseAuthProtocol(authProtocol), authPassphrase))
 	}
 
 	// Privacy protocol
 	if privProtocol != "" {
-		var proto snmp.PrivProtocol
-		switch strings.ToUpper(privProtocol) {
-		case "DES":
-			proto = snmp.DES
-		case "AES", "AES-128":
-			proto = snmp.AES
-		case "AES-192":
-			proto = snmp.AES192
-		case "AES-256":
-			proto = snmp.AES256
-		}
-		opts = append(opts, snmp.WithPrivacy(proto, privPassphrase))
+		opts = append(opts, snmp.WithPrivacy(parsePrivProtocol(privProtocol), privPassphrase))
 	}
 
 	// Context name
@@ -142,6 +130,42 @@ func buildV3Options() []snmp.Option {
 	return opts
 }
 
+// parseAuthProtocol parses an --auth-protocol value.
+func parseAuthProtocol(s string) snmp.AuthProtocol {
+	switch strings.ToUpper(s) {
+	case "MD5":
+		return snmp.MD5
+	case "SHA", "SHA-1":
+		return snmp.SHA
+	case "SHA-224":
+		return snmp.SHA224
+	case "SHA-256":
+		return snmp.SHA256
+	case "SHA-384":
+		return snmp.SHA384
+	case "SHA-512":
+		return snmp.SHA512
+	default:
+		return snmp.NoAuth
+	}
+}
+
+// parsePrivProtocol parses a --priv-protocol value.
+func parsePrivProtocol(s string) snmp.PrivProtocol {
+	switch strings.ToUpper(s) {
+	case "DES":
+		return snmp.DES
+	case "AES", "AES-128":
+		return snmp.AES
+	case "AES-192":
+		return snmp.AES192
+	case "AES-256":
+		return snmp.AES256
+	default:
+		return snmp.NoPriv
+	}
+}
+
 // disconnectClient gracefully disconnects the client.
 func disconnectClient(client *snmp.Client) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -163,14 +187,35 @@ func printError(format string, args ...interface{}) {
 
 // parseOID parses an OID string.
 func parseOID(s string) (snmp.OID, error) {
-	return snmp.ParseOID(s)
+	return resolveOIDArg(s)
+}
+
+// resolveOIDArg parses an OID argument. Besides numeric OIDs it accepts a
+// name registered with snmp.RegisterOIDName optionally followed by an
+// instance index, e.g. "sysDescr.0" or "ifInOctets.3".
+func resolveOIDArg(s string) (snmp.OID, error) {
+	name, index, _ := strings.Cut(s, ".")
+	base, ok := snmp.LookupOID(name)
+	if !ok {
+		return snmp.ParseOID(s)
+	}
+
+	oid := base.Copy()
+	if index != "" {
+		suffix, err := snmp.ParseOID(index)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index for %s: %w", name, err)
+		}
+		oid = append(oid, suffix...)
+	}
+	return oid, nil
 }
 
 // parseOIDs parses multiple OID strings.
 func parseOIDs(args []string) ([]snmp.OID, error) {
 	oids := make([]snmp.OID, len(args))
 	for i, arg := range args {
-		oid, err := snmp.ParseOID(arg)
+		oid, err := resolveOIDArg(arg)
 		if err != nil {
 			return nil, fmt.Errorf("invalid OID '%s': %w", arg, err)
 		}
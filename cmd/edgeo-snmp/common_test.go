@@ -0,0 +1,66 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edgeo-scada/snmp"
+)
+
+func TestBuildClientOptionsVersionDefaults(t *testing.T) {
+	flags := rootCmd.PersistentFlags()
+	savedVersion, savedTimeout, savedRetries := version, timeout, retries
+	t.Cleanup(func() {
+		version, timeout, retries = savedVersion, savedTimeout, savedRetries
+		flags.Lookup("timeout").Changed = false
+		flags.Lookup("retries").Changed = false
+	})
+
+	tests := []struct {
+		name        string
+		version     string
+		flags       map[string]string
+		wantTimeout time.Duration
+		wantRetries int
+	}{
+		{"v2c defaults", "2c", nil, snmp.DefaultTimeout, snmp.DefaultRetries},
+		{"v1 defaults", "1", nil, snmp.DefaultV1Timeout, snmp.DefaultV1Retries},
+		{"v1 explicit", "1", map[string]string{"timeout": "2s", "retries": "1"}, 2 * time.Second, 1},
+		// Values equal to the v2c defaults still override the v1 ones.
+		{"v1 explicit v2c values", "1", map[string]string{"timeout": "5s", "retries": "3"}, 5 * time.Second, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version = tt.version
+			for _, name := range []string{"timeout", "retries"} {
+				f := flags.Lookup(name)
+				f.Value.Set(f.DefValue)
+				f.Changed = false
+			}
+			for name, value := range tt.flags {
+				if err := flags.Set(name, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := snmp.NewClient(buildClientOptions()...).Options()
+			if opts.Timeout != tt.wantTimeout || opts.Retries != tt.wantRetries {
+				t.Errorf("timeout %v, retries %d, want %v, %d", opts.Timeout, opts.Retries, tt.wantTimeout, tt.wantRetries)
+			}
+		})
+	}
+}
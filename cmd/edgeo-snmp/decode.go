@@ -0,0 +1,228 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/edgeo-scada/snmp"
+	"github.com/spf13/cobra"
+)
+
+var decodeCmd = &cobra.Command{
+	Use:   "decode HEX|FILE",
+	Short: "Decode a captured SNMP packet",
+	Long: `Decode an SNMPv1/v2c packet for offline analysis, such as one copied
+from a packet capture, and print its version, community, PDU and varbinds.
+
+The packet is given as hex on the command line or as a file holding either
+hex or the raw packet bytes. Hex may contain whitespace, colons and 0x
+prefixes.
+
+Examples:
+  # Decode a hex string
+  edgeo-snmp decode "30 26 02 01 01 04 06 70 75 62 6c 69 63 a0 19 ..."
+
+  # Decode a packet saved from Wireshark (Copy as Hex Stream)
+  edgeo-snmp decode packet.hex
+
+  # Print the decoded packet as JSON
+  edgeo-snmp decode packet.bin -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDecode,
+}
+
+func init() {
+	rootCmd.AddCommand(decodeCmd)
+}
+
+// DecodedPacket represents a decoded packet for output.
+type DecodedPacket struct {
+	Version        string           `json:"version"`
+	Community      string           `json:"community"`
+	PDUType        string           `json:"pdu_type"`
+	RequestID      *int32           `json:"request_id,omitempty"`
+	ErrorStatus    string           `json:"error_status,omitempty"`
+	ErrorIndex     *int             `json:"error_index,omitempty"`
+	NonRepeaters   *int             `json:"non_repeaters,omitempty"`
+	MaxRepetitions *int             `json:"max_repetitions,omitempty"`
+	Enterprise     string           `json:"enterprise,omitempty"`
+	AgentAddress   string           `json:"agent_address,omitempty"`
+	GenericTrap    *int             `json:"generic_trap,omitempty"`
+	SpecificTrap   *int             `json:"specific_trap,omitempty"`
+	Uptime         string           `json:"uptime,omitempty"`
+	Variables      []VariableOutput `json:"variables"`
+	vars           []snmp.Variable
+}
+
+func runDecode(cmd *cobra.Command, args []string) error {
+	data, err := readPacket(args[0])
+	if err != nil {
+		return err
+	}
+	printVerbose("Decoding %d bytes", len(data))
+
+	decoded, err := snmp.DecodeAny(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode packet: %w", err)
+	}
+
+	var packet DecodedPacket
+	switch msg := decoded.(type) {
+	case *snmp.Message:
+		packet = newDecodedMessage(msg)
+	case *snmp.TrapV1Message:
+		packet = newDecodedTrapV1(msg)
+	}
+
+	if outputFormat == "json" || outputFormat == "jsonl" {
+		data, _ := json.MarshalIndent(packet, "", "  ")
+		if outputFormat == "jsonl" {
+			data, _ = json.Marshal(packet)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	printDecodedPacket(packet)
+	return nil
+}
+
+// readPacket reads a packet from a file, as hex or raw bytes, or else
+// parses arg itself as hex.
+func readPacket(arg string) ([]byte, error) {
+	if info, err := os.Stat(arg); err == nil && info.Mode().IsRegular() {
+		content, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, err
+		}
+		if data, err := parseHexDump(string(content)); err == nil {
+			return data, nil
+		}
+		return content, nil
+	}
+
+	data, err := parseHexDump(arg)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a file nor valid hex: %w", arg, err)
+	}
+	return data, nil
+}
+
+// parseHexDump decodes hex, ignoring whitespace, colons and 0x prefixes.
+func parseHexDump(s string) ([]byte, error) {
+	var b strings.Builder
+	for _, field := range strings.Fields(s) {
+		for _, part := range strings.Split(field, ":") {
+			part = strings.TrimPrefix(strings.TrimPrefix(part, "0x"), "0X")
+			b.WriteString(part)
+		}
+	}
+	if b.Len() == 0 {
+		return nil, fmt.Errorf("empty packet")
+	}
+	return hex.DecodeString(b.String())
+}
+
+func newDecodedMessage(msg *snmp.Message) DecodedPacket {
+	pdu := msg.PDU
+	packet := DecodedPacket{
+		Version:   msg.Version.String(),
+		Community: msg.Community,
+		PDUType:   pdu.Type.String(),
+		RequestID: &pdu.RequestID,
+		vars:      pdu.Variables,
+	}
+	if pdu.Type == snmp.PDUGetBulkRequest {
+		packet.NonRepeaters = &pdu.NonRepeaters
+		packet.MaxRepetitions = &pdu.MaxRepetitions
+	} else {
+		packet.ErrorStatus = pdu.ErrorStatus.String()
+		packet.ErrorIndex = &pdu.ErrorIndex
+	}
+	packet.Variables = decodedVariables(pdu.Variables)
+	return packet
+}
+
+func newDecodedTrapV1(msg *snmp.TrapV1Message) DecodedPacket {
+	pdu := msg.PDU
+	packet := DecodedPacket{
+		Version:      msg.Version.String(),
+		Community:    msg.Community,
+		PDUType:      snmp.PDUTrapV1.String(),
+		Enterprise:   pdu.Enterprise.String(),
+		GenericTrap:  &pdu.GenericTrap,
+		SpecificTrap: &pdu.SpecificTrap,
+		Uptime:       snmp.TimeTicksToString(pdu.Timestamp),
+		vars:         pdu.Variables,
+	}
+	if len(pdu.AgentAddress) == 4 {
+		packet.AgentAddress = net.IP(pdu.AgentAddress).String()
+	}
+	packet.Variables = decodedVariables(pdu.Variables)
+	return packet
+}
+
+func decodedVariables(vars []snmp.Variable) []VariableOutput {
+	output := make([]VariableOutput, 0, len(vars))
+	for _, v := range vars {
+		output = append(output, VariableOutput{
+			OID:   v.OID.String(),
+			Type:  v.Type.String(),
+			Value: convertValue(v),
+		})
+	}
+	return output
+}
+
+func printDecodedPacket(packet DecodedPacket) {
+	PrintSection("SNMP Packet")
+	PrintKeyValue("Version", packet.Version)
+	PrintKeyValue("Community", packet.Community)
+	PrintKeyValue("PDU Type", packet.PDUType)
+	if packet.RequestID != nil {
+		PrintKeyValue("Request ID", fmt.Sprint(*packet.RequestID))
+	}
+	if packet.NonRepeaters != nil {
+		PrintKeyValue("Non-Repeaters", fmt.Sprint(*packet.NonRepeaters))
+		PrintKeyValue("Max-Repetitions", fmt.Sprint(*packet.MaxRepetitions))
+	}
+	if packet.ErrorIndex != nil {
+		PrintKeyValue("Error Status", packet.ErrorStatus)
+		PrintKeyValue("Error Index", fmt.Sprint(*packet.ErrorIndex))
+	}
+	if packet.GenericTrap != nil {
+		PrintKeyValue("Enterprise", packet.Enterprise)
+		PrintKeyValue("Agent Address", packet.AgentAddress)
+		PrintKeyValue("Generic Trap", fmt.Sprint(*packet.GenericTrap))
+		PrintKeyValue("Specific Trap", fmt.Sprint(*packet.SpecificTrap))
+		PrintKeyValue("Uptime", packet.Uptime)
+	}
+
+	PrintSection(fmt.Sprintf("Variables (%d)", len(packet.vars)))
+	oids := newOIDRenderer(numeric)
+	for _, v := range packet.vars {
+		fmt.Printf("  %s = %s: %s\n",
+			colorize(oids.RenderOID(v.OID), ColorCyan),
+			colorize(v.Type.String(), ColorYellow),
+			renderValue(v, oids))
+	}
+	fmt.Println()
+}
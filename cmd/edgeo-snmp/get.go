@@ -22,6 +22,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/edgeo-scada/snmp"
 	"github.com/spf13/cobra"
 )
 
@@ -37,6 +38,9 @@ Examples:
   # Get multiple OIDs
   edgeo-snmp get -t 192.168.1.1 1.3.6.1.2.1.1.1.0 1.3.6.1.2.1.1.3.0 1.3.6.1.2.1.1.5.0
 
+  # Sample a counter 10 times, once per second, as CSV
+  edgeo-snmp get -t 192.168.1.1 --repeat 10 --interval 1s -o csv ifInOctets.1
+
   # Using SNMPv3
   edgeo-snmp get -t 192.168.1.1 -V 3 -u admin -a SHA -A authpass -x AES -X privpass 1.3.6.1.2.1.1.1.0`,
 	Args: cobra.MinimumNArgs(1),
@@ -77,6 +81,9 @@ Examples:
 var (
 	maxRepetitions int
 	nonRepeaters   int
+
+	getRepeat   int
+	getInterval time.Duration
 )
 
 func init() {
@@ -84,6 +91,9 @@ func init() {
 	rootCmd.AddCommand(getNextCmd)
 	rootCmd.AddCommand(getBulkCmd)
 
+	getCmd.Flags().IntVar(&getRepeat, "repeat", 1, "number of samples to take")
+	getCmd.Flags().DurationVar(&getInterval, "interval", time.Second, "time between samples with --repeat")
+
 	getBulkCmd.Flags().IntVar(&maxRepetitions, "max-repetitions", 10, "max-repetitions value")
 	getBulkCmd.Flags().IntVar(&nonRepeaters, "non-repeaters", 0, "non-repeaters value")
 }
@@ -115,6 +125,16 @@ func runGet(cmd *cobra.Command, args []string) error {
 	}
 	defer disconnectClient(client)
 
+	if getRepeat > 1 && getInterval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+
+	formatter := NewFormatter(outputFormat)
+	defer formatter.Close()
+	if getRepeat > 1 {
+		return repeatGet(ctx, client, formatter, oids)
+	}
+
 	printVerbose("Sending GET request for %d OID(s)...", len(oids))
 	start := time.Now()
 
@@ -125,12 +145,45 @@ func runGet(cmd *cobra.Command, args []string) error {
 
 	printVerbose("Response received in %s", formatDuration(time.Since(start)))
 
-	formatter := NewFormatter(outputFormat)
+	formatter.Begin("get")
 	formatter.FormatVariables(vars)
 
 	return nil
 }
 
+// repeatGet takes --repeat samples of oids, --interval apart, printing
+// each with its time. Ctrl+C stops early without an error.
+func repeatGet(ctx context.Context, client *snmp.Client, formatter *Formatter, oids []snmp.OID) error {
+	formatter.Begin("get")
+
+	ticker := time.NewTicker(getInterval)
+	defer ticker.Stop()
+
+	for i := 0; i < getRepeat; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+
+		start := time.Now()
+		vars, err := client.Get(ctx, oids...)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("GET failed: %w", err)
+		}
+
+		printVerbose("Sample %d/%d received in %s", i+1, getRepeat, formatDuration(time.Since(start)))
+		formatter.FormatSample(start, vars)
+	}
+
+	return nil
+}
+
 func runGetNext(cmd *cobra.Command, args []string) error {
 	if err := checkTarget(); err != nil {
 		return err
@@ -168,6 +221,8 @@ func runGetNext(cmd *cobra.Command, args []string) error {
 	printVerbose("Response received in %s", formatDuration(time.Since(start)))
 
 	formatter := NewFormatter(outputFormat)
+	defer formatter.Close()
+	formatter.Begin("getnext")
 	formatter.FormatVariables(vars)
 
 	return nil
@@ -215,6 +270,8 @@ func runGetBulk(cmd *cobra.Command, args []string) error {
 	printVerbose("Response received in %s (%d variables)", formatDuration(time.Since(start)), len(vars))
 
 	formatter := NewFormatter(outputFormat)
+	defer formatter.Close()
+	formatter.Begin("getbulk")
 	formatter.FormatVariables(vars)
 
 	return nil
@@ -85,17 +85,26 @@ func runInfo(cmd *cobra.Command, args []string) error {
 	printVerbose("Retrieving system information...")
 	start := time.Now()
 
-	vars, err := client.Get(ctx, oids...)
+	byOID, err := client.GetMap(ctx, oids...)
 	if err != nil {
 		return fmt.Errorf("failed to get system info: %w", err)
 	}
 
+	// Present the objects in a fixed order whatever order the agent
+	// returned them in.
+	var vars []snmp.Variable
+	for _, oid := range oids {
+		if v, ok := byOID[oid.String()]; ok {
+			vars = append(vars, v)
+		}
+	}
+
 	printVerbose("Response received in %s", formatDuration(time.Since(start)))
 
 	if outputFormat == "json" {
 		formatter := NewFormatter(outputFormat)
 		formatter.FormatVariables(vars)
-		return nil
+		return formatter.Close()
 	}
 
 	// Pretty print system info
@@ -103,9 +112,13 @@ func runInfo(cmd *cobra.Command, args []string) error {
 	fmt.Println(colorize("System Information", ColorBold))
 	fmt.Println(colorize("==================", ColorBold))
 
+	renderer := newOIDRenderer(numeric)
 	for _, v := range vars {
 		name := getOIDName(v.OID)
-		value := formatValue(v)
+		if numeric {
+			name = v.OID.String()
+		}
+		value := renderValue(v, renderer)
 
 		// Special handling for uptime
 		if v.OID.Equal(snmp.OIDSysUpTime) {
@@ -137,7 +150,6 @@ func getOIDName(oid snmp.OID) string {
 		return "Location"
 	case oid.Equal(snmp.OIDSysServices):
 		return "Services"
-	default:
-		return oid.String()
 	}
+	return WellKnownOIDRenderer{}.RenderOID(oid)
 }
@@ -0,0 +1,126 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/edgeo-scada/snmp"
+	"github.com/spf13/cobra"
+)
+
+var monitorCmd = &cobra.Command{
+	Use:   "monitor OID [OID...]",
+	Short: "Poll OIDs periodically",
+	Long: `Repeatedly GET one or more OIDs until interrupted.
+
+Each poll is delayed by a random jitter so that many monitors started
+together do not poll in lockstep. While polls keep failing, the interval
+backs off exponentially up to --max-interval and returns to --interval
+after the first successful poll.
+
+Examples:
+  # Poll sysUpTime every 5 seconds
+  edgeo-snmp monitor -t 192.168.1.1 --interval 5s 1.3.6.1.2.1.1.3.0
+
+  # Poll interface counters every 30 seconds with 20% jitter
+  edgeo-snmp monitor -t 192.168.1.1 --interval 30s --jitter 0.2 ifInOctets.1 ifOutOctets.1`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runMonitor,
+}
+
+var (
+	monitorInterval    time.Duration
+	monitorJitter      float64
+	monitorMaxInterval time.Duration
+)
+
+func init() {
+	rootCmd.AddCommand(monitorCmd)
+
+	monitorCmd.Flags().DurationVar(&monitorInterval, "interval", 10*time.Second, "polling interval")
+	monitorCmd.Flags().Float64Var(&monitorJitter, "jitter", snmp.DefaultPollerJitter, "random jitter as a fraction of the interval (0-1)")
+	monitorCmd.Flags().DurationVar(&monitorMaxInterval, "max-interval", snmp.DefaultPollerMaxInterval, "maximum interval while backing off after errors")
+}
+
+func runMonitor(cmd *cobra.Command, args []string) error {
+	if err := checkTarget(); err != nil {
+		return err
+	}
+
+	if monitorInterval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	if monitorJitter < 0 || monitorJitter > 1 {
+		return fmt.Errorf("--jitter must be between 0 and 1")
+	}
+
+	oids, err := parseOIDs(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	client, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer disconnectClient(client)
+
+	printVerbose("Monitoring %d OID(s) every %s...", len(oids), monitorInterval)
+
+	formatter := NewFormatter(outputFormat)
+	defer formatter.Close()
+	formatter.Begin("monitor")
+
+	poller := snmp.NewBackoffPoller(monitorInterval,
+		snmp.WithPollerJitter(monitorJitter),
+		snmp.WithPollerMaxInterval(monitorMaxInterval),
+	)
+
+	err = poller.Run(ctx, func(ctx context.Context) error {
+		start := time.Now()
+		vars, err := client.Get(ctx, oids...)
+		if err != nil {
+			if ctx.Err() == nil {
+				printError("GET failed: %v", err)
+			}
+			return err
+		}
+
+		printVerbose("Response received in %s", formatDuration(time.Since(start)))
+		formatter.FormatVariables(vars)
+		return nil
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
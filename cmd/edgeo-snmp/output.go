@@ -15,6 +15,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
@@ -22,6 +23,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/edgeo-scada/snmp"
@@ -35,41 +37,179 @@ const (
 	FormatJSON  OutputFormat = "json"
 	FormatCSV   OutputFormat = "csv"
 	FormatRaw   OutputFormat = "raw"
+	FormatJSONL OutputFormat = "jsonl"
 )
 
+// JSONLSchemaVersion is the version of the jsonl line schema. It is bumped
+// whenever a field is removed or changes meaning; new fields may be added
+// without a version change.
+const JSONLSchemaVersion = 1
+
 // VariableOutput represents a variable for output.
 type VariableOutput struct {
+	Time  *time.Time  `json:"time,omitempty"`
 	OID   string      `json:"oid"`
 	Type  string      `json:"type"`
 	Value interface{} `json:"value"`
 }
 
+// JSONLRecord is a variable line of jsonl output.
+type JSONLRecord struct {
+	V     int         `json:"v"`
+	Time  *time.Time  `json:"time,omitempty"`
+	OID   string      `json:"oid"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// JSONLMeta is the leading metadata line of jsonl output.
+type JSONLMeta struct {
+	V    int           `json:"v"`
+	Meta JSONLMetaInfo `json:"meta"`
+}
+
+// JSONLMetaInfo describes the operation that produced the jsonl stream.
+type JSONLMetaInfo struct {
+	Target string    `json:"target,omitempty"`
+	Op     string    `json:"op"`
+	Time   time.Time `json:"time"`
+}
+
+// JSONLTrap is a trap line of jsonl output.
+type JSONLTrap struct {
+	V    int        `json:"v"`
+	Trap TrapOutput `json:"trap"`
+}
+
+// OIDRenderer renders OIDs for display.
+type OIDRenderer interface {
+	RenderOID(oid snmp.OID) string
+}
+
+// NumericOIDRenderer renders OIDs in dotted numeric form.
+type NumericOIDRenderer struct{}
+
+// RenderOID implements OIDRenderer.
+func (NumericOIDRenderer) RenderOID(oid snmp.OID) string {
+	return oid.String()
+}
+
+// WellKnownOIDRenderer renders OIDs under a name registered with
+// snmp.RegisterOIDName as name.suffix (e.g. sysDescr.0 or cisco.1.1208),
+// and others numerically.
+type WellKnownOIDRenderer struct{}
+
+// RenderOID implements OIDRenderer.
+func (WellKnownOIDRenderer) RenderOID(oid snmp.OID) string {
+	if name, ok := snmp.LookupOIDName(oid); ok {
+		return name
+	}
+	return oid.String()
+}
+
+// newOIDRenderer returns the renderer selected by the --numeric flag.
+func newOIDRenderer(numeric bool) OIDRenderer {
+	if numeric {
+		return NumericOIDRenderer{}
+	}
+	return WellKnownOIDRenderer{}
+}
+
 // Formatter handles output formatting.
 type Formatter struct {
 	format    OutputFormat
 	writer    io.Writer
 	csvWriter *csv.Writer
-	first     bool
+	// first is set until the first variable is written: the CSV header
+	// or the opening bracket of the JSON array is still due.
+	first bool
+	// mu serializes Begin, FormatTrap and Close, which the trap listener
+	// calls from concurrent handlers.
+	mu sync.Mutex
+	// header is the JSONL metadata line or CSV header that trap output
+	// started with, which a rotated log file starts with again.
+	header []byte
+	// oids renders OIDs in human-readable output. Machine-readable
+	// formats always use numeric OIDs.
+	oids OIDRenderer
+	// sampleTime, when set, stamps each variable of a repeated sample.
+	sampleTime *time.Time
 }
 
-// NewFormatter creates a new formatter.
+// NewFormatter creates a new formatter writing to stdout.
 func NewFormatter(format string) *Formatter {
+	return NewFormatterTo(format, os.Stdout)
+}
+
+// NewFormatterTo creates a new formatter writing to w.
+func NewFormatterTo(format string, w io.Writer) *Formatter {
 	f := &Formatter{
 		format: OutputFormat(format),
-		writer: os.Stdout,
+		writer: w,
 		first:  true,
+		oids:   newOIDRenderer(numeric),
 	}
 	if f.format == FormatCSV {
-		f.csvWriter = csv.NewWriter(os.Stdout)
+		f.csvWriter = csv.NewWriter(w)
 	}
 	return f
 }
 
+// Begin starts the output of an operation. For jsonl output it writes the
+// leading metadata line.
+func (f *Formatter) Begin(op string) {
+	if f.format != FormatJSONL {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, _ := json.Marshal(JSONLMeta{
+		V: JSONLSchemaVersion,
+		Meta: JSONLMetaInfo{
+			Target: target,
+			Op:     op,
+			Time:   time.Now(),
+		},
+	})
+	data = append(data, '\n')
+	f.writer.Write(data)
+	f.header = data
+}
+
+// fileHeader returns the JSONL metadata line or CSV header the output
+// started with, for a rotatingWriter to start each new file with. The
+// writer calls it from within Begin or FormatTrap, which hold f.mu.
+func (f *Formatter) fileHeader() []byte {
+	return f.header
+}
+
+// Close flushes any buffered output and closes the JSON array of
+// variables. Commands defer it, so output cut short by SIGINT or SIGTERM
+// is still well-formed.
+func (f *Formatter) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.format == FormatJSON && !f.first {
+		f.first = true
+		_, err := fmt.Fprintln(f.writer, "\n]")
+		return err
+	}
+	if f.csvWriter != nil {
+		f.csvWriter.Flush()
+		return f.csvWriter.Error()
+	}
+	return nil
+}
+
 // FormatVariable formats and prints a variable.
 func (f *Formatter) FormatVariable(v snmp.Variable) {
 	switch f.format {
 	case FormatJSON:
 		f.formatJSON(v)
+	case FormatJSONL:
+		f.formatJSONL(v)
 	case FormatCSV:
 		f.formatCSV(v)
 	case FormatRaw:
@@ -86,11 +226,31 @@ func (f *Formatter) FormatVariables(vars []snmp.Variable) {
 	}
 }
 
+// FormatSample prints variables sampled at t, adding the time to every
+// line: as a prefix in table and raw output and as a field or column in
+// the machine-readable formats.
+func (f *Formatter) FormatSample(t time.Time, vars []snmp.Variable) {
+	f.sampleTime = &t
+	defer func() { f.sampleTime = nil }()
+	f.FormatVariables(vars)
+}
+
+// samplePrefix returns the time prefix of a table or raw line.
+func (f *Formatter) samplePrefix() string {
+	if f.sampleTime == nil {
+		return ""
+	}
+	return f.sampleTime.Format("15:04:05.000") + " "
+}
+
 func (f *Formatter) formatTable(v snmp.Variable) {
 	var sb strings.Builder
+	if prefix := f.samplePrefix(); prefix != "" {
+		sb.WriteString(colorize(prefix, ColorGray))
+	}
 
 	// OID
-	sb.WriteString(colorize(v.OID.String(), ColorCyan))
+	sb.WriteString(colorize(f.oids.RenderOID(v.OID), ColorCyan))
 	sb.WriteString(" = ")
 
 	// Type
@@ -98,37 +258,72 @@ func (f *Formatter) formatTable(v snmp.Variable) {
 	sb.WriteString(": ")
 
 	// Value
-	sb.WriteString(formatValue(v))
+	sb.WriteString(renderValue(v, f.oids))
 
 	fmt.Fprintln(f.writer, sb.String())
 }
 
 func (f *Formatter) formatJSON(v snmp.Variable) {
 	output := VariableOutput{
+		Time:  f.sampleTime,
 		OID:   v.OID.String(),
 		Type:  v.Type.String(),
 		Value: convertValue(v),
 	}
 	data, _ := json.Marshal(output)
+
+	// Variables form one array, which Close ends.
+	sep := ",\n"
+	if f.first {
+		sep = "[\n"
+		f.first = false
+	}
+	fmt.Fprint(f.writer, sep+"  "+string(data))
+}
+
+func (f *Formatter) formatJSONL(v snmp.Variable) {
+	f.writeJSONLine(JSONLRecord{
+		V:     JSONLSchemaVersion,
+		Time:  f.sampleTime,
+		OID:   v.OID.String(),
+		Type:  v.Type.String(),
+		Value: convertValue(v),
+	})
+}
+
+func (f *Formatter) writeJSONLine(record interface{}) {
+	data, _ := json.Marshal(record)
 	fmt.Fprintln(f.writer, string(data))
 }
 
 func (f *Formatter) formatCSV(v snmp.Variable) {
+	record := []string{v.OID.String(), v.Type.String(), formatValue(v)}
+	header := []string{"oid", "type", "value"}
+	if f.sampleTime != nil {
+		record = append([]string{f.sampleTime.Format(time.RFC3339Nano)}, record...)
+		header = append([]string{"time"}, header...)
+	}
+
 	if f.first {
-		f.csvWriter.Write([]string{"oid", "type", "value"})
+		f.csvWriter.Write(header)
 		f.first = false
 	}
 
-	f.csvWriter.Write([]string{
-		v.OID.String(),
-		v.Type.String(),
-		formatValue(v),
-	})
+	f.csvWriter.Write(record)
 	f.csvWriter.Flush()
 }
 
 func (f *Formatter) formatRaw(v snmp.Variable) {
-	fmt.Fprintln(f.writer, formatValue(v))
+	fmt.Fprintln(f.writer, f.samplePrefix()+formatValue(v))
+}
+
+// renderValue formats a variable value for human-readable output, where
+// OID values such as a sysObjectID are rendered with oids.
+func renderValue(v snmp.Variable, oids OIDRenderer) string {
+	if oid, ok := v.Value.(snmp.OID); ok && v.Type == snmp.TypeObjectIdentifier {
+		return oids.RenderOID(oid)
+	}
+	return formatValue(v)
 }
 
 // formatValue formats a variable value for display.
@@ -147,6 +342,12 @@ func formatValue(v snmp.Variable) string {
 			if isPrintable(val) {
 				return fmt.Sprintf("\"%s\"", string(val))
 			}
+			if mac, ok := guessMAC(val); ok {
+				return mac
+			}
+			if ts, ok := guessDateAndTime(val); ok {
+				return ts
+			}
 			// Otherwise print as hex
 			return formatHex(val)
 		case string:
@@ -188,6 +389,12 @@ func formatValue(v snmp.Variable) string {
 		}
 		return fmt.Sprintf("%v", v.Value)
 
+	case snmp.TypeNsapAddress:
+		if data, ok := v.Value.([]byte); ok {
+			return formatHex(data)
+		}
+		return fmt.Sprintf("%v", v.Value)
+
 	case snmp.TypeNoSuchObject:
 		return "No Such Object"
 
@@ -204,6 +411,10 @@ func formatValue(v snmp.Variable) string {
 
 // convertValue converts a variable value for JSON output.
 func convertValue(v snmp.Variable) interface{} {
+	if v.Type.IsException() {
+		return nil
+	}
+
 	switch v.Type {
 	case snmp.TypeNull:
 		return nil
@@ -214,6 +425,12 @@ func convertValue(v snmp.Variable) interface{} {
 			if isPrintable(val) {
 				return string(val)
 			}
+			if mac, ok := guessMAC(val); ok {
+				return mac
+			}
+			if ts, ok := guessDateAndTime(val); ok {
+				return ts
+			}
 			return formatHex(val)
 		default:
 			return v.Value
@@ -244,6 +461,12 @@ func convertValue(v snmp.Variable) interface{} {
 		}
 		return v.Value
 
+	case snmp.TypeNsapAddress:
+		if data, ok := v.Value.([]byte); ok {
+			return formatHex(data)
+		}
+		return v.Value
+
 	default:
 		return v.Value
 	}
@@ -259,6 +482,34 @@ func isPrintable(data []byte) bool {
 	return true
 }
 
+// guessMAC renders a non-printable 6-byte OCTET STRING as a MAC address
+// unless --mac-format=off.
+func guessMAC(data []byte) (string, bool) {
+	if macFormat == "off" || len(data) != 6 {
+		return "", false
+	}
+	return snmp.FormatMAC(data), true
+}
+
+// guessDateAndTime renders a non-printable 8- or 11-byte OCTET STRING as
+// an ISO 8601 timestamp if it is a valid DateAndTime. An 8-byte value is a
+// local time of the agent with no offset from UTC, so it is printed
+// without a zone. The value carries no type information, so this is a
+// heuristic; the year range keeps it from matching arbitrary binary data.
+func guessDateAndTime(data []byte) (string, bool) {
+	if len(data) != 8 && len(data) != 11 {
+		return "", false
+	}
+	t, err := snmp.ParseDateAndTime(data)
+	if err != nil || t.Year() < 1970 || t.Year() > 2200 {
+		return "", false
+	}
+	if len(data) == 8 {
+		return t.Format("2006-01-02T15:04:05.0"), true
+	}
+	return t.Format("2006-01-02T15:04:05.0Z07:00"), true
+}
+
 // formatHex formats bytes as hex string.
 func formatHex(data []byte) string {
 	var parts []string
@@ -359,62 +610,128 @@ type TrapOutput struct {
 	Timestamp     time.Time        `json:"timestamp"`
 	Version       string           `json:"version"`
 	Community     string           `json:"community,omitempty"`
+	SecurityName  string           `json:"security_name,omitempty"`
+	SecurityLevel string           `json:"security_level,omitempty"`
 	SourceAddress string           `json:"source_address"`
 	Enterprise    string           `json:"enterprise,omitempty"`
 	AgentAddress  string           `json:"agent_address,omitempty"`
 	GenericTrap   int              `json:"generic_trap,omitempty"`
 	SpecificTrap  int              `json:"specific_trap,omitempty"`
+	TrapOID       string           `json:"trap_oid,omitempty"`
 	Uptime        string           `json:"uptime,omitempty"`
 	Variables     []VariableOutput `json:"variables"`
 }
 
-// FormatTrap formats a trap for output.
+// FormatTrap formats a trap for output. The whole trap goes out in a
+// single Write, so a rotating log file never splits one across files.
 func (f *Formatter) FormatTrap(trap *snmp.TrapPDU) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// The header only becomes the file header once written, so that a
+	// rotation on this very write doesn't start the file with it twice.
+	header := f.header
+	var buf bytes.Buffer
 	switch f.format {
 	case FormatJSON:
-		f.formatTrapJSON(trap)
+		f.formatTrapJSON(&buf, trap)
+	case FormatJSONL:
+		data, _ := json.Marshal(JSONLTrap{V: JSONLSchemaVersion, Trap: newTrapOutput(trap)})
+		buf.Write(data)
+		buf.WriteByte('\n')
+	case FormatCSV:
+		if header == nil {
+			header = csvRecord(trapCSVHeader)
+			buf.Write(header)
+		}
+		f.formatTrapCSV(&buf, trap)
 	default:
-		f.formatTrapTable(trap)
+		f.formatTrapTable(&buf, trap)
 	}
+	f.writer.Write(buf.Bytes())
+	f.header = header
 }
 
-func (f *Formatter) formatTrapTable(trap *snmp.TrapPDU) {
-	fmt.Println()
-	fmt.Println(colorize("=== TRAP RECEIVED ===", ColorBold))
-	fmt.Printf("  %s: %s\n", colorize("Time", ColorCyan), time.Now().Format(time.RFC3339))
-	fmt.Printf("  %s: %s\n", colorize("Source", ColorCyan), trap.SourceAddress)
-	fmt.Printf("  %s: %s\n", colorize("Version", ColorCyan), trap.Version)
-	fmt.Printf("  %s: %s\n", colorize("Community", ColorCyan), trap.Community)
+func (f *Formatter) formatTrapTable(w io.Writer, trap *snmp.TrapPDU) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, colorize("=== TRAP RECEIVED ===", ColorBold))
+	fmt.Fprintf(w, "  %s: %s\n", colorize("Time", ColorCyan), time.Now().Format(time.RFC3339))
+	fmt.Fprintf(w, "  %s: %s\n", colorize("Source", ColorCyan), trap.SourceAddress)
+	fmt.Fprintf(w, "  %s: %s\n", colorize("Version", ColorCyan), trap.Version)
+	if trap.Version == snmp.Version3 {
+		fmt.Fprintf(w, "  %s: %s\n", colorize("Security Name", ColorCyan), trap.SecurityName)
+		fmt.Fprintf(w, "  %s: %s\n", colorize("Security Level", ColorCyan), trap.SecurityLevel)
+	} else {
+		fmt.Fprintf(w, "  %s: %s\n", colorize("Community", ColorCyan), trap.Community)
+	}
 
 	if trap.Version == snmp.Version1 {
-		fmt.Printf("  %s: %s\n", colorize("Enterprise", ColorCyan), trap.Enterprise)
-		fmt.Printf("  %s: %s\n", colorize("Agent Address", ColorCyan), trap.AgentAddress)
-		fmt.Printf("  %s: %d\n", colorize("Generic Trap", ColorCyan), trap.GenericTrap)
-		fmt.Printf("  %s: %d\n", colorize("Specific Trap", ColorCyan), trap.SpecificTrap)
+		fmt.Fprintf(w, "  %s: %s\n", colorize("Enterprise", ColorCyan), trap.Enterprise)
+		fmt.Fprintf(w, "  %s: %s\n", colorize("Agent Address", ColorCyan), trap.AgentAddress)
+		fmt.Fprintf(w, "  %s: %d\n", colorize("Generic Trap", ColorCyan), trap.GenericTrap)
+		fmt.Fprintf(w, "  %s: %d\n", colorize("Specific Trap", ColorCyan), trap.SpecificTrap)
 	}
 
-	fmt.Printf("  %s: %s\n", colorize("Uptime", ColorCyan), snmp.TimeTicksToString(trap.Timestamp))
+	fmt.Fprintf(w, "  %s: %s\n", colorize("Uptime", ColorCyan), snmp.TimeTicksToString(trap.Timestamp))
+	if len(trap.TrapOID) > 0 {
+		fmt.Fprintf(w, "  %s: %s\n", colorize("Trap OID", ColorCyan), f.oids.RenderOID(trap.TrapOID))
+	}
 
 	if len(trap.Variables) > 0 {
-		fmt.Println()
-		fmt.Println(colorize("Variables:", ColorBold))
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, colorize("Variables:", ColorBold))
 		for _, v := range trap.Variables {
-			fmt.Printf("    %s = %s: %s\n",
-				colorize(v.OID.String(), ColorCyan),
+			fmt.Fprintf(w, "    %s = %s: %s\n",
+				colorize(f.oids.RenderOID(v.OID), ColorCyan),
 				colorize(v.Type.String(), ColorYellow),
-				formatValue(v))
+				renderValue(v, f.oids))
 		}
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
+}
+
+func (f *Formatter) formatTrapJSON(w io.Writer, trap *snmp.TrapPDU) {
+	data, _ := json.MarshalIndent(newTrapOutput(trap), "", "  ")
+	fmt.Fprintln(w, string(data))
 }
 
-func (f *Formatter) formatTrapJSON(trap *snmp.TrapPDU) {
+// trapCSVHeader is the header row of CSV trap output.
+var trapCSVHeader = []string{"time", "source", "version", "trap_oid", "oid", "type", "value"}
+
+// csvRecord returns fields encoded as a CSV line.
+func csvRecord(fields []string) []byte {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.Write(fields)
+	cw.Flush()
+	return buf.Bytes()
+}
+
+// formatTrapCSV writes one row per trap variable, repeating the trap's
+// time, source and trap OID on each.
+func (f *Formatter) formatTrapCSV(w io.Writer, trap *snmp.TrapPDU) {
+	cw := csv.NewWriter(w)
+	now := time.Now().Format(time.RFC3339Nano)
+	for _, v := range trap.Variables {
+		cw.Write([]string{now, trap.SourceAddress, trap.Version.String(),
+			trap.TrapOID.String(), v.OID.String(), v.Type.String(), formatValue(v)})
+	}
+	cw.Flush()
+}
+
+func newTrapOutput(trap *snmp.TrapPDU) TrapOutput {
 	output := TrapOutput{
 		Timestamp:     time.Now(),
 		Version:       trap.Version.String(),
 		Community:     trap.Community,
 		SourceAddress: trap.SourceAddress,
 		Uptime:        snmp.TimeTicksToString(trap.Timestamp),
+		TrapOID:       trap.TrapOID.String(),
+	}
+
+	if trap.Version == snmp.Version3 {
+		output.SecurityName = trap.SecurityName
+		output.SecurityLevel = trap.SecurityLevel.String()
 	}
 
 	if trap.Version == snmp.Version1 {
@@ -432,6 +749,5 @@ func (f *Formatter) formatTrapJSON(trap *snmp.TrapPDU) {
 		})
 	}
 
-	data, _ := json.MarshalIndent(output, "", "  ")
-	fmt.Fprintln(f.writer, string(data))
+	return output
 }
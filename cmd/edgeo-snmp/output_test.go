@@ -0,0 +1,210 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgeo-scada/snmp"
+)
+
+func TestGuessDateAndTime(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"local time", []byte{0x07, 0xe9, 5, 26, 13, 30, 15, 0}, "2025-05-26T13:30:15.0"},
+		{"with offset", []byte{0x07, 0xe9, 5, 26, 13, 30, 15, 0, '+', 2, 0}, "2025-05-26T13:30:15.0+02:00"},
+		{"UTC offset", []byte{0x07, 0xe9, 5, 26, 13, 30, 15, 0, '+', 0, 0}, "2025-05-26T13:30:15.0Z"},
+	}
+	for _, tt := range tests {
+		got, ok := guessDateAndTime(tt.data)
+		if !ok || got != tt.want {
+			t.Errorf("%s: guessDateAndTime = %q, %v, want %q", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestFormatTrapRotatedHeader(t *testing.T) {
+	for _, format := range []string{"csv", "jsonl"} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "traps.log")
+			w, err := newRotatingWriter(path, 400, 50)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+			f := NewFormatterTo(format, w)
+			w.onOpen = f.fileHeader
+			f.Begin("trap")
+
+			trap := &snmp.TrapPDU{
+				Version:       snmp.Version2c,
+				SourceAddress: "192.0.2.1",
+				TrapOID:       snmp.MustParseOID("1.3.6.1.6.3.1.1.5.1"),
+				Variables: []snmp.Variable{
+					{OID: snmp.OIDSysUpTime, Type: snmp.TypeTimeTicks, Value: uint32(100)},
+				},
+			}
+			// Handlers format traps concurrently.
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					f.FormatTrap(trap)
+				}()
+			}
+			wg.Wait()
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			header := string(f.fileHeader())
+			if header == "" {
+				t.Fatal("no header recorded")
+			}
+			files, _ := filepath.Glob(path + "*")
+			if len(files) < 2 {
+				t.Fatalf("log did not rotate: %v", files)
+			}
+			for _, name := range files {
+				data := readLog(t, name)
+				if !strings.HasPrefix(data, header) {
+					t.Errorf("%s does not start with the header", filepath.Base(name))
+				}
+				if strings.Count(data, header) != 1 {
+					t.Errorf("%s holds the header %d times", filepath.Base(name), strings.Count(data, header))
+				}
+			}
+		})
+	}
+}
+
+// endlessAgent is an SNMPv2c agent on a loopback port whose MIB never
+// ends: every request is answered with the next few integers.
+func endlessAgent(t *testing.T) int {
+	t.Helper()
+	pc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	root := snmp.MustParseOID("1.3.6.1.4.1.99999.1")
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req, err := snmp.DecodeMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			next := 1
+			if oid := req.PDU.Variables[0].OID; len(oid) > len(root) {
+				next = oid[len(root)] + 1
+			}
+			var vars []snmp.Variable
+			for i := 0; i < max(1, req.PDU.MaxRepetitions); i++ {
+				vars = append(vars, snmp.Variable{OID: root.Append(next + i), Type: snmp.TypeInteger, Value: next + i})
+			}
+			resp := &snmp.Message{
+				Version:   req.Version,
+				Community: req.Community,
+				PDU:       &snmp.PDU{Type: snmp.PDUGetResponse, RequestID: req.PDU.RequestID, Variables: vars},
+			}
+			if data, err := resp.Encode(); err == nil {
+				pc.WriteToUDP(data, addr)
+			}
+		}
+	}()
+	return pc.LocalAddr().(*net.UDPAddr).Port
+}
+
+// TestFormatterInterruptedWalk cancels a walk the way SIGINT does and
+// checks the output the formatter leaves is still well-formed.
+func TestFormatterInterruptedWalk(t *testing.T) {
+	const want = 7
+	port := endlessAgent(t)
+	for _, format := range []string{"csv", "json"} {
+		t.Run(format, func(t *testing.T) {
+			client := snmp.NewClient(
+				snmp.WithTarget("127.0.0.1"),
+				snmp.WithPort(port),
+				snmp.WithTimeout(500*time.Millisecond),
+				snmp.WithRetries(0))
+			if err := client.Connect(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			var buf bytes.Buffer
+			formatter := NewFormatterTo(format, &buf)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			count := 0
+			err := client.WalkFunc(ctx, snmp.MustParseOID("1.3.6.1.4.1.99999.1"), func(v snmp.Variable) error {
+				formatter.FormatVariable(v)
+				if count++; count == want {
+					cancel()
+				}
+				return nil
+			})
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("walk error = %v, want %v", err, context.Canceled)
+			}
+			if err := formatter.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var rows int
+			switch format {
+			case "csv":
+				records, err := csv.NewReader(&buf).ReadAll()
+				if err != nil {
+					t.Fatalf("invalid CSV: %v\n%s", err, buf.String())
+				}
+				if len(records) == 0 || strings.Join(records[0], ",") != "oid,type,value" {
+					t.Fatalf("CSV header = %v", records)
+				}
+				rows = len(records) - 1
+			case "json":
+				var vars []VariableOutput
+				if err := json.Unmarshal(buf.Bytes(), &vars); err != nil {
+					t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+				}
+				rows = len(vars)
+			}
+			// The rest of the GETBULK response being delivered still follows.
+			if rows < want {
+				t.Errorf("output has %d variables, want at least %d", rows, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/edgeo-scada/snmp"
+	"github.com/spf13/cobra"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Export a structured device profile",
+	Long: `Collect a device profile from an SNMP agent for inventory purposes.
+
+The profile contains:
+  - The system group (sysDescr, sysObjectID, sysUpTime, ...)
+  - The interface count and a summary of the ifTable and ifXTable
+  - The sysORTable (supported MIB modules)
+
+Tables the agent does not implement are reported as empty.
+
+Examples:
+  # Print a device profile
+  edgeo-snmp profile -t 192.168.1.1
+
+  # Export a machine-readable profile
+  edgeo-snmp profile -t 192.168.1.1 -o json`,
+	RunE: runProfile,
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+}
+
+// ProfileOutput represents an agent profile for output.
+type ProfileOutput struct {
+	Target     string            `json:"target"`
+	System     SystemOutput      `json:"system"`
+	IfNumber   int               `json:"if_number"`
+	Interfaces []InterfaceOutput `json:"interfaces"`
+	ORTable    []SysOROutput     `json:"sys_or_table"`
+}
+
+// SystemOutput represents the system group for output.
+type SystemOutput struct {
+	Descr    string `json:"descr"`
+	ObjectID string `json:"object_id"`
+	UpTime   uint32 `json:"uptime"`
+	Contact  string `json:"contact"`
+	Name     string `json:"name"`
+	Location string `json:"location"`
+	Services int    `json:"services"`
+}
+
+// InterfaceOutput represents an ifTable row for output.
+type InterfaceOutput struct {
+	Index        int    `json:"index"`
+	Name         string `json:"name,omitempty"`
+	Descr        string `json:"descr"`
+	Alias        string `json:"alias,omitempty"`
+	Type         int    `json:"type"`
+	Speed        uint64 `json:"speed"`
+	AdminStatus  int    `json:"admin_status"`
+	OperStatus   int    `json:"oper_status"`
+	InOctets     uint64 `json:"in_octets"`
+	OutOctets    uint64 `json:"out_octets"`
+	HighCapacity bool   `json:"high_capacity"`
+}
+
+// SysOROutput represents a sysORTable row for output.
+type SysOROutput struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id"`
+	Descr  string `json:"descr"`
+	UpTime uint32 `json:"uptime"`
+}
+
+func runProfile(cmd *cobra.Command, args []string) error {
+	if err := checkTarget(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	client, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer disconnectClient(client)
+
+	printVerbose("Collecting device profile...")
+	start := time.Now()
+
+	profile, err := client.Profile(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to collect profile: %w", err)
+	}
+
+	printVerbose("Profile collected in %s", formatDuration(time.Since(start)))
+
+	output := newProfileOutput(profile)
+
+	if outputFormat == "json" {
+		data, _ := json.MarshalIndent(output, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	PrintSection("System")
+	PrintKeyValue("Description", output.System.Descr)
+	PrintKeyValue("Object ID", output.System.ObjectID)
+	PrintKeyValue("Uptime", snmp.TimeTicksToString(output.System.UpTime))
+	PrintKeyValue("Contact", output.System.Contact)
+	PrintKeyValue("Name", output.System.Name)
+	PrintKeyValue("Location", output.System.Location)
+	PrintKeyValue("Services", strconv.Itoa(output.System.Services))
+
+	PrintSection(fmt.Sprintf("Interfaces (%d)", output.IfNumber))
+	table := NewTableWriter("Index", "Name", "Description", "Type", "Speed", "Admin", "Oper", "In Octets", "Out Octets")
+	for _, i := range output.Interfaces {
+		table.AddRow(
+			strconv.Itoa(i.Index),
+			i.Name,
+			i.Descr,
+			strconv.Itoa(i.Type),
+			strconv.FormatUint(i.Speed, 10),
+			ifStatusString(i.AdminStatus),
+			ifStatusString(i.OperStatus),
+			strconv.FormatUint(i.InOctets, 10),
+			strconv.FormatUint(i.OutOctets, 10),
+		)
+	}
+	table.Render()
+
+	PrintSection("Object Resources")
+	table = NewTableWriter("Index", "ID", "Description")
+	for _, e := range output.ORTable {
+		table.AddRow(strconv.Itoa(e.Index), e.ID, e.Descr)
+	}
+	table.Render()
+	fmt.Println()
+
+	return nil
+}
+
+func newProfileOutput(p *snmp.AgentProfile) ProfileOutput {
+	output := ProfileOutput{
+		Target: target,
+		System: SystemOutput{
+			Descr:    p.System.Descr,
+			ObjectID: p.System.ObjectID.String(),
+			UpTime:   p.System.UpTime,
+			Contact:  p.System.Contact,
+			Name:     p.System.Name,
+			Location: p.System.Location,
+			Services: p.System.Services,
+		},
+		IfNumber:   p.IfNumber,
+		Interfaces: []InterfaceOutput{},
+		ORTable:    []SysOROutput{},
+	}
+
+	for _, i := range p.Interfaces {
+		output.Interfaces = append(output.Interfaces, InterfaceOutput{
+			Index:        i.Index,
+			Name:         i.Name,
+			Descr:        i.Descr,
+			Alias:        i.Alias,
+			Type:         i.Type,
+			Speed:        i.Speed,
+			AdminStatus:  i.AdminStatus,
+			OperStatus:   i.OperStatus,
+			InOctets:     i.InOctets,
+			OutOctets:    i.OutOctets,
+			HighCapacity: i.HighCapacity,
+		})
+	}
+
+	for _, e := range p.ORTable {
+		output.ORTable = append(output.ORTable, SysOROutput{
+			Index:  e.Index,
+			ID:     e.ID.String(),
+			Descr:  e.Descr,
+			UpTime: e.UpTime,
+		})
+	}
+
+	return output
+}
+
+// ifStatusString returns the textual form of ifAdminStatus/ifOperStatus.
+func ifStatusString(status int) string {
+	switch status {
+	case 1:
+		return "up"
+	case 2:
+		return "down"
+	case 3:
+		return "testing"
+	case 4:
+		return "unknown"
+	case 5:
+		return "dormant"
+	case 6:
+		return "notPresent"
+	case 7:
+		return "lowerLayerDown"
+	default:
+		return strconv.Itoa(status)
+	}
+}
@@ -18,8 +18,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/edgeo-scada/snmp"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -48,6 +51,7 @@ var (
 	verbose      bool
 	noColor      bool
 	numeric      bool
+	macFormat    string
 )
 
 var rootCmd = &cobra.Command{
@@ -80,6 +84,13 @@ Examples:
 
 func init() {
 	cobra.OnInitialize(initConfig)
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := validateConfig(); err != nil {
+			printError("%v", err)
+			return err
+		}
+		return nil
+	}
 
 	// Connection flags
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "", "", "config file (default is $HOME/.edgeo-snmp.yaml)")
@@ -87,8 +98,8 @@ func init() {
 	rootCmd.PersistentFlags().IntVarP(&port, "port", "p", 161, "SNMP agent port")
 	rootCmd.PersistentFlags().StringVarP(&community, "community", "c", "public", "community string (v1/v2c)")
 	rootCmd.PersistentFlags().StringVarP(&version, "version", "V", "2c", "SNMP version (1, 2c, 3)")
-	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 5*time.Second, "request timeout")
-	rootCmd.PersistentFlags().IntVarP(&retries, "retries", "r", 3, "number of retries")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", snmp.DefaultTimeout, fmt.Sprintf("request timeout (%s for SNMPv1)", snmp.DefaultV1Timeout))
+	rootCmd.PersistentFlags().IntVarP(&retries, "retries", "r", snmp.DefaultRetries, fmt.Sprintf("number of retries (%d for SNMPv1)", snmp.DefaultV1Retries))
 
 	// SNMPv3 flags
 	rootCmd.PersistentFlags().StringVar(&securityLevel, "security-level", "noAuthNoPriv", "security level (noAuthNoPriv, authNoPriv, authPriv)")
@@ -100,10 +111,11 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&contextName, "context", "n", "", "context name")
 
 	// Output flags
-	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "output format: table, json, csv, raw")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "output format: table, json, jsonl, csv, raw")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "disable colored output")
 	rootCmd.PersistentFlags().BoolVar(&numeric, "numeric", false, "print OIDs numerically")
+	rootCmd.PersistentFlags().StringVar(&macFormat, "mac-format", "auto", "render 6-byte binary strings as MAC addresses: auto, off")
 
 	// Bind flags to viper
 	viper.BindPFlag("target", rootCmd.PersistentFlags().Lookup("target"))
@@ -123,6 +135,7 @@ func init() {
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
 	viper.BindPFlag("no-color", rootCmd.PersistentFlags().Lookup("no-color"))
 	viper.BindPFlag("numeric", rootCmd.PersistentFlags().Lookup("numeric"))
+	viper.BindPFlag("mac-format", rootCmd.PersistentFlags().Lookup("mac-format"))
 }
 
 func initConfig() {
@@ -166,4 +179,56 @@ func initConfig() {
 	verbose = viper.GetBool("verbose")
 	noColor = viper.GetBool("no-color")
 	numeric = viper.GetBool("numeric")
+	macFormat = viper.GetString("mac-format")
+}
+
+// validateConfig checks the settings merged from flags, environment and the
+// config file. Unknown config file keys, usually typos such as "comunity",
+// are reported as warnings; invalid values are errors.
+func validateConfig() error {
+	var unknown []string
+	for _, key := range viper.AllKeys() {
+		if key == "config" || rootCmd.PersistentFlags().Lookup(key) == nil {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		fmt.Fprintf(os.Stderr, "Warning: unknown config key %q in %s\n", key, viper.ConfigFileUsed())
+	}
+
+	switch strings.ToLower(version) {
+	case "1", "v1", "2c", "v2c", "2", "3", "v3":
+	default:
+		return fmt.Errorf("invalid version %q (expected 1, 2c or 3)", version)
+	}
+
+	switch strings.ToLower(securityLevel) {
+	case "noauthnopriv", "authnopriv", "authpriv":
+	default:
+		return fmt.Errorf("invalid security-level %q (expected noAuthNoPriv, authNoPriv or authPriv)", securityLevel)
+	}
+
+	if authProtocol != "" && parseAuthProtocol(authProtocol) == snmp.NoAuth {
+		return fmt.Errorf("invalid auth-protocol %q (expected MD5, SHA, SHA-224, SHA-256, SHA-384 or SHA-512)", authProtocol)
+	}
+	if privProtocol != "" && parsePrivProtocol(privProtocol) == snmp.NoPriv {
+		return fmt.Errorf("invalid priv-protocol %q (expected DES, AES, AES-192 or AES-256)", privProtocol)
+	}
+
+	switch OutputFormat(outputFormat) {
+	case FormatTable, FormatJSON, FormatJSONL, FormatCSV, FormatRaw:
+	default:
+		return fmt.Errorf("invalid output %q (expected table, json, jsonl, csv or raw)", outputFormat)
+	}
+
+	if macFormat != "auto" && macFormat != "off" {
+		return fmt.Errorf("invalid mac-format %q (expected auto or off)", macFormat)
+	}
+
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port %d", port)
+	}
+
+	return nil
 }
@@ -0,0 +1,162 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// rotatingWriter appends to a log file and rotates it once it would grow
+// past maxSize: path becomes path.1, path.1 becomes path.2 and so on, and
+// the oldest backup beyond maxBackups is removed.
+//
+// Each Write goes to the file unbuffered and is never split across files,
+// so a record written in one call survives a crash and stays whole.
+type rotatingWriter struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	// onOpen, if set, returns what each file opened by a rotation starts
+	// with, such as a CSV header, so that every file reads on its own.
+	// It is called with mu held, from within Write.
+	onOpen func() []byte
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// newRotatingWriter opens path for appending, creating it if needed.
+func newRotatingWriter(path string, maxSize int64, maxBackups int) (*rotatingWriter, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("max size must be positive")
+	}
+	if maxBackups < 0 {
+		return nil, fmt.Errorf("max backups must not be negative")
+	}
+	w := &rotatingWriter{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.size = f, info.Size()
+	return nil
+}
+
+// Write implements io.Writer. A write larger than maxSize still goes to a
+// file of its own.
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+		if w.onOpen != nil {
+			if _, err := w.write(w.onOpen()); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return w.write(p)
+}
+
+func (w *rotatingWriter) write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups and starts a new file. The caller must hold
+// w.mu.
+func (w *rotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	if w.maxBackups == 0 {
+		if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return w.open()
+	}
+
+	os.Remove(w.backup(w.maxBackups))
+	for i := w.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(w.backup(i), w.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(w.path, w.backup(1)); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+func (w *rotatingWriter) backup(n int) string {
+	return fmt.Sprintf("%s.%d", w.path, n)
+}
+
+// Close closes the current file.
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// parseSize parses a byte size such as "100MB", "512K" or "1048576".
+// Units are powers of 1024.
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		scale  int64
+	}{
+		{"GB", 1 << 30}, {"G", 1 << 30},
+		{"MB", 1 << 20}, {"M", 1 << 20},
+		{"KB", 1 << 10}, {"K", 1 << 10},
+		{"B", 1},
+	}
+
+	str := strings.ToUpper(strings.TrimSpace(s))
+	scale := int64(1)
+	for _, u := range units {
+		if rest, ok := strings.CutSuffix(str, u.suffix); ok {
+			str, scale = strings.TrimSpace(rest), u.scale
+			break
+		}
+	}
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * scale, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readLog returns the contents of the file at path, or "<missing>".
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "<missing>"
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotatingWriter(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxSize    int64
+		maxBackups int
+		writes     []string
+		// want is the contents of the log file and its backups, from
+		// the log file on; "<missing>" for a file that must not exist.
+		want []string
+	}{
+		{
+			name:    "exactly max size",
+			maxSize: 10, maxBackups: 2,
+			writes: []string{"12345", "67890"},
+			want:   []string{"1234567890", "<missing>"},
+		},
+		{
+			name:    "one byte past max size",
+			maxSize: 10, maxBackups: 2,
+			writes: []string{"12345", "67890", "x"},
+			want:   []string{"x", "1234567890", "<missing>"},
+		},
+		{
+			name:    "no backups",
+			maxSize: 10, maxBackups: 0,
+			writes: []string{"1234567890", "abc"},
+			want:   []string{"abc", "<missing>"},
+		},
+		{
+			name:    "record larger than max size",
+			maxSize: 10, maxBackups: 2,
+			writes: []string{"abc", strings.Repeat("x", 25), "def"},
+			want:   []string{"def", strings.Repeat("x", 25), "abc", "<missing>"},
+		},
+		{
+			name:    "oldest backup dropped",
+			maxSize: 3, maxBackups: 2,
+			writes: []string{"aaa", "bbb", "ccc", "ddd"},
+			want:   []string{"ddd", "ccc", "bbb", "<missing>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "traps.log")
+			w, err := newRotatingWriter(path, tt.maxSize, tt.maxBackups)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+
+			for _, s := range tt.writes {
+				if n, err := w.Write([]byte(s)); err != nil || n != len(s) {
+					t.Fatalf("Write(%q) = %d, %v", s, n, err)
+				}
+			}
+			for i, want := range tt.want {
+				name := path
+				if i > 0 {
+					name = w.backup(i)
+				}
+				if got := readLog(t, name); got != want {
+					t.Errorf("%s holds %q, want %q", filepath.Base(name), got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRotatingWriterOnOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traps.csv")
+	w, err := newRotatingWriter(path, 12, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.onOpen = func() []byte { return []byte("h\n") }
+
+	for _, s := range []string{"h\n", "row1\n", "row2\n", "row3\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := readLog(t, w.backup(1)), "h\nrow1\nrow2\n"; got != want {
+		t.Errorf("rotated file holds %q, want %q", got, want)
+	}
+	if got, want := readLog(t, path), "h\nrow3\n"; got != want {
+		t.Errorf("new file holds %q, want %q", got, want)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"1048576", 1 << 20},
+		{"512K", 512 << 10},
+		{"100MB", 100 << 20},
+		{" 1 gb ", 1 << 30},
+	}
+	for _, tt := range tests {
+		if got, err := parseSize(tt.in); err != nil || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "0", "-1MB", "ten"} {
+		if _, err := parseSize(in); err == nil {
+			t.Errorf("parseSize(%q) succeeded", in)
+		}
+	}
+}
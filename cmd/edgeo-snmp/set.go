@@ -38,7 +38,7 @@ Type specifiers:
   i - INTEGER
   u - Unsigned INTEGER (Gauge32)
   c - Counter32
-  s - OCTET STRING (text)
+  s - OCTET STRING (text, sent byte for byte)
   x - OCTET STRING (hex bytes, e.g., "DE AD BE EF")
   d - OCTET STRING (decimal bytes, e.g., "10.0.1.1")
   n - NULL
@@ -46,6 +46,10 @@ Type specifiers:
   t - TimeTicks
   a - IP Address
 
+An empty value ("") with s, x or d sends a zero-length OCTET STRING, which
+agents treat differently from NULL. Bytes that cannot be typed on a command
+line, such as an embedded NUL, are given in hex with x.
+
 Examples:
   # Set system contact (string)
   edgeo-snmp set -t 192.168.1.1 1.3.6.1.2.1.1.4.0 s "admin@example.com"
@@ -53,6 +57,10 @@ Examples:
   # Set system name
   edgeo-snmp set -t 192.168.1.1 1.3.6.1.2.1.1.5.0 s "switch01"
 
+  # Clear a string, or set one with an embedded NUL byte
+  edgeo-snmp set -t 192.168.1.1 1.3.6.1.2.1.1.6.0 s ""
+  edgeo-snmp set -t 192.168.1.1 1.3.6.1.4.1.9999.1.0 x "61 00 62"
+
   # Set an integer value
   edgeo-snmp set -t 192.168.1.1 1.3.6.1.4.1.9.2.1.55.0 i 5
 
@@ -114,6 +122,8 @@ func runSet(cmd *cobra.Command, args []string) error {
 	printVerbose("Response received in %s", formatDuration(time.Since(start)))
 
 	formatter := NewFormatter(outputFormat)
+	defer formatter.Close()
+	formatter.Begin("set")
 	formatter.FormatVariables(result)
 
 	return nil
@@ -123,7 +133,7 @@ func parseSetVariables(args []string) ([]snmp.Variable, error) {
 	var variables []snmp.Variable
 
 	for i := 0; i < len(args); i += 3 {
-		oid, err := snmp.ParseOID(args[i])
+		oid, err := resolveOIDArg(args[i])
 		if err != nil {
 			return nil, fmt.Errorf("invalid OID '%s': %w", args[i], err)
 		}
@@ -254,6 +264,9 @@ func parseHexString(s string) ([]byte, error) {
 }
 
 func parseDottedDecimal(s string) ([]byte, error) {
+	if s == "" {
+		return []byte{}, nil
+	}
 	parts := strings.Split(s, ".")
 	bytes := make([]byte, len(parts))
 
@@ -0,0 +1,63 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/edgeo-scada/snmp"
+)
+
+func TestParseValueOctetString(t *testing.T) {
+	oid := snmp.MustParseOID("1.3.6.1.2.1.1.6.0")
+	tests := []struct {
+		typeSpec, value string
+		want            []byte
+	}{
+		{"s", "", []byte{}},
+		{"x", "", []byte{}},
+		{"d", "", []byte{}},
+		{"s", "a\x00b", []byte{'a', 0, 'b'}},
+		{"x", "61 00 62", []byte{'a', 0, 'b'}},
+		{"s", "00", []byte("00")},
+	}
+	for _, tt := range tests {
+		v, err := parseValue(oid, tt.typeSpec, tt.value)
+		if err != nil {
+			t.Errorf("parseValue(%s %q): %v", tt.typeSpec, tt.value, err)
+			continue
+		}
+
+		// The value must survive the wire as an OCTET STRING of exactly
+		// those bytes, not as NULL.
+		data, err := (&snmp.Message{Version: snmp.Version2c, Community: "private", PDU: &snmp.PDU{
+			Type:      snmp.PDUSetRequest,
+			Variables: []snmp.Variable{*v},
+		}}).Encode()
+		if err != nil {
+			t.Errorf("encoding %s %q: %v", tt.typeSpec, tt.value, err)
+			continue
+		}
+		msg, err := snmp.DecodeMessage(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := msg.PDU.Variables[0]
+		if got.Type != snmp.TypeOctetString || !bytes.Equal(got.AsBytes(), tt.want) {
+			t.Errorf("%s %q sent as %s % x, want OCTET STRING % x", tt.typeSpec, tt.value, got.Type, got.AsBytes(), tt.want)
+		}
+	}
+}
@@ -40,14 +40,35 @@ Examples:
   # Listen on alternate port
   edgeo-snmp trap-listen --listen ":1162"
 
+  # Listen for IPv6 traps only
+  edgeo-snmp trap-listen --listen "[::1]:1162" --network udp6
+
   # Listen with community filter
-  edgeo-snmp trap-listen --trap-community private`,
+  edgeo-snmp trap-listen --trap-community private
+
+  # Accept SNMPv3 notifications from an authenticated, encrypted user
+  edgeo-snmp trap-listen -u monitor -a SHA -A authpass -x AES -X privpass
+
+  # Forward traps to a syslog collector as RFC 5424 messages
+  edgeo-snmp trap-listen --listen ":1162" --forward-syslog udp://siem.example.com:514
+
+  # Spill traps to disk while the collector is unreachable
+  edgeo-snmp trap-listen --forward-syslog tcp://siem.example.com:601 --spool-dir /var/spool/edgeo-snmp
+
+  # Log traps as JSON lines to a file rotated at 100MB, keeping 5 old files
+  edgeo-snmp trap-listen -o jsonl --log-file traps.log --max-size 100MB --max-backups 5`,
 	RunE: runTrapListen,
 }
 
 var (
 	listenAddress string
 	trapCommunity string
+	trapNetwork   string
+	forwardSyslog string
+	spoolDir      string
+	trapLogFile   string
+	trapLogSize   string
+	trapLogKeep   int
 )
 
 func init() {
@@ -55,13 +76,50 @@ func init() {
 
 	trapListenCmd.Flags().StringVar(&listenAddress, "listen", ":162", "listen address (host:port)")
 	trapListenCmd.Flags().StringVar(&trapCommunity, "trap-community", "", "filter by community string (empty = accept all)")
+	trapListenCmd.Flags().StringVar(&trapNetwork, "network", "udp", "network to listen on: udp (IPv4+IPv6), udp4, udp6")
+	trapListenCmd.Flags().StringVar(&forwardSyslog, "forward-syslog", "", "forward traps to a syslog collector (udp://host:port or tcp://host:port)")
+	trapListenCmd.Flags().StringVar(&spoolDir, "spool-dir", "", "queue traps and spill them to this directory while the queue is full")
+	trapListenCmd.Flags().StringVar(&trapLogFile, "log-file", "", "write traps to this file instead of stdout")
+	trapListenCmd.Flags().StringVar(&trapLogSize, "max-size", "100MB", "rotate the log file once it reaches this size (e.g. 512KB, 100MB, 1GB)")
+	trapListenCmd.Flags().IntVar(&trapLogKeep, "max-backups", 5, "number of rotated log files to keep")
 }
 
 func runTrapListen(cmd *cobra.Command, args []string) error {
+	var logWriter *rotatingWriter
+	if trapLogFile != "" {
+		maxSize, err := parseSize(trapLogSize)
+		if err != nil {
+			return fmt.Errorf("--max-size: %w", err)
+		}
+		logWriter, err = newRotatingWriter(trapLogFile, maxSize, trapLogKeep)
+		if err != nil {
+			return fmt.Errorf("failed to open trap log: %w", err)
+		}
+		defer logWriter.Close()
+		// Escape sequences have no place in a log file.
+		noColor = true
+	}
+
+	var forwarder *snmp.SyslogTrapForwarder
+	if forwardSyslog != "" {
+		var err error
+		forwarder, err = snmp.NewSyslogTrapForwarder(forwardSyslog)
+		if err != nil {
+			return err
+		}
+		defer forwarder.Close()
+	}
+
 	fmt.Printf("Starting SNMP trap listener on %s\n", listenAddress)
 	if trapCommunity != "" {
 		fmt.Printf("Filtering by community: %s\n", trapCommunity)
 	}
+	if forwarder != nil {
+		fmt.Printf("Forwarding traps to syslog: %s\n", forwardSyslog)
+	}
+	if logWriter != nil {
+		fmt.Printf("Logging traps to: %s\n", trapLogFile)
+	}
 	fmt.Println("Press Ctrl+C to stop...")
 	fmt.Println()
 
@@ -73,13 +131,36 @@ func runTrapListen(cmd *cobra.Command, args []string) error {
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
 	formatter := NewFormatter(outputFormat)
+	if logWriter != nil {
+		formatter = NewFormatterTo(outputFormat, logWriter)
+		logWriter.onOpen = formatter.fileHeader
+	}
+	formatter.Begin("trap")
+
+	listenerOpts := []snmp.TrapListenerOption{
+		snmp.WithListenAddress(listenAddress),
+		snmp.WithTrapCommunity(trapCommunity),
+		snmp.WithTrapNetwork(trapNetwork),
+	}
+	if spoolDir != "" {
+		listenerOpts = append(listenerOpts, snmp.WithTrapSpoolDir(spoolDir))
+	}
+	if securityName != "" {
+		listenerOpts = append(listenerOpts, snmp.WithTrapV3User(securityName,
+			parseAuthProtocol(authProtocol), authPassphrase,
+			parsePrivProtocol(privProtocol), privPassphrase))
+	}
 
 	listener := snmp.NewTrapListener(
 		func(trap *snmp.TrapPDU) {
 			formatter.FormatTrap(trap)
+			if forwarder != nil {
+				if err := forwarder.Forward(trap); err != nil {
+					printError("%v", err)
+				}
+			}
 		},
-		snmp.WithListenAddress(listenAddress),
-		snmp.WithTrapCommunity(trapCommunity),
+		listenerOpts...,
 	)
 
 	if err := listener.Start(ctx); err != nil {
@@ -88,7 +169,13 @@ func runTrapListen(cmd *cobra.Command, args []string) error {
 
 	// Wait for interrupt
 	<-sigCh
-	fmt.Println("\nShutting down...")
+	fmt.Fprintln(os.Stderr, "\nShutting down...")
 
-	return listener.Stop()
+	// Stop waits for the handler, so every trap is formatted before the
+	// output is flushed.
+	err := listener.Stop()
+	if cerr := formatter.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
@@ -15,10 +15,13 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -41,8 +44,11 @@ Examples:
   # Walk interface table
   edgeo-snmp walk -t 192.168.1.1 1.3.6.1.2.1.2.2
 
-  # Walk entire MIB
-  edgeo-snmp walk -t 192.168.1.1 1.3`,
+  # Walk entire MIB (asks for confirmation unless --yes is given)
+  edgeo-snmp walk -t 192.168.1.1 --yes 1.3
+
+  # Stop after 1000 variables or 30 seconds, whichever comes first
+  edgeo-snmp walk -t 192.168.1.1 --max-oids 1000 --max-duration 30s 1.3.6.1.2.1`,
 	Args: cobra.ExactArgs(1),
 	RunE: runWalk,
 }
@@ -68,8 +74,15 @@ Examples:
 var (
 	walkMaxRepetitions int
 	walkShowCount      bool
+	walkMaxOIDs        int
+	walkMaxDuration    time.Duration
+	walkYes            bool
 )
 
+// shortWalkRoot is the OID length below which a walk start is considered
+// close enough to the MIB root to ask for confirmation.
+const shortWalkRoot = 4
+
 func init() {
 	rootCmd.AddCommand(walkCmd)
 	rootCmd.AddCommand(bulkWalkCmd)
@@ -79,6 +92,37 @@ func init() {
 
 	bulkWalkCmd.Flags().IntVar(&walkMaxRepetitions, "max-repetitions", 10, "max-repetitions value")
 	bulkWalkCmd.Flags().BoolVar(&walkShowCount, "count", false, "show count of variables at the end")
+
+	for _, c := range []*cobra.Command{walkCmd, bulkWalkCmd} {
+		c.Flags().IntVar(&walkMaxOIDs, "max-oids", 0, "stop after this many variables (0 = unlimited)")
+		c.Flags().DurationVar(&walkMaxDuration, "max-duration", 0, "stop after this long (0 = unlimited)")
+		c.Flags().BoolVarP(&walkYes, "yes", "y", false, "do not ask for confirmation when walking near the MIB root")
+	}
+}
+
+// confirmWalk asks before walking from an OID so short that the walk would
+// dump most of the agent's MIB.
+func confirmWalk(rootOID snmp.OID) error {
+	if walkYes || len(rootOID) >= shortWalkRoot {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Walking from %s may retrieve the entire MIB. Continue? [y/N] ", rootOID)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return fmt.Errorf("walk cancelled")
+	}
+	return nil
+}
+
+// walkLimitOptions returns the request options for the --max-oids and
+// --max-duration flags.
+func walkLimitOptions() []snmp.RequestOption {
+	return []snmp.RequestOption{
+		snmp.WithRequestMaxWalkOIDs(walkMaxOIDs),
+		snmp.WithRequestMaxWalkDuration(walkMaxDuration),
+	}
 }
 
 func runWalk(cmd *cobra.Command, args []string) error {
@@ -91,6 +135,10 @@ func runWalk(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid OID: %w", err)
 	}
 
+	if err := confirmWalk(rootOID); err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -109,18 +157,24 @@ func runWalk(cmd *cobra.Command, args []string) error {
 	}
 	defer disconnectClient(client)
 
-	// Override max-repetitions if specified
-	if walkMaxRepetitions > 0 && client.Options().Version != snmp.Version1 {
-		client.Options().MaxRepetitions = walkMaxRepetitions
+	// Override max-repetitions if specified. A walk has a single varbind,
+	// so non-repeaters must be zero.
+	walkCtx := snmp.WithRequestOptions(ctx, walkLimitOptions()...)
+	if walkMaxRepetitions > 0 {
+		walkCtx = snmp.WithRequestOptions(walkCtx,
+			snmp.WithRequestMaxRepetitions(walkMaxRepetitions),
+			snmp.WithRequestNonRepeaters(0))
 	}
 
 	printVerbose("Walking from %s...", rootOID)
 	start := time.Now()
 
 	formatter := NewFormatter(outputFormat)
+	defer formatter.Close()
+	formatter.Begin("walk")
 	count := 0
 
-	err = client.WalkFunc(ctx, rootOID, func(v snmp.Variable) error {
+	err = client.WalkFunc(walkCtx, rootOID, func(v snmp.Variable) error {
 		formatter.FormatVariable(v)
 		count++
 		return nil
@@ -128,7 +182,9 @@ func runWalk(cmd *cobra.Command, args []string) error {
 
 	elapsed := time.Since(start)
 
-	if err != nil && ctx.Err() == nil {
+	if errors.Is(err, snmp.ErrWalkLimitExceeded) {
+		fmt.Fprintf(os.Stderr, "\nWalk stopped: %v\n", err)
+	} else if err != nil && ctx.Err() == nil {
 		return fmt.Errorf("walk failed: %w", err)
 	}
 
@@ -153,6 +209,10 @@ func runBulkWalk(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid OID: %w", err)
 	}
 
+	if err := confirmWalk(rootOID); err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -170,16 +230,19 @@ func runBulkWalk(cmd *cobra.Command, args []string) error {
 	}
 	defer disconnectClient(client)
 
-	// Set max-repetitions
-	client.Options().MaxRepetitions = walkMaxRepetitions
+	walkCtx := snmp.WithRequestOptions(ctx, append(walkLimitOptions(),
+		snmp.WithRequestMaxRepetitions(walkMaxRepetitions),
+		snmp.WithRequestNonRepeaters(0))...)
 
 	printVerbose("Bulk walking from %s (max-repetitions=%d)...", rootOID, walkMaxRepetitions)
 	start := time.Now()
 
 	formatter := NewFormatter(outputFormat)
+	defer formatter.Close()
+	formatter.Begin("bulkwalk")
 	count := 0
 
-	err = client.WalkFunc(ctx, rootOID, func(v snmp.Variable) error {
+	err = client.WalkFunc(walkCtx, rootOID, func(v snmp.Variable) error {
 		formatter.FormatVariable(v)
 		count++
 		return nil
@@ -187,7 +250,9 @@ func runBulkWalk(cmd *cobra.Command, args []string) error {
 
 	elapsed := time.Since(start)
 
-	if err != nil && ctx.Err() == nil {
+	if errors.Is(err, snmp.ErrWalkLimitExceeded) {
+		fmt.Fprintf(os.Stderr, "\nBulk walk stopped: %v\n", err)
+	} else if err != nil && ctx.Err() == nil {
 		return fmt.Errorf("bulk walk failed: %w", err)
 	}
 
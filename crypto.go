@@ -0,0 +1,111 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"fmt"
+	"hash"
+)
+
+// CryptoProvider supplies the cryptographic primitives of the User-based
+// Security Model, so that a FIPS-validated module or a hardware
+// implementation can replace the Go standard library. The USM logic, such
+// as key localization and IV construction, stays in this package.
+type CryptoProvider interface {
+	// Hash returns a new hash of the authentication protocol, used to
+	// derive and localize keys.
+	Hash(proto AuthProtocol) (hash.Hash, error)
+	// AuthDigest returns the untruncated HMAC of msg under key.
+	AuthDigest(proto AuthProtocol, key, msg []byte) ([]byte, error)
+	// Encrypt encrypts a scoped PDU: DES in CBC mode or AES in CFB mode,
+	// with the key and IV the protocol specifies.
+	Encrypt(proto PrivProtocol, key, iv, plaintext []byte) ([]byte, error)
+	// Decrypt reverses Encrypt.
+	Decrypt(proto PrivProtocol, key, iv, ciphertext []byte) ([]byte, error)
+}
+
+// StdCryptoProvider is the CryptoProvider backed by the Go standard
+// library. It is used by default.
+type StdCryptoProvider struct{}
+
+// Hash implements CryptoProvider.
+func (StdCryptoProvider) Hash(proto AuthProtocol) (hash.Hash, error) {
+	newHash := proto.newHash()
+	if newHash == nil {
+		return nil, fmt.Errorf("snmp: unsupported authentication protocol %s", proto)
+	}
+	return newHash(), nil
+}
+
+// AuthDigest implements CryptoProvider.
+func (StdCryptoProvider) AuthDigest(proto AuthProtocol, key, msg []byte) ([]byte, error) {
+	newHash := proto.newHash()
+	if newHash == nil {
+		return nil, fmt.Errorf("snmp: unsupported authentication protocol %s", proto)
+	}
+	mac := hmac.New(newHash, key)
+	mac.Write(msg)
+	return mac.Sum(nil), nil
+}
+
+// Encrypt implements CryptoProvider.
+func (StdCryptoProvider) Encrypt(proto PrivProtocol, key, iv, plaintext []byte) ([]byte, error) {
+	return stdCrypt(proto, key, iv, plaintext, true)
+}
+
+// Decrypt implements CryptoProvider.
+func (StdCryptoProvider) Decrypt(proto PrivProtocol, key, iv, ciphertext []byte) ([]byte, error) {
+	return stdCrypt(proto, key, iv, ciphertext, false)
+}
+
+func stdCrypt(proto PrivProtocol, key, iv, data []byte, encrypt bool) ([]byte, error) {
+	out := make([]byte, len(data))
+
+	switch proto {
+	case DES:
+		if len(data)%des.BlockSize != 0 {
+			return nil, fmt.Errorf("snmp: data is not a multiple of the DES block size")
+		}
+		block, err := des.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if encrypt {
+			cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+		} else {
+			cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+		}
+		return out, nil
+
+	case AES, AES192, AES256, AES192C, AES256C:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if encrypt {
+			cipher.NewCFBEncrypter(block, iv).XORKeyStream(out, data)
+		} else {
+			cipher.NewCFBDecrypter(block, iv).XORKeyStream(out, data)
+		}
+		return out, nil
+
+	default:
+		return nil, fmt.Errorf("snmp: unsupported privacy protocol %s", proto)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package snmp
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// setDontFragment sets the DF bit on a UDP socket by enabling path MTU
+// discovery, so datagrams larger than the path MTU fail with EMSGSIZE
+// instead of being fragmented.
+func setDontFragment(conn net.Conn) error {
+	udp, ok := conn.(*net.UDPConn)
+	if !ok {
+		return fmt.Errorf("snmp: don't-fragment requires a UDP socket")
+	}
+	raw, err := udp.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	level, opt, value := syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_DO
+	if addr, ok := udp.LocalAddr().(*net.UDPAddr); ok && addr.IP.To4() == nil {
+		level, opt, value = syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_DO
+	}
+
+	var serr error
+	if err := raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), level, opt, value)
+	}); err != nil {
+		return err
+	}
+	if serr != nil {
+		return fmt.Errorf("snmp: failed to set don't-fragment: %w", serr)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// setDontFragment is only implemented on Linux.
+func setDontFragment(conn net.Conn) error {
+	return fmt.Errorf("snmp: don't-fragment is not supported on %s: %w", runtime.GOOS, errors.ErrUnsupported)
+}
@@ -0,0 +1,370 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// v3MaxMessageSize is the msgMaxSize this client advertises: the largest
+// UDP payload it can receive.
+const v3MaxMessageSize = 65507
+
+// agentEngine is what the client knows of the authoritative SNMPv3 engine
+// of the agent.
+type agentEngine struct {
+	id    []byte
+	boots uint32
+	time  uint32
+	// synced is when boots and time were learned; the engine time
+	// advances with the local clock from there.
+	synced time.Time
+	// maxSize is the msgMaxSize the agent advertised, 0 if unknown.
+	maxSize int
+}
+
+// v3MinMessageSize is the smallest msgMaxSize an engine may advertise
+// (RFC 3412 section 6).
+const v3MinMessageSize = 484
+
+// clock returns the current estimate of the engine boots and time.
+func (e *agentEngine) clock() (uint32, uint32) {
+	if e.synced.IsZero() {
+		return e.boots, e.time
+	}
+	return e.boots, e.time + uint32(time.Since(e.synced)/time.Second)
+}
+
+// msgFlags returns the SNMPv3 message flags of the security level.
+func (l SecurityLevel) msgFlags() byte {
+	switch l {
+	case AuthPriv:
+		return msgFlagAuth | msgFlagPriv
+	case AuthNoPriv:
+		return msgFlagAuth
+	default:
+		return 0
+	}
+}
+
+// ensureEngine makes sure the authoritative engine ID of the agent is
+// known before an SNMPv3 request is sent. It takes the one set with
+// WithEngineID, or else discovers it with an unauthenticated request that
+// the agent answers with a report (RFC 3414 section 4).
+func (c *Client) ensureEngine(ctx context.Context) error {
+	if c.engineKnown() {
+		return nil
+	}
+
+	c.discoverMu.Lock()
+	defer c.discoverMu.Unlock()
+	if c.engineKnown() {
+		return nil
+	}
+
+	if len(c.opts.EngineID) > 0 {
+		// A stale ID or time is corrected from the report the agent
+		// answers the first request with.
+		c.engineMu.Lock()
+		c.engine = agentEngine{
+			id:     slices.Clone(c.opts.EngineID),
+			boots:  uint32(c.opts.EngineBoots),
+			time:   uint32(c.opts.EngineTime),
+			synced: time.Now(),
+		}
+		c.engineMu.Unlock()
+		return nil
+	}
+
+	c.logger.Debug("discovering SNMPv3 engine")
+	ctx = context.WithValue(ctx, discoveryKey{}, true)
+	if _, err := c.exchange(ctx, NewGetRequest(c.nextRequestID()), c.opts.Retries, 1); err != nil {
+		return fmt.Errorf("snmp: engine discovery failed: %w", err)
+	}
+	if !c.engineKnown() {
+		return fmt.Errorf("%w: agent did not report its engine ID", ErrUnknownEngineID)
+	}
+	return nil
+}
+
+func (c *Client) engineKnown() bool {
+	c.engineMu.Lock()
+	defer c.engineMu.Unlock()
+	return len(c.engine.id) > 0
+}
+
+// encodeV3 encodes an SNMPv3 message for pdu. The engine discovery request
+// goes out unauthenticated, with an empty engine ID and user name.
+func (c *Client) encodeV3(ctx context.Context, pdu *PDU) ([]byte, error) {
+	h := v3Header{MsgID: pdu.RequestID, MaxSize: v3MaxMessageSize}
+	if isDiscovery(ctx) {
+		h.Flags = msgFlagReportable
+	} else {
+		c.engineMu.Lock()
+		h.EngineID = c.engine.id
+		h.EngineBoots, h.EngineTime = c.engine.clock()
+		c.engineMu.Unlock()
+		h.UserName = c.opts.SecurityName
+		h.Flags = c.opts.SecurityLevel.msgFlags() | msgFlagReportable
+	}
+
+	scoped, err := c.scopedPDU(ctx, pdu, h.EngineID).Encode()
+	if err != nil {
+		return nil, err
+	}
+	return encodeV3Message(h, c.usm, scoped)
+}
+
+// decodeV3Response authenticates, decrypts and decodes an SNMPv3 response.
+// Reports may come unauthenticated, as the answer to discovery does; any
+// other response must be for the user and security level of the client.
+func (c *Client) decodeV3Response(data []byte) (*Message, *v3Message, error) {
+	msg, err := decodeV3Message(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var user *usmUser
+	if msg.UserName == c.opts.SecurityName {
+		user = c.usm
+	}
+	if err := msg.open(data, user); err != nil {
+		return nil, nil, err
+	}
+
+	pdu := msg.ScopedPDU.PDU
+	if pdu.Type != PDUReport &&
+		(msg.UserName != c.opts.SecurityName || msg.securityLevel() != c.opts.SecurityLevel) {
+		return nil, nil, fmt.Errorf("%w: response for user %q at %s, expected %q at %s", ErrAuthFailure,
+			msg.UserName, msg.securityLevel(), c.opts.SecurityName, c.opts.SecurityLevel)
+	}
+	return &Message{Version: Version3, PDU: pdu}, msg, nil
+}
+
+// observeEngine records the msgMaxSize of a message from the agent and
+// advances the agent engine clock from an authenticated one (RFC 3414
+// section 3.2 step 7b).
+func (c *Client) observeEngine(msg *v3Message) {
+	c.engineMu.Lock()
+	defer c.engineMu.Unlock()
+
+	if msg.MaxSize >= v3MinMessageSize {
+		c.engine.maxSize = msg.MaxSize
+	}
+
+	if msg.Flags&msgFlagAuth == 0 || !bytes.Equal(msg.EngineID, c.engine.id) {
+		return
+	}
+	boots, now := c.engine.clock()
+	if msg.EngineBoots > boots || (msg.EngineBoots == boots && msg.EngineTime > now) {
+		c.engine.boots, c.engine.time, c.engine.synced = msg.EngineBoots, msg.EngineTime, time.Now()
+	}
+}
+
+// learnEngine updates the agent engine from a report saying that a request
+// used the wrong engine ID or time: usmStatsUnknownEngineIDs carries the
+// engine ID, usmStatsNotInTimeWindows the engine boots and time. It reports
+// whether the request should be sent again.
+func (c *Client) learnEngine(msg *v3Message, report *PDU) bool {
+	if len(report.Variables) == 0 {
+		return false
+	}
+	oid := report.Variables[0].OID
+
+	c.engineMu.Lock()
+	defer c.engineMu.Unlock()
+
+	switch {
+	case oid.Equal(OIDUsmStatsUnknownEngineIDs):
+		if len(msg.EngineID) == 0 || bytes.Equal(msg.EngineID, c.engine.id) {
+			return false
+		}
+		c.engine.id = slices.Clone(msg.EngineID)
+	case oid.Equal(OIDUsmStatsNotInTimeWindows):
+		// Only an authenticated report can be trusted with the time
+		// (RFC 3414 section 3.2 step 7b).
+		if msg.Flags&msgFlagAuth == 0 || !bytes.Equal(msg.EngineID, c.engine.id) {
+			return false
+		}
+	default:
+		return false
+	}
+	c.engine.boots, c.engine.time, c.engine.synced = msg.EngineBoots, msg.EngineTime, time.Now()
+	return true
+}
+
+// bumpEngineBoots increments the snmpEngineBoots counter stored at path for
+// engineID and returns the new value.
+//
+// The file holds a single line "<engine ID in hex> <boots>", e.g.
+// "80001f8880c3a1d2e4 42". A missing file, or one recorded for a different
+// engine ID, starts again at 1 as RFC 3414 requires when the engine ID
+// changes. Once the counter reaches its maximum (2147483647) it stays there.
+// The file is replaced atomically so a crash never leaves it truncated.
+func bumpEngineBoots(path string, engineID []byte) (int32, error) {
+	var boots int64
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("snmp: malformed engine boots file %s", path)
+		}
+		if fields[0] == hex.EncodeToString(engineID) {
+			boots, err = strconv.ParseInt(fields[1], 10, 32)
+			if err != nil || boots < 0 {
+				return 0, fmt.Errorf("snmp: malformed engine boots file %s", path)
+			}
+		}
+	case errors.Is(err, os.ErrNotExist):
+	default:
+		return 0, fmt.Errorf("snmp: reading engine boots: %w", err)
+	}
+
+	if boots < math.MaxInt32 {
+		boots++
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return 0, fmt.Errorf("snmp: writing engine boots: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = fmt.Fprintf(tmp, "%s %d\n", hex.EncodeToString(engineID), boots)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("snmp: writing engine boots: %w", err)
+	}
+
+	return int32(boots), nil
+}
+
+// localEngine is the local SNMPv3 engine of the process: its
+// snmpEngineBoots and when they were incremented, which the local
+// snmpEngineTime counts from.
+type localEngine struct {
+	boots int32
+	start time.Time
+}
+
+// localEngines holds the local engine of each boots store and engine ID,
+// so that the counter is incremented once per process however many clients
+// share the store.
+var (
+	localEnginesMu sync.Mutex
+	localEngines   = make(map[string]*localEngine)
+)
+
+// loadLocalEngine returns the local engine for the boots store at path,
+// incrementing the stored counter the first time the process asks for it.
+// Without a store the engine boots stay at 0.
+func loadLocalEngine(path string, engineID []byte) (*localEngine, error) {
+	if path != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+	key := path + " " + hex.EncodeToString(engineID)
+
+	localEnginesMu.Lock()
+	defer localEnginesMu.Unlock()
+	if e, ok := localEngines[key]; ok {
+		return e, nil
+	}
+
+	e := &localEngine{start: time.Now()}
+	if path != "" {
+		boots, err := bumpEngineBoots(path, engineID)
+		if err != nil {
+			return nil, err
+		}
+		e.boots = boots
+	}
+	localEngines[key] = e
+	return e, nil
+}
+
+// localEngine returns the local engine of the client, loading it on first
+// use.
+func (c *Client) localEngine() (*localEngine, error) {
+	if e := c.local.Load(); e != nil {
+		return e, nil
+	}
+	e, err := loadLocalEngine(c.opts.EngineBootsStore, c.opts.LocalEngineID)
+	if err != nil {
+		return nil, err
+	}
+	c.local.Store(e)
+	return e, nil
+}
+
+// encodeV3Trap encodes an SNMPv3 trap. The sender of a trap is its
+// authoritative engine (RFC 3414 section 1.5.1), so the message carries
+// the local engine ID, boots and time rather than those of the agent.
+func (c *Client) encodeV3Trap(ctx context.Context, pdu *PDU) ([]byte, error) {
+	if len(c.opts.LocalEngineID) == 0 {
+		return nil, fmt.Errorf("%w: SNMPv3 traps require WithLocalEngineID", ErrUnknownEngineID)
+	}
+	local, err := c.localEngine()
+	if err != nil {
+		return nil, err
+	}
+
+	h := v3Header{
+		MsgID:       pdu.RequestID,
+		MaxSize:     v3MaxMessageSize,
+		Flags:       c.opts.SecurityLevel.msgFlags(),
+		EngineID:    c.opts.LocalEngineID,
+		EngineBoots: uint32(local.boots),
+		EngineTime:  uint32(time.Since(local.start) / time.Second),
+		UserName:    c.opts.SecurityName,
+	}
+	scoped, err := c.scopedPDU(ctx, pdu, h.EngineID).Encode()
+	if err == nil {
+		var data []byte
+		if data, err = encodeV3Message(h, c.usm, scoped); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to encode message: %w", err)
+}
+
+// EngineBoots returns the local snmpEngineBoots value loaded from the
+// engine boots store, or 0 if no store is configured or the client has
+// not connected yet.
+func (c *Client) EngineBoots() int32 {
+	if e := c.local.Load(); e != nil {
+		return e.boots
+	}
+	return 0
+}
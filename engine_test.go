@@ -0,0 +1,310 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testEngineID = []byte{0x80, 0x00, 0x1f, 0x88, 0x04, 't', 'e', 's', 't'}
+
+// sysDescrAgent answers every request with sysDescr.0.
+func sysDescrAgent(user *usmUser) *v3Agent {
+	return &v3Agent{
+		engineID: testEngineID,
+		boots:    3,
+		time:     1000,
+		user:     user,
+		handler: func(req *ScopedPDU) *PDU {
+			return &PDU{Type: PDUGetResponse, Variables: []Variable{
+				{OID: OIDSysDescr, Type: TypeOctetString, Value: []byte("test agent")},
+			}}
+		},
+	}
+}
+
+func TestV3Get(t *testing.T) {
+	tests := []struct {
+		name  string
+		level SecurityLevel
+		auth  AuthProtocol
+		priv  PrivProtocol
+	}{
+		{"noAuthNoPriv", NoAuthNoPriv, NoAuth, NoPriv},
+		{"authNoPriv MD5", AuthNoPriv, MD5, NoPriv},
+		{"authPriv SHA DES", AuthPriv, SHA, DES},
+		{"authPriv SHA-256 AES-256", AuthPriv, SHA256, AES256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := sysDescrAgent(newUSMUser("monitor", tt.auth, "authpass1", tt.priv, "privpass1"))
+			port := agent.start(t)
+			c := newTestClient(t, port,
+				WithVersion(Version3),
+				WithSecurityLevel(tt.level),
+				WithSecurityName("monitor"),
+				WithAuth(tt.auth, "authpass1"),
+				WithPrivacy(tt.priv, "privpass1"))
+
+			vars, err := c.Get(context.Background(), OIDSysDescr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(vars) != 1 || string(vars[0].AsBytes()) != "test agent" {
+				t.Fatalf("got %v", vars)
+			}
+
+			reqs := agent.received()
+			if len(reqs) != 2 {
+				t.Fatalf("agent received %d messages, want discovery and request", len(reqs))
+			}
+			if len(reqs[0].EngineID) != 0 || reqs[0].securityLevel() != NoAuthNoPriv {
+				t.Errorf("discovery sent engine ID %x at %s", reqs[0].EngineID, reqs[0].securityLevel())
+			}
+			if got := reqs[1].securityLevel(); got != tt.level {
+				t.Errorf("request sent at %s, want %s", got, tt.level)
+			}
+			if !bytes.Equal(reqs[1].EngineID, testEngineID) || reqs[1].EngineBoots != 3 {
+				t.Errorf("request sent for engine %x boots %d", reqs[1].EngineID, reqs[1].EngineBoots)
+			}
+		})
+	}
+}
+
+func TestV3ScopedPDUContext(t *testing.T) {
+	user := func() *usmUser { return newUSMUser("monitor", SHA, "authpass1", AES, "privpass1") }
+	secure := []Option{
+		WithVersion(Version3),
+		WithSecurityLevel(AuthPriv),
+		WithSecurityName("monitor"),
+		WithAuth(SHA, "authpass1"),
+		WithPrivacy(AES, "privpass1"),
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantEngine []byte
+		wantName   string
+	}{
+		{"default", nil, testEngineID, ""},
+		{"configured", []Option{WithContextName("vrfA"), WithContextEngineID("ctx-engine")}, []byte("ctx-engine"), "vrfA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := sysDescrAgent(user())
+			port := agent.start(t)
+			c := newTestClient(t, port, append(secure, tt.opts...)...)
+
+			if _, err := c.Get(context.Background(), OIDSysDescr); err != nil {
+				t.Fatal(err)
+			}
+
+			reqs := agent.received()
+			scoped := reqs[len(reqs)-1].ScopedPDU
+			if !bytes.Equal(scoped.ContextEngineID, tt.wantEngine) {
+				t.Errorf("contextEngineID = %q, want %q", scoped.ContextEngineID, tt.wantEngine)
+			}
+			if scoped.ContextName != tt.wantName {
+				t.Errorf("contextName = %q, want %q", scoped.ContextName, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestV3WrongPassphrase(t *testing.T) {
+	agent := sysDescrAgent(newUSMUser("monitor", SHA, "authpass1", NoPriv, ""))
+	port := agent.start(t)
+	c := newTestClient(t, port,
+		WithVersion(Version3),
+		WithSecurityLevel(AuthNoPriv),
+		WithSecurityName("monitor"),
+		WithAuth(SHA, "wrongpass"))
+
+	_, err := c.Get(context.Background(), OIDSysDescr)
+	if !errors.Is(err, ErrAuthFailure) {
+		t.Fatalf("Get with a wrong passphrase: %v, want ErrAuthFailure", err)
+	}
+}
+
+func TestV3ReportErrors(t *testing.T) {
+	tests := []struct {
+		oid    OID
+		reason string
+		want   error
+	}{
+		{OIDUsmStatsUnsupportedSecLevels, "unsupported security level", ErrAuthFailure},
+		{OIDUsmStatsNotInTimeWindows, "not in time window", ErrNotInTimeWindow},
+		{OIDUsmStatsUnknownUserNames, "unknown user name", ErrUnknownUserName},
+		{OIDUsmStatsUnknownEngineIDs, "unknown engine ID", ErrUnknownEngineID},
+		{OIDUsmStatsWrongDigests, "wrong digest", ErrAuthFailure},
+		{OIDUsmStatsDecryptionErrors, "decryption error", ErrPrivFailure},
+		{OIDSnmpUnknownContexts, "unknown context", ErrReport},
+		{MustParseOID("1.3.6.1.4.1.99999.1.0"), "unrecognized report", ErrReport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reason, func(t *testing.T) {
+			// The agent accepts the request and reports the counter
+			// in place of a response.
+			agent := sysDescrAgent(newUSMUser("monitor", SHA, "authpass1", AES, "privpass1"))
+			agent.handler = func(req *ScopedPDU) *PDU {
+				return &PDU{Type: PDUReport, Variables: []Variable{{OID: tt.oid, Type: TypeCounter32, Value: uint32(7)}}}
+			}
+			port := agent.start(t)
+			c := newTestClient(t, port,
+				WithVersion(Version3),
+				WithSecurityLevel(AuthPriv),
+				WithSecurityName("monitor"),
+				WithAuth(SHA, "authpass1"),
+				WithPrivacy(AES, "privpass1"))
+
+			_, err := c.Get(context.Background(), OIDSysDescr)
+			var rerr *ReportError
+			if !errors.As(err, &rerr) {
+				t.Fatalf("Get error = %v, want a *ReportError", err)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Get error = %v, want %v", err, tt.want)
+			}
+			if rerr.Reason != tt.reason || !rerr.Report.OID.Equal(tt.oid) || rerr.Report.Value != 7 {
+				t.Errorf("ReportError = %+v, want %s for %s = 7", rerr, tt.reason, tt.oid)
+			}
+		})
+	}
+}
+
+func TestV3ProvisionedEngineID(t *testing.T) {
+	secure := []Option{
+		WithVersion(Version3),
+		WithSecurityLevel(AuthNoPriv),
+		WithSecurityName("monitor"),
+		WithAuth(SHA, "authpass1"),
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		// sent is the number of messages the agent receives.
+		sent int
+	}{
+		{"current", []Option{WithEngineID(testEngineID), WithEngineTimeHint(3, 1000)}, 1},
+		{"stale time", []Option{WithEngineID(testEngineID), WithEngineTimeHint(2, 50)}, 2},
+		{"no time hint", []Option{WithEngineID(testEngineID)}, 2},
+		{"stale engine ID", []Option{WithEngineID([]byte("old-engine")), WithEngineTimeHint(3, 1000)}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := sysDescrAgent(newUSMUser("monitor", SHA, "authpass1", NoPriv, ""))
+			port := agent.start(t)
+			c := newTestClient(t, port, append(secure, tt.opts...)...)
+
+			if _, err := c.Get(context.Background(), OIDSysDescr); err != nil {
+				t.Fatal(err)
+			}
+
+			reqs := agent.received()
+			for _, msg := range reqs {
+				if len(msg.EngineID) == 0 {
+					t.Error("client sent a discovery request")
+				}
+			}
+			if len(reqs) != tt.sent {
+				t.Errorf("agent received %d messages, want %d", len(reqs), tt.sent)
+			}
+			last := reqs[len(reqs)-1]
+			if !bytes.Equal(last.EngineID, testEngineID) || last.EngineBoots != 3 {
+				t.Errorf("last request for engine %q boots %d", last.EngineID, last.EngineBoots)
+			}
+		})
+	}
+}
+
+func TestV3TrapLocalEngine(t *testing.T) {
+	pc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+
+	localID := []byte{0x80, 0x00, 0x1f, 0x88, 0x04, 'l', 'o', 'c', 'a', 'l'}
+	store := filepath.Join(t.TempDir(), "boots")
+	if err := os.WriteFile(store, []byte(hex.EncodeToString(localID)+" 41\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	user := newUSMUser("monitor", SHA, "authpass1", AES, "privpass1")
+
+	// Two clients in one process share the local engine, so the stored
+	// counter is bumped only once.
+	for i := 0; i < 2; i++ {
+		c := newTestClient(t, port,
+			WithVersion(Version3),
+			WithSecurityLevel(AuthPriv),
+			WithSecurityName("monitor"),
+			WithAuth(SHA, "authpass1"),
+			WithPrivacy(AES, "privpass1"),
+			WithLocalEngineID(localID),
+			WithEngineBootsStore(store))
+		if err := c.SendTrap(context.Background(), 100, OIDSnmpTraps.Append(1)); err != nil {
+			t.Fatal(err)
+		}
+
+		buf := make([]byte, 65535)
+		pc.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := pc.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := decodeV3Message(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := msg.open(buf[:n], user); err != nil {
+			t.Fatalf("receiver cannot open trap: %v", err)
+		}
+		if !bytes.Equal(msg.EngineID, localID) || msg.EngineBoots != 42 {
+			t.Errorf("trap %d sent for engine %x boots %d, want %x boots 42", i, msg.EngineID, msg.EngineBoots, localID)
+		}
+		if msg.Flags&msgFlagReportable != 0 {
+			t.Errorf("trap %d is reportable", i)
+		}
+		if got := msg.ScopedPDU.PDU.Type; got != PDUTrapV2 {
+			t.Errorf("trap %d sent as %s", i, got)
+		}
+	}
+
+	data, err := os.ReadFile(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := hex.EncodeToString(localID) + " 42\n"; string(data) != want {
+		t.Errorf("boots store holds %q, want %q", data, want)
+	}
+}
+
+func TestV3TrapRequiresLocalEngineID(t *testing.T) {
+	c := newTestClient(t, 9, WithVersion(Version3), WithSecurityName("monitor"))
+	if err := c.SendTrap(context.Background(), 100, OIDSnmpTraps.Append(1)); !errors.Is(err, ErrUnknownEngineID) {
+		t.Fatalf("SendTrap without a local engine ID: %v, want ErrUnknownEngineID", err)
+	}
+}
@@ -15,42 +15,166 @@
 package snmp
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // Standard errors.
 var (
-	ErrNotConnected     = errors.New("snmp: not connected")
-	ErrAlreadyConnected = errors.New("snmp: already connected")
-	ErrConnectionLost   = errors.New("snmp: connection lost")
-	ErrTimeout          = errors.New("snmp: operation timed out")
-	ErrInvalidOID       = errors.New("snmp: invalid OID")
-	ErrInvalidPacket    = errors.New("snmp: invalid packet")
-	ErrInvalidPDU       = errors.New("snmp: invalid PDU")
-	ErrInvalidType      = errors.New("snmp: invalid type")
-	ErrInvalidLength    = errors.New("snmp: invalid length")
-	ErrInvalidValue     = errors.New("snmp: invalid value")
-	ErrInvalidVersion   = errors.New("snmp: invalid SNMP version")
-	ErrInvalidCommunity = errors.New("snmp: invalid community string")
-	ErrPacketTooLarge   = errors.New("snmp: packet too large")
-	ErrMalformedPacket  = errors.New("snmp: malformed packet")
-	ErrNoResponse       = errors.New("snmp: no response received")
-	ErrEndOfMIB         = errors.New("snmp: end of MIB view")
-	ErrNoSuchObject     = errors.New("snmp: no such object")
-	ErrNoSuchInstance   = errors.New("snmp: no such instance")
+	ErrNotConnected      = errors.New("snmp: not connected")
+	ErrAlreadyConnected  = errors.New("snmp: already connected")
+	ErrConnectionLost    = errors.New("snmp: connection lost")
+	ErrConnectionRefused = errors.New("snmp: connection refused")
+	ErrTimeout           = errors.New("snmp: operation timed out")
+	ErrInvalidOID        = errors.New("snmp: invalid OID")
+	ErrInvalidPacket     = errors.New("snmp: invalid packet")
+	ErrInvalidPDU        = errors.New("snmp: invalid PDU")
+	ErrInvalidType       = errors.New("snmp: invalid type")
+	ErrInvalidLength     = errors.New("snmp: invalid length")
+	ErrInvalidValue      = errors.New("snmp: invalid value")
+	ErrInvalidVersion    = errors.New("snmp: invalid SNMP version")
+	ErrInvalidCommunity  = errors.New("snmp: invalid community string")
+	ErrPacketTooLarge    = errors.New("snmp: packet too large")
+	ErrMalformedPacket   = errors.New("snmp: malformed packet")
+	ErrNoResponse        = errors.New("snmp: no response received")
+	ErrEndOfMIB          = errors.New("snmp: end of MIB view")
+	ErrNoSuchObject      = errors.New("snmp: no such object")
+	ErrNoSuchInstance    = errors.New("snmp: no such instance")
 	ErrRequestIDMismatch = errors.New("snmp: request ID mismatch")
-	ErrAuthFailure      = errors.New("snmp: authentication failure")
-	ErrPrivFailure      = errors.New("snmp: privacy failure")
-	ErrClientClosed     = errors.New("snmp: client closed")
+	ErrAuthFailure       = errors.New("snmp: authentication failure")
+	ErrPrivFailure       = errors.New("snmp: privacy failure")
+	ErrClientClosed      = errors.New("snmp: client closed")
+	ErrGuardMismatch     = errors.New("snmp: guard mismatch")
+	ErrWalkLimitExceeded = errors.New("snmp: walk limit exceeded")
+	ErrOIDNotIncreasing  = errors.New("snmp: OID not increasing")
+	ErrUnknownEngineID   = errors.New("snmp: unknown engine ID")
+	ErrNotInTimeWindow   = errors.New("snmp: not in time window")
+	ErrUnknownUserName   = errors.New("snmp: unknown user name")
+	ErrSetNotApplied     = errors.New("snmp: set not applied")
+	ErrReport            = errors.New("snmp: report received")
+	// ErrRequestCanceled is returned by requests aborted with
+	// CancelPending. It wraps context.Canceled.
+	ErrRequestCanceled = fmt.Errorf("snmp: request canceled: %w", context.Canceled)
 )
 
+// Report is a counter carried in a report PDU, identifying why the agent
+// rejected a request (RFC 3412 section 7.1).
+type Report struct {
+	OID   OID
+	Value uint64
+}
+
+// ReportError is returned when the agent answers a request with a report
+// PDU. It unwraps to the sentinel for the reported cause, such as
+// ErrUnknownEngineID or ErrAuthFailure, or to ErrReport for other causes,
+// so errors.Is identifies the cause and errors.As exposes the report
+// itself.
+type ReportError struct {
+	Report Report
+	// Reason describes the cause, e.g. "wrong digest".
+	Reason string
+	err    error
+}
+
+// Error implements the error interface.
+func (e *ReportError) Error() string {
+	if e.Report.OID == nil {
+		return fmt.Sprintf("snmp: agent reported %s", e.Reason)
+	}
+	return fmt.Sprintf("snmp: agent reported %s (%s = %d)", e.Reason, e.Report.OID, e.Report.Value)
+}
+
+// Unwrap returns the sentinel error for the reported cause.
+func (e *ReportError) Unwrap() error {
+	return e.err
+}
+
+// reportCauses maps the usmStats counters of SNMP-USER-BASED-SM-MIB to
+// the cause they report.
+var reportCauses = []struct {
+	oid    OID
+	reason string
+	err    error
+}{
+	{OIDUsmStatsUnsupportedSecLevels, "unsupported security level", ErrAuthFailure},
+	{OIDUsmStatsNotInTimeWindows, "not in time window", ErrNotInTimeWindow},
+	{OIDUsmStatsUnknownUserNames, "unknown user name", ErrUnknownUserName},
+	{OIDUsmStatsUnknownEngineIDs, "unknown engine ID", ErrUnknownEngineID},
+	{OIDUsmStatsWrongDigests, "wrong digest", ErrAuthFailure},
+	{OIDUsmStatsDecryptionErrors, "decryption error", ErrPrivFailure},
+	{OIDSnmpUnknownSecurityModels, "unknown security model", ErrReport},
+	{OIDSnmpInvalidMsgs, "invalid message", ErrReport},
+	{OIDSnmpUnknownPDUHandlers, "unknown PDU handler", ErrReport},
+	{OIDSnmpUnavailableContexts, "unavailable context", ErrReport},
+	{OIDSnmpUnknownContexts, "unknown context", ErrReport},
+}
+
+// newReportError returns the ReportError for a report varbind, or nil if
+// the reported counter is not a known cause.
+func newReportError(v Variable) *ReportError {
+	for _, cause := range reportCauses {
+		if v.OID.Equal(cause.oid) {
+			value, _ := v.AsUint()
+			return &ReportError{
+				Report: Report{OID: v.OID, Value: value},
+				Reason: cause.reason,
+				err:    cause.err,
+			}
+		}
+	}
+	return nil
+}
+
+// unknownReportError returns the ReportError for a report carrying no
+// known cause, such as one from an SNMPv2c proxy.
+func unknownReportError(report *PDU) *ReportError {
+	rerr := &ReportError{Reason: "unrecognized report", err: ErrReport}
+	if len(report.Variables) > 0 {
+		v := report.Variables[0]
+		value, _ := v.AsUint()
+		rerr.Report = Report{OID: v.OID, Value: value}
+	}
+	return rerr
+}
+
+// VerifyMismatch is a variable whose read-back value differs from the
+// value set.
+type VerifyMismatch struct {
+	// Expected is the variable as sent in the SET.
+	Expected Variable
+	// Actual is the variable as read back.
+	Actual Variable
+}
+
+// VerifyError is returned by Set when WithVerifySet is enabled and some
+// variables do not hold the values set after the agent acknowledged the
+// SET. It unwraps to ErrSetNotApplied.
+type VerifyError struct {
+	Mismatches []VerifyMismatch
+}
+
+// Error implements the error interface.
+func (e *VerifyError) Error() string {
+	oids := make([]string, len(e.Mismatches))
+	for i, m := range e.Mismatches {
+		oids[i] = m.Expected.OID.String()
+	}
+	return fmt.Sprintf("snmp: set not applied to %s", strings.Join(oids, ", "))
+}
+
+// Unwrap returns ErrSetNotApplied.
+func (e *VerifyError) Unwrap() error {
+	return ErrSetNotApplied
+}
+
 // SNMPError represents an SNMP protocol error.
 type SNMPError struct {
-	Status      ErrorStatus
-	Index       int
-	Message     string
-	RequestOID  OID
+	Status     ErrorStatus
+	Index      int
+	Message    string
+	RequestOID OID
 }
 
 // Error implements the error interface.
@@ -93,6 +217,31 @@ func IsNoSuchInstance(err error) bool {
 	return errors.Is(err, ErrNoSuchInstance)
 }
 
+// IsTooBig returns true if the agent rejected the request as too big.
+func IsTooBig(err error) bool {
+	return hasErrorStatus(err, TooBig)
+}
+
+// IsNoSuchName returns true if the agent reported noSuchName (SNMPv1).
+func IsNoSuchName(err error) bool {
+	return hasErrorStatus(err, NoSuchName)
+}
+
+// IsReadOnly returns true if the agent reported readOnly.
+func IsReadOnly(err error) bool {
+	return hasErrorStatus(err, ReadOnly)
+}
+
+// IsAuthorizationError returns true if the agent reported authorizationError.
+func IsAuthorizationError(err error) bool {
+	return hasErrorStatus(err, AuthorizationError)
+}
+
+func hasErrorStatus(err error, status ErrorStatus) bool {
+	var snmpErr *SNMPError
+	return errors.As(err, &snmpErr) && snmpErr.Status == status
+}
+
 // ErrorStatusToError converts an error status to an error.
 func ErrorStatusToError(status ErrorStatus, index int, oid OID) error {
 	if status == NoError {
@@ -0,0 +1,151 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import "context"
+
+// Interface summarizes a network interface from the ifTable, merged with
+// the ifXTable (RFC 2863) when the agent implements it.
+type Interface struct {
+	Index       int
+	Name        string
+	Descr       string
+	Alias       string
+	Type        int
+	Speed       uint64 // bits per second
+	AdminStatus int
+	OperStatus  int
+	InOctets    uint64
+	OutOctets   uint64
+	// HighCapacity reports whether InOctets and OutOctets come from the
+	// 64-bit ifHCInOctets/ifHCOutOctets counters rather than the 32-bit
+	// ifInOctets/ifOutOctets, which wrap quickly on fast links.
+	HighCapacity bool
+}
+
+// ifTable, ifXTable and the columns used by Interfaces.
+var (
+	oidIfTable  = MustParseOID("1.3.6.1.2.1.2.2")
+	oidIfXTable = MustParseOID("1.3.6.1.2.1.31.1.1")
+)
+
+const (
+	ifColIndex       = 1
+	ifColDescr       = 2
+	ifColType        = 3
+	ifColSpeed       = 5
+	ifColAdminStatus = 7
+	ifColOperStatus  = 8
+	ifColInOctets    = 10
+	ifColOutOctets   = 16
+
+	ifXColName        = 1
+	ifXColHCInOctets  = 6
+	ifXColHCOutOctets = 10
+	ifXColHighSpeed   = 15
+	ifXColAlias       = 18
+)
+
+// ifSpeedSaturated is the ifSpeed value reported for interfaces faster
+// than a Gauge32 can express; ifHighSpeed holds the real speed.
+const ifSpeedSaturated = 4294967295
+
+// Interfaces returns the agent's interfaces ordered by ifIndex. Values
+// from the ifXTable are preferred where available, with a fallback to
+// the ifTable for agents that only implement the legacy table. An agent
+// without an ifTable yields an empty result.
+func (c *Client) Interfaces(ctx context.Context) ([]Interface, error) {
+	rows, err := c.WalkTable(ctx, oidIfTable,
+		ifColIndex, ifColDescr, ifColType, ifColSpeed, ifColAdminStatus,
+		ifColOperStatus, ifColInOctets, ifColOutOctets)
+	if err != nil {
+		return nil, err
+	}
+
+	xrows, err := c.WalkTable(ctx, oidIfXTable,
+		ifXColName, ifXColHCInOctets, ifXColHCOutOctets, ifXColHighSpeed, ifXColAlias)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := make(map[int]map[int]Variable, len(xrows))
+	for _, row := range xrows {
+		if len(row.Index) == 1 {
+			ext[row.Index[0]] = row.Columns
+		}
+	}
+
+	result := make([]Interface, 0, len(rows))
+	for _, row := range rows {
+		if len(row.Index) != 1 {
+			continue
+		}
+		iface := Interface{Index: row.Index[0]}
+		cols := row.Columns
+		xcols := ext[iface.Index]
+
+		if v, ok := cols[ifColDescr]; ok {
+			iface.Descr = v.AsString()
+		}
+		if v, ok := cols[ifColType]; ok {
+			if n, ok := v.AsInt(); ok {
+				iface.Type = int(n)
+			}
+		}
+		if v, ok := cols[ifColSpeed]; ok {
+			iface.Speed, _ = v.AsUint()
+		}
+		if v, ok := cols[ifColAdminStatus]; ok {
+			if n, ok := v.AsInt(); ok {
+				iface.AdminStatus = int(n)
+			}
+		}
+		if v, ok := cols[ifColOperStatus]; ok {
+			if n, ok := v.AsInt(); ok {
+				iface.OperStatus = int(n)
+			}
+		}
+
+		if v, ok := xcols[ifXColName]; ok {
+			iface.Name = v.AsString()
+		}
+		if v, ok := xcols[ifXColAlias]; ok {
+			iface.Alias = v.AsString()
+		}
+		if v, ok := xcols[ifXColHighSpeed]; ok && (iface.Speed == ifSpeedSaturated || iface.Speed == 0) {
+			if n, ok := v.AsUint(); ok && n > 0 {
+				iface.Speed = n * 1000000
+			}
+		}
+
+		in, inOK := xcols[ifXColHCInOctets]
+		out, outOK := xcols[ifXColHCOutOctets]
+		if inOK && outOK {
+			iface.InOctets, _ = in.AsUint()
+			iface.OutOctets, _ = out.AsUint()
+			iface.HighCapacity = true
+		} else {
+			if v, ok := cols[ifColInOctets]; ok {
+				iface.InOctets, _ = v.AsUint()
+			}
+			if v, ok := cols[ifColOutOctets]; ok {
+				iface.OutOctets, _ = v.AsUint()
+			}
+		}
+
+		result = append(result, iface)
+	}
+	return result, nil
+}
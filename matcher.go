@@ -0,0 +1,85 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"sort"
+	"sync"
+)
+
+// OIDMatcher matches OIDs against a set of subtrees. It is safe for
+// concurrent use.
+type OIDMatcher struct {
+	mu sync.RWMutex
+	// prefixes is kept sorted, and no prefix is a prefix of another, so
+	// the only candidate match for an OID is the greatest prefix <= OID.
+	prefixes []OID
+}
+
+// NewOIDMatcher creates a matcher for the given subtrees.
+func NewOIDMatcher(prefixes ...OID) *OIDMatcher {
+	m := &OIDMatcher{}
+	for _, p := range prefixes {
+		m.Add(p)
+	}
+	return m
+}
+
+// Add adds the subtree rooted at prefix.
+func (m *OIDMatcher) Add(prefix OID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.matches(prefix) {
+		return
+	}
+
+	// Drop subtrees covered by the new prefix
+	kept := m.prefixes[:0]
+	for _, p := range m.prefixes {
+		if !p.HasPrefix(prefix) {
+			kept = append(kept, p)
+		}
+	}
+
+	i := sort.Search(len(kept), func(i int) bool {
+		return kept[i].Compare(prefix) > 0
+	})
+	kept = append(kept, nil)
+	copy(kept[i+1:], kept[i:])
+	kept[i] = prefix.Copy()
+	m.prefixes = kept
+}
+
+// Matches reports whether oid lies within one of the subtrees.
+func (m *OIDMatcher) Matches(oid OID) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.matches(oid)
+}
+
+func (m *OIDMatcher) matches(oid OID) bool {
+	i := sort.Search(len(m.prefixes), func(i int) bool {
+		return m.prefixes[i].Compare(oid) > 0
+	})
+	return i > 0 && oid.HasPrefix(m.prefixes[i-1])
+}
+
+// Len returns the number of subtrees in the matcher.
+func (m *OIDMatcher) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.prefixes)
+}
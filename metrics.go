@@ -15,6 +15,7 @@
 package snmp
 
 import (
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -71,13 +72,41 @@ type LatencyHistogram struct {
 	bounds  []int64
 }
 
+// defaultLatencyBounds are the default bucket upper bounds in milliseconds.
+var defaultLatencyBounds = []int64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
 // NewLatencyHistogram creates a new latency histogram.
 func NewLatencyHistogram() *LatencyHistogram {
+	h, _ := NewLatencyHistogramWithBounds(defaultLatencyBounds)
+	return h
+}
+
+// NewLatencyHistogramWithBounds creates a latency histogram with one bucket
+// per upper bound in milliseconds, plus an overflow bucket. The bounds must
+// be strictly ascending.
+func NewLatencyHistogramWithBounds(bounds []int64) (*LatencyHistogram, error) {
+	if err := validateLatencyBounds(bounds); err != nil {
+		return nil, err
+	}
 	return &LatencyHistogram{
 		min:     -1,
-		bounds:  []int64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000},
-		buckets: make([]int64, 13), // 12 buckets + overflow
+		bounds:  append([]int64(nil), bounds...),
+		buckets: make([]int64, len(bounds)+1),
+	}, nil
+}
+
+// validateLatencyBounds checks that histogram bounds are non-empty and
+// strictly ascending.
+func validateLatencyBounds(bounds []int64) error {
+	if len(bounds) == 0 {
+		return fmt.Errorf("snmp: latency histogram needs at least one bucket bound")
+	}
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			return fmt.Errorf("snmp: latency bucket bounds must be strictly ascending, got %d after %d", bounds[i], bounds[i-1])
+		}
 	}
+	return nil
 }
 
 // Observe records a latency observation in milliseconds.
@@ -116,10 +145,12 @@ func (h *LatencyHistogram) Stats() LatencyStats {
 	defer h.mu.RUnlock()
 
 	stats := LatencyStats{
-		Count: h.count,
-		Sum:   h.sum,
-		Min:   h.min,
-		Max:   h.max,
+		Count:   h.count,
+		Sum:     h.sum,
+		Min:     h.min,
+		Max:     h.max,
+		Bounds:  append([]int64(nil), h.bounds...),
+		Buckets: append([]int64(nil), h.buckets...),
 	}
 
 	if h.count > 0 {
@@ -129,6 +160,49 @@ func (h *LatencyHistogram) Stats() LatencyStats {
 	return stats
 }
 
+// Percentile estimates the q-th quantile (0 < q <= 1) of the observed
+// latencies in milliseconds. The estimate is the upper bound of the bucket
+// containing the quantile, or the maximum for the overflow bucket.
+func (h *LatencyHistogram) Percentile(q float64) int64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := int64(q * float64(h.count))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var cumulative int64
+	for i, n := range h.buckets {
+		cumulative += n
+		if cumulative >= rank {
+			if i < len(h.bounds) && h.bounds[i] < h.max {
+				return h.bounds[i]
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+// Reset clears all observations.
+func (h *LatencyHistogram) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.count = 0
+	h.sum = 0
+	h.min = -1
+	h.max = 0
+	for i := range h.buckets {
+		h.buckets[i] = 0
+	}
+}
+
 // LatencyStats contains latency statistics.
 type LatencyStats struct {
 	Count int64
@@ -136,16 +210,24 @@ type LatencyStats struct {
 	Min   int64
 	Max   int64
 	Avg   float64
+	// Bounds are the bucket upper bounds in milliseconds.
+	Bounds []int64
+	// Buckets are the observation counts per bucket (not cumulative): one
+	// per bound, then the overflow bucket.
+	Buckets []int64
 }
 
 // Metrics contains all client metrics.
 type Metrics struct {
 	// Request metrics
-	RequestsSent     Counter
-	ResponsesReceived Counter
-	Timeouts         Counter
-	Retries          Counter
-	Errors           Counter
+	RequestsSent       Counter
+	ResponsesReceived  Counter
+	Timeouts           Counter
+	Retries            Counter
+	GenErrRetries      Counter
+	Errors             Counter
+	ResponseMismatches Counter
+	ResponsesRejected  Counter
 
 	// PDU type metrics
 	GetRequests     Counter
@@ -153,9 +235,15 @@ type Metrics struct {
 	GetBulkRequests Counter
 	SetRequests     Counter
 	WalkRequests    Counter
+	InformRequests  Counter
+
+	// Walk metrics
+	WalkAnomalies Counter
 
 	// Trap metrics
 	TrapsReceived Counter
+	TrapsSent     Counter
+	TrapsSpooled  Counter
 
 	// Variable binding metrics
 	VarbindsSent     Counter
@@ -171,6 +259,10 @@ type Metrics struct {
 
 	// Start time
 	StartTime time.Time
+
+	// mu makes Reset exclusive with Snapshot, so a snapshot never mixes
+	// values from before and after a reset.
+	mu sync.RWMutex
 }
 
 // NewMetrics creates a new Metrics instance.
@@ -183,18 +275,28 @@ func NewMetrics() *Metrics {
 
 // Snapshot returns a copy of the current metrics.
 func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	return MetricsSnapshot{
 		RequestsSent:       m.RequestsSent.Value(),
 		ResponsesReceived:  m.ResponsesReceived.Value(),
 		Timeouts:           m.Timeouts.Value(),
 		Retries:            m.Retries.Value(),
+		GenErrRetries:      m.GenErrRetries.Value(),
 		Errors:             m.Errors.Value(),
 		GetRequests:        m.GetRequests.Value(),
 		GetNextRequests:    m.GetNextRequests.Value(),
 		GetBulkRequests:    m.GetBulkRequests.Value(),
 		SetRequests:        m.SetRequests.Value(),
 		WalkRequests:       m.WalkRequests.Value(),
+		InformRequests:     m.InformRequests.Value(),
+		WalkAnomalies:      m.WalkAnomalies.Value(),
+		ResponseMismatches: m.ResponseMismatches.Value(),
+		ResponsesRejected:  m.ResponsesRejected.Value(),
 		TrapsReceived:      m.TrapsReceived.Value(),
+		TrapsSent:          m.TrapsSent.Value(),
+		TrapsSpooled:       m.TrapsSpooled.Value(),
 		VarbindsSent:       m.VarbindsSent.Value(),
 		VarbindsReceived:   m.VarbindsReceived.Value(),
 		RequestLatency:     m.RequestLatency.Stats(),
@@ -211,13 +313,20 @@ type MetricsSnapshot struct {
 	ResponsesReceived  int64
 	Timeouts           int64
 	Retries            int64
+	GenErrRetries      int64
 	Errors             int64
 	GetRequests        int64
 	GetNextRequests    int64
 	GetBulkRequests    int64
 	SetRequests        int64
 	WalkRequests       int64
+	InformRequests     int64
+	WalkAnomalies      int64
+	ResponseMismatches int64
+	ResponsesRejected  int64
 	TrapsReceived      int64
+	TrapsSent          int64
+	TrapsSpooled       int64
 	VarbindsSent       int64
 	VarbindsReceived   int64
 	RequestLatency     LatencyStats
@@ -227,22 +336,34 @@ type MetricsSnapshot struct {
 	Uptime             time.Duration
 }
 
-// Reset resets all metrics.
+// Reset resets all metrics. It is safe to call while requests are in
+// flight; the latency histogram is cleared in place rather than replaced,
+// so concurrent observations are never lost to a stale histogram.
 func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.RequestsSent.Reset()
 	m.ResponsesReceived.Reset()
 	m.Timeouts.Reset()
 	m.Retries.Reset()
+	m.GenErrRetries.Reset()
 	m.Errors.Reset()
 	m.GetRequests.Reset()
 	m.GetNextRequests.Reset()
 	m.GetBulkRequests.Reset()
 	m.SetRequests.Reset()
 	m.WalkRequests.Reset()
+	m.InformRequests.Reset()
+	m.WalkAnomalies.Reset()
+	m.ResponseMismatches.Reset()
+	m.ResponsesRejected.Reset()
 	m.TrapsReceived.Reset()
+	m.TrapsSent.Reset()
+	m.TrapsSpooled.Reset()
 	m.VarbindsSent.Reset()
 	m.VarbindsReceived.Reset()
-	m.RequestLatency = NewLatencyHistogram()
+	m.RequestLatency.Reset()
 	m.ConnectionAttempts.Reset()
 	m.ActiveConnections.Set(0)
 	m.ReconnectAttempts.Reset()
@@ -0,0 +1,71 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMetricsResetDuringRequests(t *testing.T) {
+	c := newTestClient(t, mockAgent(t, sysDescrHandler))
+	m := c.Metrics()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if _, err := c.Get(context.Background(), OIDSysDescr); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			m.Reset()
+			// Every observed latency belongs to a counted response,
+			// give or take the four requests in flight at the reset.
+			s := m.Snapshot()
+			if s.RequestLatency.Count > s.ResponsesReceived+4 {
+				t.Errorf("%d latencies observed for %d responses", s.RequestLatency.Count, s.ResponsesReceived)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	// Observations made after a reset land in the histogram the
+	// snapshot reads.
+	m.Reset()
+	for range 5 {
+		if _, err := c.Get(context.Background(), OIDSysDescr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := m.Snapshot()
+	if s.RequestLatency.Count != 5 || s.GetRequests != 5 {
+		t.Errorf("after reset: %d latencies, %d GETs, want 5 each", s.RequestLatency.Count, s.GetRequests)
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockAgent is an SNMPv1/v2c agent on a loopback port that answers each
+// request with handler; a nil reply sends nothing.
+func mockAgent(t *testing.T, handler func(req *Message) *Message) int {
+	return rawAgent(t, func(req *Message) []byte {
+		resp := handler(req)
+		if resp == nil {
+			return nil
+		}
+		data, err := resp.Encode()
+		if err != nil {
+			return nil
+		}
+		return data
+	})
+}
+
+// rawAgent is like mockAgent but handler returns the response datagram,
+// so tests can send packets the encoder would not produce.
+func rawAgent(t *testing.T, handler func(req *Message) []byte) int {
+	t.Helper()
+	pc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req, err := DecodeMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			if data := handler(req); data != nil {
+				pc.WriteToUDP(data, addr)
+			}
+		}
+	}()
+	return pc.LocalAddr().(*net.UDPAddr).Port
+}
+
+// reply returns a response to req carrying vars.
+func reply(req *Message, vars ...Variable) *Message {
+	return &Message{
+		Version:   req.Version,
+		Community: req.Community,
+		PDU:       &PDU{Type: PDUGetResponse, RequestID: req.PDU.RequestID, Variables: vars},
+	}
+}
+
+// newTestClient returns a client connected to the mock agent on port,
+// with a short timeout and no retries.
+func newTestClient(t *testing.T, port int, opts ...Option) *Client {
+	t.Helper()
+	opts = append([]Option{
+		WithTarget("127.0.0.1"),
+		WithPort(port),
+		WithTimeout(500 * time.Millisecond),
+		WithRetries(0),
+		WithAutoReconnect(false),
+	}, opts...)
+	c := NewClient(opts...)
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// v3Agent is a mock SNMPv3 agent with a single USM user. Like a real agent
+// it answers requests for another engine ID, such as discovery requests,
+// with a usmStatsUnknownEngineIDs report and authenticated requests outside
+// its time window with a usmStatsNotInTimeWindows report. Other requests
+// are answered with handler.
+type v3Agent struct {
+	engineID []byte
+	boots    uint32
+	time     uint32
+	maxSize  int
+	user     *usmUser
+	handler  func(req *ScopedPDU) *PDU
+
+	mu       sync.Mutex
+	requests []*v3Message
+}
+
+// start serves the agent on a loopback port and returns the port.
+func (a *v3Agent) start(t *testing.T) int {
+	t.Helper()
+	pc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			data := append([]byte(nil), buf[:n]...)
+			if resp := a.serve(t, data); resp != nil {
+				pc.WriteToUDP(resp, addr)
+			}
+		}
+	}()
+	return pc.LocalAddr().(*net.UDPAddr).Port
+}
+
+func (a *v3Agent) serve(t *testing.T, data []byte) []byte {
+	msg, err := decodeV3Message(data)
+	if err != nil {
+		t.Errorf("agent: %v", err)
+		return nil
+	}
+	var user *usmUser
+	if msg.UserName == a.user.name {
+		user = a.user
+	}
+	openErr := msg.open(data, user)
+
+	a.mu.Lock()
+	a.requests = append(a.requests, msg)
+	a.mu.Unlock()
+
+	h := v3Header{
+		MsgID:       msg.MsgID,
+		MaxSize:     a.maxSize,
+		EngineID:    a.engineID,
+		EngineBoots: a.boots,
+		EngineTime:  a.time,
+		UserName:    msg.UserName,
+	}
+	if h.MaxSize == 0 {
+		h.MaxSize = v3MaxMessageSize
+	}
+
+	// Reports go out unauthenticated, with the request ID when it can
+	// be read and 0 otherwise.
+	var resp *PDU
+	report := func(oid OID) *PDU {
+		pdu := &PDU{Type: PDUReport, Variables: []Variable{{OID: oid, Type: TypeCounter32, Value: uint32(1)}}}
+		if msg.ScopedPDU != nil {
+			pdu.RequestID = msg.ScopedPDU.PDU.RequestID
+		}
+		return pdu
+	}
+	switch {
+	case !bytes.Equal(msg.EngineID, a.engineID):
+		resp = report(OIDUsmStatsUnknownEngineIDs)
+	case openErr != nil:
+		resp = report(OIDUsmStatsWrongDigests)
+	case msg.Flags&msgFlagAuth != 0 && (msg.EngineBoots != a.boots || absDiff(msg.EngineTime, a.time) > 150):
+		// RFC 3414 section 3.2 step 7: authenticated, so the client
+		// can trust the clock it carries.
+		resp = report(OIDUsmStatsNotInTimeWindows)
+		h.Flags = msgFlagAuth
+	default:
+		req := msg.ScopedPDU.PDU
+		resp = a.handler(msg.ScopedPDU)
+		if resp == nil {
+			return nil
+		}
+		resp.RequestID = req.RequestID
+		h.Flags = msg.Flags &^ msgFlagReportable
+	}
+
+	var contextName string
+	if msg.ScopedPDU != nil {
+		contextName = msg.ScopedPDU.ContextName
+	}
+	scoped, err := (&ScopedPDU{ContextEngineID: a.engineID, ContextName: contextName, PDU: resp}).Encode()
+	if err != nil {
+		t.Errorf("agent: %v", err)
+		return nil
+	}
+	out, err := encodeV3Message(h, a.user, scoped)
+	if err != nil {
+		t.Errorf("agent: %v", err)
+		return nil
+	}
+	return out
+}
+
+// received returns the requests the agent decoded so far.
+func (a *v3Agent) received() []*v3Message {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]*v3Message(nil), a.requests...)
+}
+
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// dropConnection closes the client's socket under its read loop, which
+// then handles the connection as lost, and waits until it has. The client
+// may already have reconnected by the time it returns.
+func dropConnection(t *testing.T, c *Client) {
+	t.Helper()
+	gen := c.generation.Load()
+	c.conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for c.State() == StateConnected && c.generation.Load() == gen {
+		if time.Now().After(deadline) {
+			t.Fatal("connection loss not detected")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// mibHandler answers GET, GETNEXT and GETBULK requests from mib, which
+// must be sorted by OID. A GET for a missing object fails with noSuchName and
+// a walk past the last object returns an OID outside the MIB-2 tree.
+func mibHandler(mib ...Variable) func(req *Message) *Message {
+	end := Variable{OID: MustParseOID("1.3.6.2"), Type: TypeInteger, Value: 0}
+	next := func(oid OID) Variable {
+		for _, v := range mib {
+			if v.OID.Compare(oid) > 0 {
+				return v
+			}
+		}
+		return end
+	}
+	return func(req *Message) *Message {
+		var vars []Variable
+		switch req.PDU.Type {
+		case PDUGetRequest:
+		get:
+			for i, want := range req.PDU.Variables {
+				for _, v := range mib {
+					if v.OID.Equal(want.OID) {
+						vars = append(vars, v)
+						continue get
+					}
+				}
+				resp := reply(req, req.PDU.Variables...)
+				resp.PDU.ErrorStatus = NoSuchName
+				resp.PDU.ErrorIndex = i + 1
+				return resp
+			}
+		case PDUGetNextRequest:
+			for _, want := range req.PDU.Variables {
+				vars = append(vars, next(want.OID))
+			}
+		case PDUGetBulkRequest:
+			for _, want := range req.PDU.Variables {
+				v := Variable{OID: want.OID}
+				for range max(req.PDU.MaxRepetitions, 1) {
+					v = next(v.OID)
+					vars = append(vars, v)
+				}
+			}
+		}
+		return reply(req, vars...)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"slices"
+	"sync"
+)
+
+// oidNames is the registry behind RegisterOIDName and LookupOIDName, keyed
+// by dotted OID. oids indexes it by name for LookupOID, listing the OIDs
+// of each name in registration order.
+var oidNames = struct {
+	sync.RWMutex
+	names map[string]string
+	oids  map[string][]string
+}{
+	names: map[string]string{
+		// MIB-2 objects
+		"1.3.6.1.2.1.1.1":         "sysDescr",
+		"1.3.6.1.2.1.1.2":         "sysObjectID",
+		"1.3.6.1.2.1.1.3":         "sysUpTime",
+		"1.3.6.1.2.1.1.4":         "sysContact",
+		"1.3.6.1.2.1.1.5":         "sysName",
+		"1.3.6.1.2.1.1.6":         "sysLocation",
+		"1.3.6.1.2.1.1.7":         "sysServices",
+		"1.3.6.1.2.1.1.9.1.2":     "sysORID",
+		"1.3.6.1.2.1.1.9.1.3":     "sysORDescr",
+		"1.3.6.1.2.1.1.9.1.4":     "sysORUpTime",
+		"1.3.6.1.2.1.2.1":         "ifNumber",
+		"1.3.6.1.2.1.2.2.1.1":     "ifIndex",
+		"1.3.6.1.2.1.2.2.1.2":     "ifDescr",
+		"1.3.6.1.2.1.2.2.1.3":     "ifType",
+		"1.3.6.1.2.1.2.2.1.4":     "ifMtu",
+		"1.3.6.1.2.1.2.2.1.5":     "ifSpeed",
+		"1.3.6.1.2.1.2.2.1.6":     "ifPhysAddress",
+		"1.3.6.1.2.1.2.2.1.7":     "ifAdminStatus",
+		"1.3.6.1.2.1.2.2.1.8":     "ifOperStatus",
+		"1.3.6.1.2.1.2.2.1.10":    "ifInOctets",
+		"1.3.6.1.2.1.2.2.1.16":    "ifOutOctets",
+		"1.3.6.1.2.1.31.1.1.1.1":  "ifName",
+		"1.3.6.1.2.1.31.1.1.1.6":  "ifHCInOctets",
+		"1.3.6.1.2.1.31.1.1.1.10": "ifHCOutOctets",
+		"1.3.6.1.2.1.31.1.1.1.15": "ifHighSpeed",
+		"1.3.6.1.2.1.31.1.1.1.18": "ifAlias",
+		"1.3.6.1.6.3.1.1.4.1":     "snmpTrapOID",
+		"1.3.6.1.6.3.1.1.4.3":     "snmpTrapEnterprise",
+
+		// Vendor enterprise numbers
+		"1.3.6.1.4.1":       "enterprises",
+		"1.3.6.1.4.1.2":     "ibm",
+		"1.3.6.1.4.1.9":     "cisco",
+		"1.3.6.1.4.1.11":    "hp",
+		"1.3.6.1.4.1.43":    "a3Com",
+		"1.3.6.1.4.1.171":   "dlink",
+		"1.3.6.1.4.1.232":   "compaq",
+		"1.3.6.1.4.1.311":   "microsoft",
+		"1.3.6.1.4.1.674":   "dell",
+		"1.3.6.1.4.1.789":   "netapp",
+		"1.3.6.1.4.1.1588":  "brocade",
+		"1.3.6.1.4.1.1916":  "extremeNetworks",
+		"1.3.6.1.4.1.2011":  "huawei",
+		"1.3.6.1.4.1.2021":  "ucdavis",
+		"1.3.6.1.4.1.2636":  "juniper",
+		"1.3.6.1.4.1.3375":  "f5",
+		"1.3.6.1.4.1.4526":  "netgear",
+		"1.3.6.1.4.1.6527":  "nokia",
+		"1.3.6.1.4.1.6876":  "vmware",
+		"1.3.6.1.4.1.8072":  "netSnmp",
+		"1.3.6.1.4.1.12356": "fortinet",
+		"1.3.6.1.4.1.14988": "mikrotik",
+		"1.3.6.1.4.1.25461": "paloAltoNetworks",
+		"1.3.6.1.4.1.30065": "arista",
+		"1.3.6.1.4.1.41112": "ubiquiti",
+	},
+	oids: make(map[string][]string),
+}
+
+func init() {
+	for oid, name := range oidNames.names {
+		oidNames.oids[name] = append(oidNames.oids[name], oid)
+	}
+}
+
+// RegisterOIDName names the subtree under oid for LookupOIDName, replacing
+// any name registered for it before. The registry comes seeded with common
+// MIB-2 objects, so that 1.3.6.1.2.1.1.1.0 reads as sysDescr.0, and with
+// the enterprise numbers of common vendors, so that a sysObjectID reads as
+// e.g. cisco.1.1208; register product OIDs or private subtrees to extend
+// it. It is safe to call concurrently with lookups.
+//
+// A name registered for several OIDs resolves in LookupOID to the one
+// registered last.
+func RegisterOIDName(oid OID, name string) {
+	oidNames.Lock()
+	defer oidNames.Unlock()
+
+	key := oid.String()
+	if old, ok := oidNames.names[key]; ok {
+		oids := slices.DeleteFunc(oidNames.oids[old], func(o string) bool { return o == key })
+		if len(oids) == 0 {
+			delete(oidNames.oids, old)
+		} else {
+			oidNames.oids[old] = oids
+		}
+	}
+	oidNames.names[key] = name
+	oidNames.oids[name] = append(oidNames.oids[name], key)
+}
+
+// LookupOIDName returns the name of the longest registered prefix of oid,
+// followed by the remaining sub-identifiers: "cisco.1.1208" for
+// 1.3.6.1.4.1.9.1.1208. It returns false if no prefix of oid is
+// registered.
+func LookupOIDName(oid OID) (string, bool) {
+	oidNames.RLock()
+	defer oidNames.RUnlock()
+
+	for n := len(oid); n > 0; n-- {
+		name, ok := oidNames.names[oid[:n].String()]
+		if !ok {
+			continue
+		}
+		if n == len(oid) {
+			return name, true
+		}
+		return name + "." + oid[n:].String(), true
+	}
+	return "", false
+}
+
+// LookupOID returns the OID registered under name, the reverse of
+// LookupOIDName for a whole name such as "sysDescr" or "cisco".
+func LookupOID(name string) (OID, bool) {
+	oidNames.RLock()
+	defer oidNames.RUnlock()
+
+	oids := oidNames.oids[name]
+	if len(oids) == 0 {
+		return nil, false
+	}
+	return MustParseOID(oids[len(oids)-1]), true
+}
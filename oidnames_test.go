@@ -0,0 +1,126 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+// restoreOIDNames restores the OID name registry when the test ends, so
+// registrations do not leak into other tests.
+func restoreOIDNames(t *testing.T) {
+	oidNames.RLock()
+	names := maps.Clone(oidNames.names)
+	oids := make(map[string][]string, len(oidNames.oids))
+	for name, list := range oidNames.oids {
+		oids[name] = slices.Clone(list)
+	}
+	oidNames.RUnlock()
+
+	t.Cleanup(func() {
+		oidNames.Lock()
+		oidNames.names, oidNames.oids = names, oids
+		oidNames.Unlock()
+	})
+}
+
+func TestLookupOIDName(t *testing.T) {
+	tests := []struct {
+		oid  string
+		want string
+	}{
+		{"1.3.6.1.2.1.1.1.0", "sysDescr.0"},
+		{"1.3.6.1.2.1.2.2.1.10.3", "ifInOctets.3"},
+		{"1.3.6.1.4.1.9.1.1208", "cisco.1.1208"},
+		{"1.3.6.1.4.1.9", "cisco"},
+	}
+	for _, tt := range tests {
+		got, ok := LookupOIDName(MustParseOID(tt.oid))
+		if !ok || got != tt.want {
+			t.Errorf("LookupOIDName(%s) = %q, %v, want %q", tt.oid, got, ok, tt.want)
+		}
+	}
+	if name, ok := LookupOIDName(MustParseOID("1.3.6.1.2.1.4.1.0")); ok {
+		t.Errorf("unregistered OID named %q", name)
+	}
+}
+
+func TestLookupOID(t *testing.T) {
+	oid, ok := LookupOID("sysDescr")
+	if !ok || !oid.Equal(MustParseOID("1.3.6.1.2.1.1.1")) {
+		t.Errorf("LookupOID(sysDescr) = %s, %v", oid, ok)
+	}
+	if _, ok := LookupOID("noSuchName"); ok {
+		t.Error("LookupOID found an unregistered name")
+	}
+}
+
+func TestRegisterOIDName(t *testing.T) {
+	restoreOIDNames(t)
+	RegisterOIDName(MustParseOID("1.3.6.1.4.1.9.1.1208"), "catalyst2960")
+
+	tests := []struct {
+		oid  string
+		want string
+	}{
+		{"1.3.6.1.4.1.9.1.1208", "catalyst2960"},
+		{"1.3.6.1.4.1.9.1.1208.5", "catalyst2960.5"},
+		{"1.3.6.1.4.1.9.1.1209", "cisco.1.1209"},
+	}
+	for _, tt := range tests {
+		got, ok := LookupOIDName(MustParseOID(tt.oid))
+		if !ok || got != tt.want {
+			t.Errorf("LookupOIDName(%s) = %q, %v, want %q", tt.oid, got, ok, tt.want)
+		}
+	}
+	if oid, ok := LookupOID("catalyst2960"); !ok || !oid.Equal(MustParseOID("1.3.6.1.4.1.9.1.1208")) {
+		t.Errorf("LookupOID(catalyst2960) = %s, %v", oid, ok)
+	}
+}
+
+func TestRegisterOIDNameDuplicate(t *testing.T) {
+	restoreOIDNames(t)
+	first := MustParseOID("1.3.6.1.4.1.99999.1")
+	second := MustParseOID("1.3.6.1.4.1.99999.2")
+	RegisterOIDName(first, "testDevice")
+	RegisterOIDName(second, "testDevice")
+
+	// Both OIDs keep the name, and the name resolves to the latest.
+	for _, oid := range []OID{first, second} {
+		if name, ok := LookupOIDName(oid); !ok || name != "testDevice" {
+			t.Errorf("LookupOIDName(%s) = %q, %v, want testDevice", oid, name, ok)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if oid, ok := LookupOID("testDevice"); !ok || !oid.Equal(second) {
+			t.Fatalf("LookupOID(testDevice) = %s, %v, want %s", oid, ok, second)
+		}
+	}
+
+	// Renaming the latest OID falls back to the earlier one.
+	RegisterOIDName(second, "testDeviceV2")
+	if oid, ok := LookupOID("testDeviceV2"); !ok || !oid.Equal(second) {
+		t.Errorf("LookupOID(testDeviceV2) = %s, %v, want %s", oid, ok, second)
+	}
+	if oid, ok := LookupOID("testDevice"); !ok || !oid.Equal(first) {
+		t.Errorf("LookupOID(testDevice) = %s, %v, want %s", oid, ok, first)
+	}
+	RegisterOIDName(first, "testDeviceV1")
+	if oid, ok := LookupOID("testDevice"); ok {
+		t.Errorf("LookupOID(testDevice) = %s after renaming both OIDs", oid)
+	}
+}
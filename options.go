@@ -15,8 +15,14 @@
 package snmp
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // ClientOptions contains configuration options for the SNMP client.
@@ -25,46 +31,246 @@ type ClientOptions struct {
 	Target string
 	// Port is the SNMP agent port (default 161).
 	Port int
+	// UnixSocket is the path of a Unix datagram socket to use instead of UDP.
+	UnixSocket string
+	// LocalAddr is the local UDP address (host:port) to send from. Empty
+	// lets the system choose.
+	LocalAddr string
+	// DontFragment sets the DF bit on the UDP socket (Linux only).
+	DontFragment bool
 	// Version is the SNMP version to use.
 	Version SNMPVersion
 	// Community is the community string (v1/v2c).
 	Community string
-	// Timeout is the request timeout.
+	// NotifyCommunity is the community string for outgoing traps and
+	// informs. Empty means Community.
+	NotifyCommunity string
+	// LenientCommunity uses community strings untrimmed and logs a
+	// warning for an empty or non-printable one instead of failing.
+	LenientCommunity bool
+	// Timeout is the request timeout. Unless set with WithTimeout it
+	// follows the version: DefaultV1Timeout for SNMPv1, DefaultTimeout
+	// otherwise.
 	Timeout time.Duration
-	// Retries is the number of retries on timeout.
+	// Retries is the number of retries on timeout. Unless set with
+	// WithRetries it follows the version: DefaultV1Retries for SNMPv1,
+	// DefaultRetries otherwise.
 	Retries int
+	// timeoutSet and retriesSet record explicit WithTimeout and
+	// WithRetries options, which take precedence over version defaults.
+	timeoutSet, retriesSet bool
+	// InformRetries is the number of retransmissions of an unacknowledged inform.
+	InformRetries int
+	// RetryOnGenErr is the number of times a request answered with genErr
+	// is sent again before the error is returned.
+	RetryOnGenErr int
+	// InformBackoff multiplies the wait for an acknowledgment after each
+	// inform retransmission.
+	InformBackoff float64
 	// MaxOids is the maximum OIDs per request.
 	MaxOids int
-	// MaxRepetitions is the max-repetitions for GetBulk (v2c/v3).
+	// MaxRepetitions is the max-repetitions for GetBulk (v2c/v3). Walks
+	// can override it per request with WithRequestMaxRepetitions.
 	MaxRepetitions int
-	// NonRepeaters is the non-repeaters for GetBulk.
+	// NonRepeaters is the non-repeaters for GetBulk. Walks can override it
+	// per request with WithRequestNonRepeaters.
 	NonRepeaters int
+	// MaxMessageSize is the largest response, in bytes, that bulk walks
+	// size their requests for. Zero means no limit.
+	MaxMessageSize int
+	// LenientWalk skips non-increasing OIDs during walks instead of aborting.
+	LenientWalk bool
+	// MaxWalkRegressions is the number of consecutive non-increasing OIDs
+	// tolerated by a lenient walk before it fails.
+	MaxWalkRegressions int
+	// PipelineWindow is the number of requests GetPipelined keeps in flight.
+	PipelineWindow int
+	// SkipHoles skips noSuchObject/noSuchInstance varbinds during walks
+	// instead of ending the walk.
+	SkipHoles bool
+	// KeepRawValues keeps the undecoded value bytes in Variable.RawValue.
+	KeepRawValues bool
+	// StrictResponseCount fails GETs answered with fewer varbinds than
+	// requested instead of padding the response.
+	StrictResponseCount bool
+	// DecodeOpaqueSpecial decodes Opaque-wrapped Counter64 values.
+	DecodeOpaqueSpecial bool
+	// KeepaliveInterval is the idle time after which a keepalive GET for
+	// KeepaliveOID is sent. Zero disables keepalives.
+	KeepaliveInterval time.Duration
+	KeepaliveOID      OID
+	// LenientDecode keeps the complete varbinds of truncated responses.
+	LenientDecode bool
+	// VerifySet reads back the variables of every successful SET and
+	// fails with a VerifyError if they do not hold the values set.
+	VerifySet bool
 
 	// SNMPv3 Security
-	SecurityLevel    SecurityLevel
-	SecurityName     string
-	AuthProtocol     AuthProtocol
-	AuthPassphrase   string
-	PrivProtocol     PrivProtocol
-	PrivPassphrase   string
-	ContextName      string
-	ContextEngineID  string
+	SecurityLevel   SecurityLevel
+	SecurityName    string
+	AuthProtocol    AuthProtocol
+	AuthPassphrase  string
+	PrivProtocol    PrivProtocol
+	PrivPassphrase  string
+	ContextName     string
+	ContextEngineID string
+	// ContextCommunitySuffix selects the context of SNMPv1/v2c requests by
+	// appending "@context" to the community string.
+	ContextCommunitySuffix bool
+
+	// EngineID is the authoritative snmpEngineID of the agent when it is
+	// provisioned in advance, so that engine discovery can be skipped.
+	// EngineBoots and EngineTime are the last known values of its clock.
+	EngineID    []byte
+	EngineBoots int32
+	EngineTime  int32
+
+	// LocalEngineID is the snmpEngineID of this client when it acts as a
+	// notification originator.
+	LocalEngineID []byte
+	// EngineBootsStore is the file that persists the local snmpEngineBoots
+	// counter across restarts.
+	EngineBootsStore string
 
 	// Connection
 	AutoReconnect        bool
 	MaxReconnectInterval time.Duration
 	ConnectRetryInterval time.Duration
 	MaxRetries           int
+	FailFast             bool
 
 	// Callbacks
 	OnConnect        OnConnectHandler
 	OnConnectionLost ConnectionLostHandler
 	OnReconnecting   ReconnectHandler
+	OnAuthFailure    AuthFailureHandler
+
+	// RequestValueType is the type of the empty placeholder values in GET,
+	// GETNEXT and GETBULK requests (default TypeNull).
+	RequestValueType BERType
+
+	// ResponseValidator, if set, must accept each response before it is
+	// matched to a request.
+	ResponseValidator ResponseValidator
+
+	// Packet dumping
+	OnPacket      PacketDumpHandler
+	RedactSecrets bool
+
+	// LatencyBuckets are the request latency histogram bounds in
+	// milliseconds (nil = default).
+	LatencyBuckets []int64
 
 	// Logger
 	Logger *slog.Logger
 }
 
+// redacted replaces a non-empty secret for logging.
+func redacted(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "****"
+}
+
+// LogValue implements slog.LogValuer so that logging the options never
+// reveals the community string or the SNMPv3 passphrases.
+func (o *ClientOptions) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("target", o.Target),
+		slog.Int("port", o.Port),
+		slog.String("version", o.Version.String()),
+		slog.String("community", redacted(o.Community)),
+		slog.String("notify_community", redacted(o.NotifyCommunity)),
+		slog.Duration("timeout", o.Timeout),
+		slog.Int("retries", o.Retries),
+		slog.String("security_level", o.SecurityLevel.String()),
+		slog.String("security_name", o.SecurityName),
+		slog.String("auth_protocol", o.AuthProtocol.String()),
+		slog.String("auth_passphrase", redacted(o.AuthPassphrase)),
+		slog.String("priv_protocol", o.PrivProtocol.String()),
+		slog.String("priv_passphrase", redacted(o.PrivPassphrase)),
+		slog.String("context_name", o.ContextName),
+	)
+}
+
+// validateSecurity checks that the SNMPv3 settings are usable.
+func (o *ClientOptions) validateSecurity() error {
+	if o.Version == Version3 && o.SecurityLevel >= AuthNoPriv && o.SecurityName == "" {
+		return fmt.Errorf("%w: security level %s requires a security name", ErrInvalidVersion, o.SecurityLevel)
+	}
+	if o.Version == Version3 && o.SecurityLevel >= AuthNoPriv && o.AuthProtocol == NoAuth {
+		return fmt.Errorf("%w: security level %s requires an authentication protocol", ErrInvalidVersion, o.SecurityLevel)
+	}
+	if o.Version == Version3 && o.SecurityLevel == AuthPriv && o.PrivProtocol == NoPriv {
+		return fmt.Errorf("%w: security level %s requires a privacy protocol", ErrInvalidVersion, o.SecurityLevel)
+	}
+	// RFC 3411: SnmpEngineID is 5 to 32 octets.
+	if n := len(o.EngineID); n > 0 && (n < 5 || n > 32) {
+		return fmt.Errorf("%w: engine ID must be 5 to 32 octets, got %d", ErrInvalidValue, n)
+	}
+	return nil
+}
+
+// validateRequestValueType checks that RequestValueType is a type whose
+// content can be empty, as WithRequestValueType documents.
+func (o *ClientOptions) validateRequestValueType() error {
+	switch o.RequestValueType {
+	case 0, TypeNull, TypeOctetString, TypeOpaque, TypeNsapAddress:
+		return nil
+	}
+	return fmt.Errorf("%w: %s cannot be a request placeholder value", ErrInvalidType, o.RequestValueType)
+}
+
+// communities returns the SNMPv1/v2c community strings normalized with
+// normalizeCommunity, and the first error. Each string is normalized even
+// if an earlier one is invalid, so a lenient client uses them all as
+// configured.
+func (o *ClientOptions) communities() (community, notify string, err error) {
+	community, notify = o.Community, o.NotifyCommunity
+	if o.Version == Version3 {
+		return community, notify, nil
+	}
+	community, err = normalizeCommunity("community", community, o.LenientCommunity)
+	if notify != "" {
+		var notifyErr error
+		notify, notifyErr = normalizeCommunity("notify community", notify, o.LenientCommunity)
+		if err == nil {
+			err = notifyErr
+		}
+	}
+	return community, notify, err
+}
+
+// normalizeCommunity trims the surrounding whitespace, which easily slips
+// in from configuration files, off a community string, unless lenient is
+// set, and checks that the result is non-empty and printable. name says
+// which community the error is about; it never includes the community
+// itself.
+func normalizeCommunity(name, community string, lenient bool) (string, error) {
+	if !lenient {
+		community = strings.TrimSpace(community)
+	}
+	if community == "" {
+		return community, fmt.Errorf("%w: empty %s", ErrInvalidCommunity, name)
+	}
+	if i := nonPrintableIndex(community); i >= 0 {
+		return community, fmt.Errorf("%w: %s has a non-printable character at offset %d", ErrInvalidCommunity, name, i)
+	}
+	return community, nil
+}
+
+// nonPrintableIndex returns the byte offset of the first character of s
+// that is invalid UTF-8 or not printable, or -1 if there is none.
+func nonPrintableIndex(s string) int {
+	for i, r := range s {
+		if r == utf8.RuneError || !unicode.IsPrint(r) {
+			return i
+		}
+	}
+	return -1
+}
+
 // SecurityLevel represents SNMPv3 security levels.
 type SecurityLevel int
 
@@ -169,9 +375,14 @@ func NewClientOptions() *ClientOptions {
 		Community:            DefaultCommunity,
 		Timeout:              DefaultTimeout,
 		Retries:              DefaultRetries,
+		InformRetries:        DefaultInformRetries,
+		InformBackoff:        DefaultInformBackoff,
 		MaxOids:              DefaultMaxOids,
 		MaxRepetitions:       DefaultMaxRepetitions,
 		NonRepeaters:         DefaultNonRepeaters,
+		MaxWalkRegressions:   DefaultMaxWalkRegressions,
+		PipelineWindow:       DefaultPipelineWindow,
+		RequestValueType:     TypeNull,
 		AutoReconnect:        true,
 		MaxReconnectInterval: 2 * time.Minute,
 		ConnectRetryInterval: time.Second,
@@ -180,6 +391,34 @@ func NewClientOptions() *ClientOptions {
 	}
 }
 
+// Clone returns a copy of the options that shares no slices with o.
+// Callbacks, the response validator and the logger are copied by
+// reference, so a derived client reports to the same handlers.
+func (o *ClientOptions) Clone() *ClientOptions {
+	clone := *o
+	clone.EngineID = slices.Clone(o.EngineID)
+	clone.LocalEngineID = slices.Clone(o.LocalEngineID)
+	clone.KeepaliveOID = slices.Clone(o.KeepaliveOID)
+	clone.LatencyBuckets = slices.Clone(o.LatencyBuckets)
+	return &clone
+}
+
+// applyVersionDefaults sets the timeout and retries of the SNMP version
+// unless they were set explicitly. Slow SNMPv1 agents, often behind serial
+// links, get more time than the other versions.
+func (o *ClientOptions) applyVersionDefaults() {
+	timeout, retries := DefaultTimeout, DefaultRetries
+	if o.Version == Version1 {
+		timeout, retries = DefaultV1Timeout, DefaultV1Retries
+	}
+	if !o.timeoutSet {
+		o.Timeout = timeout
+	}
+	if !o.retriesSet {
+		o.Retries = retries
+	}
+}
+
 // Option is a functional option for configuring the client.
 type Option func(*ClientOptions)
 
@@ -197,6 +436,33 @@ func WithPort(port int) Option {
 	}
 }
 
+// WithUnixSocket connects to the agent over a Unix datagram socket at path
+// instead of UDP. Target and Port are ignored when set.
+func WithUnixSocket(path string) Option {
+	return func(o *ClientOptions) {
+		o.UnixSocket = path
+	}
+}
+
+// WithLocalAddr sets the local UDP address requests are sent from, e.g.
+// ":1161" to pin the source port or "10.0.0.5:0" to pin the interface.
+func WithLocalAddr(addr string) Option {
+	return func(o *ClientOptions) {
+		o.LocalAddr = addr
+	}
+}
+
+// WithDontFragment sets the IP don't-fragment bit on outgoing datagrams.
+// A request larger than the path MTU then fails immediately with
+// ErrPacketTooLarge instead of being fragmented, which helps find the size
+// at which a path breaks. It is only supported on Linux; elsewhere Connect
+// fails with an error wrapping errors.ErrUnsupported.
+func WithDontFragment(enabled bool) Option {
+	return func(o *ClientOptions) {
+		o.DontFragment = enabled
+	}
+}
+
 // WithVersion sets the SNMP version.
 func WithVersion(version SNMPVersion) Option {
 	return func(o *ClientOptions) {
@@ -204,24 +470,75 @@ func WithVersion(version SNMPVersion) Option {
 	}
 }
 
-// WithCommunity sets the community string.
+// WithCommunity sets the community string. Surrounding whitespace, which
+// easily slips in from configuration files, is trimmed, and Connect fails
+// with ErrInvalidCommunity if an SNMPv1/v2c community is empty or contains
+// non-printable characters; see WithLenientCommunity.
 func WithCommunity(community string) Option {
 	return func(o *ClientOptions) {
 		o.Community = community
 	}
 }
 
-// WithTimeout sets the request timeout.
+// WithNotifyCommunity sets the community string used by SendTrap,
+// SendTrapV1 and Inform, so notifications can go to a manager that expects
+// a different community than the one used for polling. It is trimmed and
+// validated like the WithCommunity string.
+func WithNotifyCommunity(community string) Option {
+	return func(o *ClientOptions) {
+		o.NotifyCommunity = community
+	}
+}
+
+// WithLenientCommunity makes the client use community strings exactly as
+// given, for agents that are really configured with an odd one: they are
+// not trimmed, and an empty or non-printable community string is logged
+// as a warning instead of failing Connect or the request.
+func WithLenientCommunity(enabled bool) Option {
+	return func(o *ClientOptions) {
+		o.LenientCommunity = enabled
+	}
+}
+
+// WithTimeout sets the request timeout. It takes precedence over the
+// version default.
 func WithTimeout(d time.Duration) Option {
 	return func(o *ClientOptions) {
 		o.Timeout = d
+		o.timeoutSet = true
 	}
 }
 
-// WithRetries sets the number of retries.
+// WithRetries sets the number of retries. It takes precedence over the
+// version default.
 func WithRetries(n int) Option {
 	return func(o *ClientOptions) {
 		o.Retries = n
+		o.retriesSet = true
+	}
+}
+
+// WithRetryOnGenErr resends a request up to n more times when the agent
+// answers it with genErr, for agents that fail intermittently under load.
+// These retries are separate from the timeout retries set by WithRetries.
+func WithRetryOnGenErr(n int) Option {
+	return func(o *ClientOptions) {
+		o.RetryOnGenErr = n
+	}
+}
+
+// WithInformRetries sets the number of inform retransmissions.
+func WithInformRetries(n int) Option {
+	return func(o *ClientOptions) {
+		o.InformRetries = n
+	}
+}
+
+// WithInformBackoff sets the factor by which the wait for an inform
+// acknowledgment grows after each retransmission.
+func WithInformBackoff(multiplier float64) Option {
+	return func(o *ClientOptions) {
+		o.InformBackoff = multiplier
 	}
 }
 
@@ -246,6 +563,100 @@ func WithNonRepeaters(n int) Option {
 	}
 }
 
+// WithLenientWalk tolerates agents that return OIDs out of order. Instead
+// of aborting, a walk skips non-increasing varbinds and continues from the
+// highest OID seen so far.
+func WithLenientWalk(enabled bool) Option {
+	return func(o *ClientOptions) {
+		o.LenientWalk = enabled
+	}
+}
+
+// WithMaxWalkRegressions sets how many consecutive non-increasing OIDs a
+// lenient walk tolerates before failing.
+func WithMaxWalkRegressions(n int) Option {
+	return func(o *ClientOptions) {
+		o.MaxWalkRegressions = n
+	}
+}
+
+// WithMaxMessageSize caps the response size bulk walks aim for. The walk
+// lowers max-repetitions so that the expected response, estimated from the
+// varbind sizes seen so far, stays within n bytes. Over SNMPv3 the walk
+// also keeps within the msgMaxSize the agent advertises.
+func WithMaxMessageSize(n int) Option {
+	return func(o *ClientOptions) {
+		o.MaxMessageSize = n
+	}
+}
+
+// WithPipelineWindow sets how many requests GetPipelined keeps in flight.
+func WithPipelineWindow(n int) Option {
+	return func(o *ClientOptions) {
+		o.PipelineWindow = n
+	}
+}
+
+// WithSkipHoles makes walks skip noSuchObject/noSuchInstance varbinds,
+// which some agents interleave for sparse columns in GetBulk responses.
+// The walk then only ends at endOfMibView or when it leaves the subtree.
+func WithSkipHoles(enabled bool) Option {
+	return func(o *ClientOptions) {
+		o.SkipHoles = enabled
+	}
+}
+
+// WithDecodeOpaqueSpecial decodes the non-standard Opaque-wrapped Counter64
+// that some agents return to SNMPv1 clients as a TypeCounter64 variable
+// with a uint64 value. Other Opaque values are left untouched.
+func WithDecodeOpaqueSpecial(enabled bool) Option {
+	return func(o *ClientOptions) {
+		o.DecodeOpaqueSpecial = enabled
+	}
+}
+
+// WithLenientDecode recovers the varbinds that arrived complete from a
+// response datagram that was cut short, for example by a middlebox,
+// instead of discarding the whole response. A GET then sees a short
+// response, handled as set by WithStrictResponseCount, and a walk resumes
+// after the last recovered varbind.
+func WithLenientDecode(enabled bool) Option {
+	return func(o *ClientOptions) {
+		o.LenientDecode = enabled
+	}
+}
+
+// WithStrictResponseCount controls GET responses that carry fewer varbinds
+// than were requested. When strict, Get fails with ErrMalformedPacket;
+// otherwise (the default) the missing positions are filled with
+// noSuchInstance varbinds for the requested OIDs, so the result still lines
+// up with the request.
+func WithStrictResponseCount(strict bool) Option {
+	return func(o *ClientOptions) {
+		o.StrictResponseCount = strict
+	}
+}
+
+// WithVerifySet makes Set read back the variables it has set with a GET
+// and compare them with the values sent. Variables that do not match are
+// reported in a VerifyError, which catches agents that answer noError but
+// apply only part of the SET, or none of it. Do not enable this for
+// write-only objects such as keys, which agents do not return as set.
+func WithVerifySet(enabled bool) Option {
+	return func(o *ClientOptions) {
+		o.VerifySet = enabled
+	}
+}
+
+// WithKeepRawValues keeps the on-wire value bytes of received varbinds in
+// Variable.RawValue. This is off by default to avoid retaining the extra
+// memory.
+func WithKeepRawValues(enabled bool) Option {
+	return func(o *ClientOptions) {
+		o.KeepRawValues = enabled
+	}
+}
+
 // WithSecurityLevel sets the SNMPv3 security level.
 func WithSecurityLevel(level SecurityLevel) Option {
 	return func(o *ClientOptions) {
@@ -283,6 +694,17 @@ func WithContextName(name string) Option {
 	}
 }
 
+// WithContextCommunitySuffix selects the context name of SNMPv1/v2c
+// requests with the community@context convention that many agents use to
+// expose per-VRF or per-instance data, e.g. "public@vrfA". The context is
+// the one passed to GetInContext or WithRequestContextName, or else the
+// client ContextName. Notifications are sent with the plain community.
+func WithContextCommunitySuffix(enabled bool) Option {
+	return func(o *ClientOptions) {
+		o.ContextCommunitySuffix = enabled
+	}
+}
+
 // WithContextEngineID sets the SNMPv3 context engine ID.
 func WithContextEngineID(id string) Option {
 	return func(o *ClientOptions) {
@@ -290,6 +712,50 @@ func WithContextEngineID(id string) Option {
 	}
 }
 
+// WithEngineID sets the authoritative SNMPv3 engine ID of the agent, for
+// fleets where engine IDs are provisioned in advance: the client then skips
+// the discovery exchange and sends its first request authenticated right
+// away. If the agent reports the ID as unknown, the client adopts the one
+// in the report and sends the request again. It is also the default
+// context engine ID. The ID must be 5 to 32 octets; requests fail with
+// ErrInvalidValue otherwise.
+func WithEngineID(id []byte) Option {
+	return func(o *ClientOptions) {
+		o.EngineID = id
+	}
+}
+
+// WithEngineTimeHint sets the snmpEngineBoots and snmpEngineTime of the
+// agent set with WithEngineID, as of the first request. Without a hint, or
+// with a stale one, the first authenticated request is answered with a
+// notInTimeWindow report that carries the agent's clock, and is sent
+// again.
+func WithEngineTimeHint(boots, engineTime int32) Option {
+	return func(o *ClientOptions) {
+		o.EngineBoots = boots
+		o.EngineTime = engineTime
+	}
+}
+
+// WithLocalEngineID sets the SNMPv3 engine ID of this client, used when it
+// originates notifications. SendTrap requires it over SNMPv3.
+func WithLocalEngineID(id []byte) Option {
+	return func(o *ClientOptions) {
+		o.LocalEngineID = id
+	}
+}
+
+// WithEngineBootsStore persists the local snmpEngineBoots counter in the
+// file at path. The counter is incremented once per process, when the
+// first client using the file connects, so that a restarted notification
+// originator never reuses a (boots, time) pair the receivers have already
+// seen (RFC 3414). SNMPv3 traps carry the counter in their header.
+func WithEngineBootsStore(path string) Option {
+	return func(o *ClientOptions) {
+		o.EngineBootsStore = path
+	}
+}
+
 // WithAutoReconnect enables or disables automatic reconnection.
 func WithAutoReconnect(enabled bool) Option {
 	return func(o *ClientOptions) {
@@ -318,6 +784,29 @@ func WithMaxConnectRetries(n int) Option {
 	}
 }
 
+// WithFailFast makes requests return ErrNotConnected immediately while the
+// client is not connected. By default, a request issued while AutoReconnect
+// is re-establishing the connection waits for the reconnection to finish
+// (bounded by the request context) before being sent. FailFast has no
+// effect when AutoReconnect is disabled, since requests never wait then.
+func WithFailFast(enabled bool) Option {
+	return func(o *ClientOptions) {
+		o.FailFast = enabled
+	}
+}
+
+// WithKeepalive sends a GET for oid (sysUpTime.0 if nil) whenever the
+// client has sent nothing for interval, so that the UDP mapping of a NAT
+// or stateful firewall between the client and the agent does not expire
+// between polls. Keepalives run in the background while the client is
+// connected; their failures are only logged. A zero interval disables them.
+func WithKeepalive(interval time.Duration, oid OID) Option {
+	return func(o *ClientOptions) {
+		o.KeepaliveInterval = interval
+		o.KeepaliveOID = oid
+	}
+}
+
 // WithOnConnect sets the connection callback.
 func WithOnConnect(handler OnConnectHandler) Option {
 	return func(o *ClientOptions) {
@@ -332,6 +821,49 @@ func WithOnConnectionLost(handler ConnectionLostHandler) Option {
 	}
 }
 
+// WithRequestValueType sets the type of the empty placeholder values sent
+// in GET, GETNEXT and GETBULK varbinds, as an escape hatch for legacy agents
+// that mishandle the standard NULL. Only types whose content can be empty
+// are accepted: TypeNull, TypeOctetString, TypeOpaque and TypeNsapAddress;
+// Connect fails with ErrInvalidType for any other.
+// Whatever the type, the encoded varbinds of every request are logged at
+// debug level.
+func WithRequestValueType(t BERType) Option {
+	return func(o *ClientOptions) {
+		o.RequestValueType = t
+	}
+}
+
+// WithResponseValidator sets a hook that every decoded response must pass
+// before it is matched to a pending request. Rejected responses are dropped
+// and counted in Metrics.ResponsesRejected, so the request keeps waiting
+// for a valid response or times out. Use it to enforce local policy such as
+// pinning the source address.
+func WithResponseValidator(validator ResponseValidator) Option {
+	return func(o *ClientOptions) {
+		o.ResponseValidator = validator
+	}
+}
+
+// WithLatencyBuckets sets the upper bounds, in milliseconds, of the request
+// latency histogram buckets. The bounds must be strictly ascending, or
+// Connect fails.
+func WithLatencyBuckets(bounds []int64) Option {
+	return func(o *ClientOptions) {
+		o.LatencyBuckets = bounds
+	}
+}
+
+// WithOnAuthFailure sets a callback invoked when the agent answers with an
+// SNMPv3 report of a wrong digest or an unsupported security level, which
+// usually means wrong credentials. The request itself fails with
+// ErrAuthFailure.
+func WithOnAuthFailure(handler AuthFailureHandler) Option {
+	return func(o *ClientOptions) {
+		o.OnAuthFailure = handler
+	}
+}
+
 // WithOnReconnecting sets the reconnecting callback.
 func WithOnReconnecting(handler ReconnectHandler) Option {
 	return func(o *ClientOptions) {
@@ -339,6 +871,22 @@ func WithOnReconnecting(handler ReconnectHandler) Option {
 	}
 }
 
+// WithPacketDump sets a callback that receives a copy of every packet sent
+// to or received from the agent, e.g. for hex dumps while debugging.
+func WithPacketDump(handler PacketDumpHandler) Option {
+	return func(o *ClientOptions) {
+		o.OnPacket = handler
+	}
+}
+
+// WithRedactSecrets overwrites the community string in packets passed to the
+// packet dump callback, so dumps can be logged in production.
+func WithRedactSecrets(enabled bool) Option {
+	return func(o *ClientOptions) {
+		o.RedactSecrets = enabled
+	}
+}
+
 // WithLogger sets the logger.
 func WithLogger(logger *slog.Logger) Option {
 	return func(o *ClientOptions) {
@@ -346,6 +894,151 @@ func WithLogger(logger *slog.Logger) Option {
 	}
 }
 
+// RequestOptions contains per-request overrides of the client options.
+type RequestOptions struct {
+	// Community overrides the client community string (v1/v2c).
+	Community    string
+	communitySet bool
+
+	// ContextName overrides the client context name: the SNMPv3 context,
+	// or the community suffix with ContextCommunitySuffix.
+	ContextName    string
+	contextNameSet bool
+
+	// MaxRepetitions overrides the client max-repetitions for walks.
+	MaxRepetitions    int
+	maxRepetitionsSet bool
+
+	// NonRepeaters overrides the client non-repeaters for walks.
+	NonRepeaters    int
+	nonRepeatersSet bool
+
+	// MaxWalkOIDs stops a walk with ErrWalkLimitExceeded before it returns
+	// more variables. Zero means no limit.
+	MaxWalkOIDs int
+
+	// MaxWalkDuration stops a walk with ErrWalkLimitExceeded once it has run
+	// this long. Zero means no limit.
+	MaxWalkDuration time.Duration
+}
+
+// RequestOption is a functional option applied to a single request.
+type RequestOption func(*RequestOptions)
+
+// WithRequestCommunity sends the request with the given community string
+// instead of the client's, so one client can poll views that are separated
+// by community without reconnecting. It is trimmed and validated like the
+// WithCommunity string, failing the request with ErrInvalidCommunity.
+func WithRequestCommunity(community string) RequestOption {
+	return func(o *RequestOptions) {
+		o.Community = community
+		o.communitySet = true
+	}
+}
+
+// WithRequestContextName sends the request in the given context, so one
+// client can query several VRFs or instances of a multi-context agent.
+// SNMPv1/v2c clients need WithContextCommunitySuffix for this.
+func WithRequestContextName(name string) RequestOption {
+	return func(o *RequestOptions) {
+		o.ContextName = name
+		o.contextNameSet = true
+	}
+}
+
+// WithRequestMaxRepetitions sets the max-repetitions used by GetBulk
+// requests issued during a walk.
+func WithRequestMaxRepetitions(n int) RequestOption {
+	return func(o *RequestOptions) {
+		o.MaxRepetitions = n
+		o.maxRepetitionsSet = true
+	}
+}
+
+// WithRequestNonRepeaters sets the non-repeaters used by GetBulk requests
+// issued during a walk.
+func WithRequestNonRepeaters(n int) RequestOption {
+	return func(o *RequestOptions) {
+		o.NonRepeaters = n
+		o.nonRepeatersSet = true
+	}
+}
+
+// WithRequestMaxWalkOIDs limits the number of variables a walk returns.
+func WithRequestMaxWalkOIDs(n int) RequestOption {
+	return func(o *RequestOptions) {
+		o.MaxWalkOIDs = n
+	}
+}
+
+// WithRequestMaxWalkDuration limits how long a walk may run.
+func WithRequestMaxWalkDuration(d time.Duration) RequestOption {
+	return func(o *RequestOptions) {
+		o.MaxWalkDuration = d
+	}
+}
+
+type requestOptionsKey struct{}
+
+// WithRequestOptions returns a context carrying request options. Requests
+// issued with the returned context apply them on top of the client options.
+func WithRequestOptions(ctx context.Context, opts ...RequestOption) context.Context {
+	ro := &RequestOptions{}
+	if parent := requestOptionsFromContext(ctx); parent != nil {
+		*ro = *parent
+	}
+	for _, opt := range opts {
+		opt(ro)
+	}
+	return context.WithValue(ctx, requestOptionsKey{}, ro)
+}
+
+func requestOptionsFromContext(ctx context.Context) *RequestOptions {
+	ro, _ := ctx.Value(requestOptionsKey{}).(*RequestOptions)
+	return ro
+}
+
+// requestStatsKey carries the *RequestStats a request records into.
+type requestStatsKey struct{}
+
+func requestStatsFromContext(ctx context.Context) *RequestStats {
+	stats, _ := ctx.Value(requestStatsKey{}).(*RequestStats)
+	return stats
+}
+
+// rawRequestKey marks a request sent with SendRaw, which is encoded as-is.
+type rawRequestKey struct{}
+
+func isRawRequest(ctx context.Context) bool {
+	raw, _ := ctx.Value(rawRequestKey{}).(bool)
+	return raw
+}
+
+// discoveryKey marks the SNMPv3 request that discovers the agent engine.
+type discoveryKey struct{}
+
+func isDiscovery(ctx context.Context) bool {
+	discovery, _ := ctx.Value(discoveryKey{}).(bool)
+	return discovery
+}
+
+// engineResyncKey counts how often an SNMPv3 request was sent again after
+// a report updated the agent engine ID or time.
+type engineResyncKey struct{}
+
+func engineResyncs(ctx context.Context) int {
+	n, _ := ctx.Value(engineResyncKey{}).(int)
+	return n
+}
+
+// walkStatsKey carries the *WalkStats a walk records into.
+type walkStatsKey struct{}
+
+func walkStatsFromContext(ctx context.Context) *WalkStats {
+	stats, _ := ctx.Value(walkStatsKey{}).(*WalkStats)
+	return stats
+}
+
 // PoolOptions contains configuration options for the connection pool.
 type PoolOptions struct {
 	// Size is the number of connections in the pool.
@@ -398,12 +1091,76 @@ func WithPoolClientOptions(opts ...Option) PoolOption {
 	}
 }
 
+// PollerOptions contains configuration options for a BackoffPoller.
+type PollerOptions struct {
+	// Jitter randomizes each delay by up to this fraction of it, in either
+	// direction, so pollers started together spread out over time.
+	Jitter float64
+	// Multiplier grows the delay after each consecutive failed poll.
+	Multiplier float64
+	// MaxInterval caps the delay reached by backing off.
+	MaxInterval time.Duration
+}
+
+// NewPollerOptions creates PollerOptions with default values.
+func NewPollerOptions() *PollerOptions {
+	return &PollerOptions{
+		Jitter:      DefaultPollerJitter,
+		Multiplier:  DefaultPollerMultiplier,
+		MaxInterval: DefaultPollerMaxInterval,
+	}
+}
+
+// PollerOption is a functional option for configuring a BackoffPoller.
+type PollerOption func(*PollerOptions)
+
+// WithPollerJitter sets the jitter fraction, between 0 and 1.
+func WithPollerJitter(fraction float64) PollerOption {
+	return func(o *PollerOptions) {
+		o.Jitter = fraction
+	}
+}
+
+// WithPollerMultiplier sets the factor applied to the delay after each
+// consecutive failed poll.
+func WithPollerMultiplier(multiplier float64) PollerOption {
+	return func(o *PollerOptions) {
+		o.Multiplier = multiplier
+	}
+}
+
+// WithPollerMaxInterval sets the maximum delay reached by backing off.
+func WithPollerMaxInterval(d time.Duration) PollerOption {
+	return func(o *PollerOptions) {
+		o.MaxInterval = d
+	}
+}
+
 // TrapListenerOptions contains configuration for the trap listener.
 type TrapListenerOptions struct {
 	// Address is the listen address (default ":162").
 	Address string
+	// Network is the UDP network to listen on: "udp" (dual-stack, default),
+	// "udp4" or "udp6".
+	Network string
 	// Community is the expected community string (empty = accept all).
 	Community string
+	// TrapOIDFilter drops traps whose TrapOID it does not match (nil = accept all).
+	TrapOIDFilter *OIDMatcher
+	// RawHandler receives datagrams that could not be decoded as a trap.
+	RawHandler RawTrapHandler
+	// V3Users are the SNMPv3 users whose notifications are verified and
+	// decrypted, by user name.
+	V3Users map[string]*usmUser
+	// CryptoProvider implements the USM cryptography for V3Users (nil =
+	// StdCryptoProvider).
+	CryptoProvider CryptoProvider
+	// SpoolDir is the directory traps are spilled to while the queue is
+	// full (empty = no queue; the handler runs concurrently per trap).
+	SpoolDir string
+	// QueueSize is the number of traps held in memory for the handler
+	// before spilling to SpoolDir.
+	QueueSize int
 	// Logger is the logger.
 	Logger *slog.Logger
 }
@@ -411,7 +1168,9 @@ type TrapListenerOptions struct {
 // NewTrapListenerOptions creates TrapListenerOptions with default values.
 func NewTrapListenerOptions() *TrapListenerOptions {
 	return &TrapListenerOptions{
-		Address: ":162",
+		Address:   ":162",
+		Network:   "udp",
+		QueueSize: DefaultTrapQueueSize,
 	}
 }
 
@@ -425,6 +1184,14 @@ func WithListenAddress(addr string) TrapListenerOption {
 	}
 }
 
+// WithTrapNetwork sets the UDP network to listen on ("udp", "udp4" or
+// "udp6"). The default "udp" accepts both IPv4 and IPv6 traps.
+func WithTrapNetwork(network string) TrapListenerOption {
+	return func(o *TrapListenerOptions) {
+		o.Network = network
+	}
+}
+
 // WithTrapCommunity sets the expected community string.
 func WithTrapCommunity(community string) TrapListenerOption {
 	return func(o *TrapListenerOptions) {
@@ -432,9 +1199,136 @@ func WithTrapCommunity(community string) TrapListenerOption {
 	}
 }
 
+// WithTrapOIDFilter only delivers traps whose TrapOID lies within one of
+// the matcher's subtrees.
+func WithTrapOIDFilter(filter *OIDMatcher) TrapListenerOption {
+	return func(o *TrapListenerOptions) {
+		o.TrapOIDFilter = filter
+	}
+}
+
+// WithRawTrapHandler sets a handler for datagrams the listener cannot
+// decode as a notification, such as SNMPv3 traps that fail authentication
+// or stray traffic, so they can be inspected or forwarded instead of only
+// being logged.
+func WithRawTrapHandler(handler RawTrapHandler) TrapListenerOption {
+	return func(o *TrapListenerOptions) {
+		o.RawHandler = handler
+	}
+}
+
+// WithTrapV3User adds an SNMPv3 user for verifying and decrypting
+// notifications. Use NoAuth and NoPriv for the protocols the user does not
+// use. SNMPv3 notifications without authentication are delivered even from
+// unknown users; check TrapPDU.SecurityLevel to reject them.
+func WithTrapV3User(name string, authProto AuthProtocol, authPass string, privProto PrivProtocol, privPass string) TrapListenerOption {
+	return func(o *TrapListenerOptions) {
+		if o.V3Users == nil {
+			o.V3Users = make(map[string]*usmUser)
+		}
+		o.V3Users[name] = newUSMUser(name, authProto, authPass, privProto, privPass)
+	}
+}
+
+// WithTrapCryptoProvider replaces the Go standard library for the hashes,
+// HMACs and ciphers used to verify and decrypt SNMPv3 notifications, for
+// example with a FIPS-validated module. It applies to every user added
+// with WithTrapV3User.
+func WithTrapCryptoProvider(provider CryptoProvider) TrapListenerOption {
+	return func(o *TrapListenerOptions) {
+		o.CryptoProvider = provider
+	}
+}
+
+// WithTrapSpoolDir queues traps for the handler and spills them to files
+// in dir while the queue is full, so that a handler blocked by a
+// downstream outage does not lose traps. The handler is then called for
+// one trap at a time, in arrival order. Spilled traps are replayed once
+// the queue has drained, including those left in dir by a previous run,
+// and each file is removed after the handler has returned. The directory
+// is created if needed.
+func WithTrapSpoolDir(dir string) TrapListenerOption {
+	return func(o *TrapListenerOptions) {
+		o.SpoolDir = dir
+	}
+}
+
+// WithTrapQueueSize sets how many traps are held in memory before spilling
+// to the spool directory (default DefaultTrapQueueSize). It only applies
+// with WithTrapSpoolDir.
+func WithTrapQueueSize(n int) TrapListenerOption {
+	return func(o *TrapListenerOptions) {
+		o.QueueSize = n
+	}
+}
+
 // WithTrapLogger sets the logger for the trap listener.
 func WithTrapLogger(logger *slog.Logger) TrapListenerOption {
 	return func(o *TrapListenerOptions) {
 		o.Logger = logger
 	}
 }
+
+// SyslogOptions contains configuration for a SyslogTrapForwarder.
+type SyslogOptions struct {
+	// Facility is the syslog facility code (default 16, local0).
+	Facility int
+	// Severity is the syslog severity code (default 5, notice).
+	Severity int
+	// Hostname is the HOSTNAME field (default: the local host name).
+	Hostname string
+	// AppName is the APP-NAME field (default "snmptrap").
+	AppName string
+	// DialTimeout bounds each connection attempt to the collector.
+	DialTimeout time.Duration
+	// Logger is the logger.
+	Logger *slog.Logger
+}
+
+// NewSyslogOptions creates SyslogOptions with default values.
+func NewSyslogOptions() *SyslogOptions {
+	return &SyslogOptions{
+		Facility:    16,
+		Severity:    5,
+		AppName:     "snmptrap",
+		DialTimeout: DefaultTimeout,
+	}
+}
+
+// SyslogOption is a functional option for configuring a SyslogTrapForwarder.
+type SyslogOption func(*SyslogOptions)
+
+// WithSyslogFacility sets the syslog facility code (0-23).
+func WithSyslogFacility(facility int) SyslogOption {
+	return func(o *SyslogOptions) {
+		o.Facility = facility
+	}
+}
+
+// WithSyslogSeverity sets the syslog severity code (0-7).
+func WithSyslogSeverity(severity int) SyslogOption {
+	return func(o *SyslogOptions) {
+		o.Severity = severity
+	}
+}
+
+// WithSyslogHostname sets the HOSTNAME field of forwarded messages.
+func WithSyslogHostname(hostname string) SyslogOption {
+	return func(o *SyslogOptions) {
+		o.Hostname = hostname
+	}
+}
+
+// WithSyslogAppName sets the APP-NAME field of forwarded messages.
+func WithSyslogAppName(name string) SyslogOption {
+	return func(o *SyslogOptions) {
+		o.AppName = name
+	}
+}
+
+// WithSyslogLogger sets the logger for the syslog forwarder.
+func WithSyslogLogger(logger *slog.Logger) SyslogOption {
+	return func(o *SyslogOptions) {
+		o.Logger = logger
+	}
+}
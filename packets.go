@@ -192,6 +192,217 @@ func DecodeMessage(data []byte) (*Message, error) {
 	return msg, nil
 }
 
+// DecodeAny decodes a captured SNMPv1/v2c packet of any PDU type for
+// offline inspection. It returns a *Message, or a *TrapV1Message for an
+// SNMPv1 trap. SNMPv3 messages are recognized but not decoded.
+func DecodeAny(data []byte) (interface{}, error) {
+	msg, err := DecodeMessage(data)
+	if err == nil {
+		return msg, nil
+	}
+	if trap, trapErr := DecodeTrapV1Message(data); trapErr == nil {
+		return trap, nil
+	}
+	if _, v3Err := decodeV3Message(data); v3Err == nil {
+		return nil, fmt.Errorf("%w: cannot decode an SNMPv3 message", ErrInvalidVersion)
+	}
+	return nil, err
+}
+
+// decodeTruncatedMessage decodes an SNMPv1/v2c message whose datagram may
+// have been cut short, keeping the varbinds that arrived complete. The
+// message header and PDU header must be complete. It reports whether the
+// message was truncated.
+func decodeTruncatedMessage(data []byte) (*Message, bool, error) {
+	seqType, seqData, _, truncated, err := decodePartialTLV(data)
+	if err != nil {
+		return nil, false, err
+	}
+	if seqType != TypeSequence {
+		return nil, false, NewParseError(fmt.Sprintf("expected sequence, got %s", seqType), -1)
+	}
+
+	// field returns the value of the next header field, which must be
+	// complete.
+	field := func(data *[]byte) ([]byte, error) {
+		_, value, rest, truncated, err := decodePartialTLV(*data)
+		if err != nil {
+			return nil, err
+		}
+		if truncated {
+			return nil, NewParseError("truncated header", -1)
+		}
+		*data = rest
+		return value, nil
+	}
+
+	msg := &Message{}
+	versionData, err := field(&seqData)
+	if err != nil {
+		return nil, truncated, err
+	}
+	msg.Version = SNMPVersion(decodeInteger(versionData))
+
+	communityData, err := field(&seqData)
+	if err != nil {
+		return nil, truncated, err
+	}
+	msg.Community = string(communityData)
+
+	pduType, pduData, _, _, err := decodePartialTLV(seqData)
+	if err != nil {
+		return nil, truncated, err
+	}
+	msg.PDU = &PDU{Type: PDUType(pduType)}
+
+	requestIDData, err := field(&pduData)
+	if err != nil {
+		return nil, truncated, err
+	}
+	msg.PDU.RequestID = int32(decodeInteger(requestIDData))
+
+	errStatusData, err := field(&pduData)
+	if err != nil {
+		return nil, truncated, err
+	}
+	errIndexData, err := field(&pduData)
+	if err != nil {
+		return nil, truncated, err
+	}
+	if pduType == TypeGetBulkRequest {
+		msg.PDU.NonRepeaters = int(decodeInteger(errStatusData))
+		msg.PDU.MaxRepetitions = int(decodeInteger(errIndexData))
+	} else {
+		msg.PDU.ErrorStatus = ErrorStatus(decodeInteger(errStatusData))
+		msg.PDU.ErrorIndex = int(decodeInteger(errIndexData))
+	}
+
+	vars, varsTruncated, err := DecodeVariablesLenient(pduData)
+	if err != nil {
+		return nil, truncated, err
+	}
+	msg.PDU.Variables = vars
+
+	return msg, truncated || varsTruncated, nil
+}
+
+// ScopedPDU represents an SNMPv3 scoped PDU (RFC 3412): a PDU qualified by
+// the context it applies to.
+type ScopedPDU struct {
+	ContextEngineID []byte
+	ContextName     string
+	PDU             *PDU
+}
+
+// Encode encodes the scoped PDU to bytes.
+func (s *ScopedPDU) Encode() ([]byte, error) {
+	var buf bytes.Buffer
+
+	// Context engine ID
+	buf.Write(encodeTLV(TypeOctetString, s.ContextEngineID))
+
+	// Context name
+	buf.Write(encodeTLV(TypeOctetString, []byte(s.ContextName)))
+
+	// PDU
+	pduBytes, err := s.PDU.Encode()
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(pduBytes)
+
+	return encodeTLV(TypeSequence, buf.Bytes()), nil
+}
+
+// DecodeScopedPDU decodes an SNMPv3 scoped PDU from bytes.
+func DecodeScopedPDU(data []byte) (*ScopedPDU, error) {
+	r := bytes.NewReader(data)
+
+	seqType, seqData, err := decodeTLV(r)
+	if err != nil {
+		return nil, err
+	}
+	if seqType != TypeSequence {
+		return nil, NewParseError(fmt.Sprintf("expected sequence, got %s", seqType), -1)
+	}
+
+	seqReader := bytes.NewReader(seqData)
+	scoped := &ScopedPDU{}
+
+	// Context engine ID
+	idType, idData, err := decodeTLV(seqReader)
+	if err != nil {
+		return nil, err
+	}
+	if idType != TypeOctetString {
+		return nil, NewParseError(fmt.Sprintf("expected context engine ID, got %s", idType), -1)
+	}
+	scoped.ContextEngineID = idData
+
+	// Context name
+	nameType, nameData, err := decodeTLV(seqReader)
+	if err != nil {
+		return nil, err
+	}
+	if nameType != TypeOctetString {
+		return nil, NewParseError(fmt.Sprintf("expected context name, got %s", nameType), -1)
+	}
+	scoped.ContextName = string(nameData)
+
+	// PDU
+	scoped.PDU, err = decodePDU(seqReader)
+	if err != nil {
+		return nil, err
+	}
+
+	return scoped, nil
+}
+
+// redactCommunity returns a copy of an encoded message with the community
+// string overwritten by asterisks of the same length. Messages without a
+// community (SNMPv3) are copied unchanged. It returns nil if the message
+// header cannot be parsed, since the community may then not be located.
+func redactCommunity(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+	r := bytes.NewReader(out)
+
+	// Outer sequence header
+	if _, err := r.ReadByte(); err != nil {
+		return nil
+	}
+	if _, err := decodeLength(r); err != nil {
+		return nil
+	}
+
+	// Version
+	if _, _, err := decodeTLV(r); err != nil {
+		return nil
+	}
+
+	// Community
+	typeByte, err := r.ReadByte()
+	if err != nil {
+		return nil
+	}
+	if BERType(typeByte) != TypeOctetString {
+		return out
+	}
+	length, err := decodeLength(r)
+	if err != nil {
+		return nil
+	}
+	start := len(out) - r.Len()
+	if start+length > len(out) {
+		return nil
+	}
+	for i := start; i < start+length; i++ {
+		out[i] = '*'
+	}
+
+	return out
+}
+
 // TrapV1PDU represents an SNMPv1 Trap PDU.
 type TrapV1PDU struct {
 	Enterprise   OID
@@ -207,7 +418,11 @@ func (t *TrapV1PDU) Encode() ([]byte, error) {
 	var buf bytes.Buffer
 
 	// Enterprise OID
-	buf.Write(encodeTLV(TypeObjectIdentifier, encodeOID(t.Enterprise)))
+	enterprise, err := encodeOID(t.Enterprise)
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(encodeTLV(TypeObjectIdentifier, enterprise))
 
 	// Agent address (IP)
 	buf.Write(encodeTLV(TypeIPAddress, t.AgentAddress))
@@ -283,6 +498,9 @@ func DecodeTrapV1PDU(data []byte) (*TrapV1PDU, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := checkUnsignedLength(tsData, 4); err != nil {
+		return nil, err
+	}
 	trap.Timestamp = uint32(decodeUnsignedInteger(tsData))
 
 	// Variable bindings
@@ -430,6 +648,14 @@ func NewSetRequest(requestID int32, variables ...Variable) *PDU {
 	}
 }
 
+// NewInformRequest creates a new InformRequest PDU. Like SNMPv2c traps,
+// informs carry sysUpTime and snmpTrapOID as the first two varbinds.
+func NewInformRequest(requestID int32, sysUpTime uint32, trapOID OID, variables ...Variable) *PDU {
+	pdu := NewTrapV2(requestID, sysUpTime, trapOID, variables...)
+	pdu.Type = PDUInformRequest
+	return pdu
+}
+
 // NewTrapV2 creates a new SNMPv2c trap PDU.
 func NewTrapV2(requestID int32, sysUpTime uint32, trapOID OID, variables ...Variable) *PDU {
 	// SNMPv2c traps include sysUpTime and snmpTrapOID as first two varbinds
@@ -453,6 +679,30 @@ func NewTrapV2(requestID int32, sysUpTime uint32, trapOID OID, variables ...Vari
 	}
 }
 
+// validatePDU checks that pdu may be sent with the given SNMP version.
+func validatePDU(version SNMPVersion, pdu *PDU) error {
+	switch pdu.Type {
+	case PDUGetBulkRequest, PDUInformRequest, PDUTrapV2:
+		if version == Version1 {
+			return fmt.Errorf("%w: %s not supported in %s", ErrInvalidPDU, pdu.Type, version)
+		}
+	case PDUTrapV1:
+		if version != Version1 {
+			return fmt.Errorf("%w: %s not supported in %s", ErrInvalidPDU, pdu.Type, version)
+		}
+	}
+
+	if version == Version1 {
+		for _, v := range pdu.Variables {
+			if v.Type == TypeCounter64 {
+				return fmt.Errorf("%w: Counter64 value for %s not supported in %s", ErrInvalidVersion, v.OID, version)
+			}
+		}
+	}
+
+	return nil
+}
+
 // Helper to create a packet with request ID as big-endian bytes
 func writeInt32(buf *bytes.Buffer, value int32) {
 	b := make([]byte, 4)
@@ -0,0 +1,110 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// BackoffPoller invokes a poll function on a fixed interval with jitter,
+// backing off exponentially while the function keeps failing and returning
+// to the base interval after the first success.
+//
+// Delays are measured from the start of each poll, so slow polls do not make
+// the schedule drift.
+type BackoffPoller struct {
+	interval time.Duration
+	opts     *PollerOptions
+	failures int
+}
+
+// NewBackoffPoller creates a poller with the given base interval.
+func NewBackoffPoller(interval time.Duration, opts ...PollerOption) *BackoffPoller {
+	options := NewPollerOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return &BackoffPoller{
+		interval: interval,
+		opts:     options,
+	}
+}
+
+// Run calls fn immediately and then once per interval until ctx is done,
+// returning the context error. An error from fn only affects the delay
+// before the next poll.
+func (p *BackoffPoller) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		start := time.Now()
+		err := fn(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		wait := p.next(err) - time.Since(start)
+		if wait < 0 {
+			wait = 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+// next records the outcome of a poll and returns the delay until the next
+// one, measured from the start of the poll.
+func (p *BackoffPoller) next(err error) time.Duration {
+	if err == nil {
+		p.failures = 0
+	} else {
+		p.failures++
+	}
+
+	delay := p.interval
+	maxInterval := p.opts.MaxInterval
+	if maxInterval < p.interval {
+		maxInterval = p.interval
+	}
+	for i := 0; i < p.failures && p.opts.Multiplier > 1; i++ {
+		delay = time.Duration(float64(delay) * p.opts.Multiplier)
+		if delay >= maxInterval {
+			delay = maxInterval
+			break
+		}
+	}
+
+	return jitter(delay, p.opts.Jitter)
+}
+
+// jitter spreads d uniformly over [d*(1-fraction), d*(1+fraction)].
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || d <= 0 {
+		return d
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	return time.Duration(float64(d) * (1 + fraction*(2*rand.Float64()-1)))
+}
@@ -0,0 +1,104 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJitterBounds(t *testing.T) {
+	const d = time.Second
+	tests := []struct {
+		fraction float64
+		min, max time.Duration
+	}{
+		{0, d, d},
+		{0.1, 900 * time.Millisecond, 1100 * time.Millisecond},
+		{0.5, 500 * time.Millisecond, 1500 * time.Millisecond},
+		{1, 0, 2 * time.Second},
+		{3, 0, 2 * time.Second}, // clamped to 1
+	}
+	for _, tt := range tests {
+		for range 1000 {
+			if got := jitter(d, tt.fraction); got < tt.min || got > tt.max {
+				t.Fatalf("jitter(%v, %v) = %v, want within [%v, %v]", d, tt.fraction, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestBackoffPollerNext(t *testing.T) {
+	p := NewBackoffPoller(time.Second,
+		WithPollerJitter(0),
+		WithPollerMultiplier(2),
+		WithPollerMaxInterval(5*time.Second))
+
+	failed := errors.New("poll failed")
+	steps := []struct {
+		err  error
+		want time.Duration
+	}{
+		{nil, time.Second},
+		{failed, 2 * time.Second},
+		{failed, 4 * time.Second},
+		{failed, 5 * time.Second},
+		{failed, 5 * time.Second},
+		{nil, time.Second},
+		{failed, 2 * time.Second},
+	}
+	for i, step := range steps {
+		if got := p.next(step.err); got != step.want {
+			t.Errorf("step %d (err %v): delay %v, want %v", i, step.err, got, step.want)
+		}
+	}
+}
+
+func TestBackoffPollerRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first poll runs at once; the second would only come after an
+	// hour, so Run must return on cancellation while waiting for it.
+	polled := make(chan struct{}, 2)
+	p := NewBackoffPoller(time.Hour, WithPollerJitter(0))
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Run(ctx, func(context.Context) error {
+			polled <- struct{}{}
+			return nil
+		})
+	}()
+
+	select {
+	case <-polled:
+	case <-time.After(time.Second):
+		t.Fatal("first poll did not run immediately")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+	if n := len(polled); n != 0 {
+		t.Errorf("%d more polls after the first, want none", n)
+	}
+}
@@ -17,6 +17,8 @@ package snmp
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -32,6 +34,11 @@ type Pool struct {
 	done       chan struct{}
 	wg         sync.WaitGroup
 	metrics    *PoolMetrics
+
+	// healthMu and healthCond wake GetContext callers when a client
+	// connects or the pool closes.
+	healthMu   sync.Mutex
+	healthCond *sync.Cond
 }
 
 type poolClient struct {
@@ -49,38 +56,96 @@ func NewPool(opts ...PoolOption) *Pool {
 	}
 
 	p := &Pool{
-		opts:       options,
-		clients:    make([]*poolClient, options.Size),
-		clientOpts: options.ClientOptions,
-		done:       make(chan struct{}),
-		metrics:    &PoolMetrics{},
+		opts:    options,
+		clients: make([]*poolClient, options.Size),
+		done:    make(chan struct{}),
+		metrics: &PoolMetrics{},
 	}
+	p.healthCond = sync.NewCond(&p.healthMu)
+
+	// Chain the caller's OnConnect so that clients reconnecting on their
+	// own also wake GetContext.
+	p.clientOpts = append(slices.Clone(options.ClientOptions), func(o *ClientOptions) {
+		onConnect := o.OnConnect
+		o.OnConnect = func(c *Client) {
+			if onConnect != nil {
+				onConnect(c)
+			}
+			p.signalHealthy()
+		}
+	})
 
 	return p
 }
 
-// Connect initializes all connections in the pool.
+// poolConnectConcurrency bounds the number of clients Connect dials at once.
+const poolConnectConcurrency = 8
+
+// Connect initializes all connections in the pool, dialing up to
+// poolConnectConcurrency clients concurrently. If ctx is cancelled before
+// all dials finish, Connect closes the clients already connected and
+// returns the context error.
 func (p *Pool) Connect(ctx context.Context) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	var firstErr error
-	successCount := 0
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, poolConnectConcurrency)
 
+dial:
 	for i := 0; i < p.opts.Size; i++ {
-		client := NewClient(p.clientOpts...)
-		if err := client.Connect(ctx); err != nil {
-			if firstErr == nil {
-				firstErr = err
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break dial
+		}
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			client := NewClient(p.clientOpts...)
+			if err := client.Connect(ctx); err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+				return
+			}
+
+			p.clients[i] = &poolClient{
+				client:   client,
+				lastUsed: time.Now(),
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		for i, pc := range p.clients {
+			if pc != nil {
+				pc.client.Disconnect(context.Background())
+				p.clients[i] = nil
 			}
-			continue
 		}
+		return err
+	}
 
-		p.clients[i] = &poolClient{
-			client:   client,
-			lastUsed: time.Now(),
+	successCount := 0
+	for _, pc := range p.clients {
+		if pc != nil {
+			successCount++
 		}
-		successCount++
 	}
 
 	p.metrics.TotalClients.Set(int64(successCount))
@@ -102,6 +167,10 @@ func (p *Pool) Close() error {
 	close(p.done)
 	p.wg.Wait()
 
+	// Runs after p.mu is released: GetContext takes the locks in the
+	// opposite order.
+	defer p.signalHealthy()
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -121,8 +190,56 @@ func (p *Pool) Close() error {
 	return lastErr
 }
 
-// Get returns a client from the pool using round-robin selection.
+// Get returns a client from the pool using round-robin selection. It
+// fails at once if no connection is healthy; use GetContext to wait for
+// one instead.
 func (p *Pool) Get() (*Client, error) {
+	client, err := p.pick()
+	if err != nil {
+		return nil, err
+	}
+
+	p.metrics.TotalRequests.Add(1)
+	if client == nil {
+		p.metrics.FailedRequests.Add(1)
+		return nil, errNoHealthyConnections
+	}
+	return client, nil
+}
+
+// GetContext returns a client from the pool like Get, but if all
+// connections are down, such as while they reconnect after a network
+// blip, it waits for one to become healthy until ctx is done.
+func (p *Pool) GetContext(ctx context.Context) (*Client, error) {
+	p.healthMu.Lock()
+	defer p.healthMu.Unlock()
+
+	stop := context.AfterFunc(ctx, p.signalHealthy)
+	defer stop()
+
+	for {
+		client, err := p.pick()
+		if err != nil {
+			return nil, err
+		}
+		if client != nil {
+			p.metrics.TotalRequests.Add(1)
+			return client, nil
+		}
+		if err := ctx.Err(); err != nil {
+			p.metrics.TotalRequests.Add(1)
+			p.metrics.FailedRequests.Add(1)
+			return nil, fmt.Errorf("%w: %w", errNoHealthyConnections, err)
+		}
+		p.healthCond.Wait()
+	}
+}
+
+var errNoHealthyConnections = errors.New("snmp: no healthy connections available")
+
+// pick selects a healthy client using round-robin selection and marks it
+// in flight. It returns nil if none is healthy.
+func (p *Pool) pick() (*Client, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
@@ -130,8 +247,6 @@ func (p *Pool) Get() (*Client, error) {
 		return nil, errors.New("snmp: pool is empty")
 	}
 
-	p.metrics.TotalRequests.Add(1)
-
 	// Round-robin with fallback to first healthy
 	start := atomic.AddUint64(&p.robin, 1) % uint64(len(p.clients))
 
@@ -146,9 +261,15 @@ func (p *Pool) Get() (*Client, error) {
 			return pc.client, nil
 		}
 	}
+	return nil, nil
+}
 
-	p.metrics.FailedRequests.Add(1)
-	return nil, errors.New("snmp: no healthy connections available")
+// signalHealthy wakes the GetContext callers waiting for a connection. It
+// must not be called with p.mu held.
+func (p *Pool) signalHealthy() {
+	p.healthMu.Lock()
+	p.healthCond.Broadcast()
+	p.healthMu.Unlock()
 }
 
 // Release returns a client to the pool (decrements in-flight counter).
@@ -175,6 +296,84 @@ func (p *Pool) GetOIDs(ctx context.Context, oids ...OID) ([]Variable, error) {
 	return client.Get(ctx, oids...)
 }
 
+// GetSharded performs a GET of oids split into chunks of at most MaxOids,
+// fetched concurrently over up to Size pooled connections. Results are
+// returned in request order. The first failing chunk cancels the others
+// and its error is returned.
+func (p *Pool) GetSharded(ctx context.Context, oids ...OID) ([]Variable, error) {
+	chunkSize := p.maxOids()
+
+	var chunks [][]OID
+	for len(oids) > 0 {
+		n := min(chunkSize, len(oids))
+		chunks = append(chunks, oids[:n])
+		oids = oids[n:]
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([][]Variable, len(chunks))
+	sem := make(chan struct{}, p.opts.Size)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+
+dispatch:
+	for i, chunk := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			vars, err := p.GetOIDs(ctx, chunk...)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			results[i] = vars
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var vars []Variable
+	for _, r := range results {
+		vars = append(vars, r...)
+	}
+	return vars, nil
+}
+
+// maxOids returns the per-request OID limit of the pooled clients.
+func (p *Pool) maxOids() int {
+	opts := NewClientOptions()
+	for _, opt := range p.clientOpts {
+		opt(opts)
+	}
+	if opts.MaxOids < 1 {
+		return DefaultMaxOids
+	}
+	return opts.MaxOids
+}
+
 // GetNext performs a GET-NEXT using a pooled connection.
 func (p *Pool) GetNext(ctx context.Context, oids ...OID) ([]Variable, error) {
 	client, err := p.Get()
@@ -219,6 +418,40 @@ func (p *Pool) Walk(ctx context.Context, rootOID OID) ([]Variable, error) {
 	return client.Walk(ctx, rootOID)
 }
 
+// WalkTable retrieves a table using a pooled connection. The connection
+// stays borrowed for the whole table walk.
+func (p *Pool) WalkTable(ctx context.Context, tableOID OID, columns ...int) ([]TableRow, error) {
+	client, err := p.Get()
+	if err != nil {
+		return nil, err
+	}
+	defer p.Release(client)
+
+	return client.WalkTable(ctx, tableOID, columns...)
+}
+
+// CreateRow creates a table row using a pooled connection.
+func (p *Pool) CreateRow(ctx context.Context, entryOID OID, statusColumn int, index OID, columns map[int]Variable) error {
+	client, err := p.Get()
+	if err != nil {
+		return err
+	}
+	defer p.Release(client)
+
+	return client.CreateRow(ctx, entryOID, statusColumn, index, columns)
+}
+
+// DeleteRow deletes a table row using a pooled connection.
+func (p *Pool) DeleteRow(ctx context.Context, entryOID OID, statusColumn int, index OID) error {
+	client, err := p.Get()
+	if err != nil {
+		return err
+	}
+	defer p.Release(client)
+
+	return client.DeleteRow(ctx, entryOID, statusColumn, index)
+}
+
 func (p *Pool) healthChecker() {
 	defer p.wg.Done()
 
@@ -231,6 +464,7 @@ func (p *Pool) healthChecker() {
 			return
 		case <-ticker.C:
 			p.checkHealth()
+			p.signalHealthy()
 		}
 	}
 }
@@ -0,0 +1,159 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// connectCounter is a slog handler counting the clients that connected
+// and disconnected, calling onConnect as each connects.
+type connectCounter struct {
+	connected, disconnected atomic.Int32
+	onConnect               func()
+}
+
+func (h *connectCounter) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *connectCounter) Handle(_ context.Context, r slog.Record) error {
+	switch r.Message {
+	case "connected to SNMP agent":
+		h.connected.Add(1)
+		h.onConnect()
+	case "disconnected from SNMP agent":
+		h.disconnected.Add(1)
+	}
+	return nil
+}
+
+func (h *connectCounter) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *connectCounter) WithGroup(string) slog.Handler      { return h }
+
+func TestPoolConnectCancel(t *testing.T) {
+	const size = 32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	counter := &connectCounter{onConnect: cancel}
+
+	p := NewPool(
+		WithPoolSize(size),
+		WithPoolClientOptions(
+			WithTarget("127.0.0.1"),
+			WithPort(mockAgent(t, sysDescrHandler)),
+			WithTimeout(500*time.Millisecond),
+			WithAutoReconnect(false),
+			WithLogger(slog.New(counter))))
+
+	if err := p.Connect(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Connect error = %v, want %v", err, context.Canceled)
+	}
+	// Only the dials already under way when the first one finished may
+	// complete.
+	connected := counter.connected.Load()
+	if connected == 0 || connected > poolConnectConcurrency {
+		t.Errorf("%d clients connected, want 1 to %d", connected, poolConnectConcurrency)
+	}
+	if got := counter.disconnected.Load(); got != connected {
+		t.Errorf("%d of %d connected clients closed", got, connected)
+	}
+	for i, pc := range p.clients {
+		if pc != nil {
+			t.Errorf("client %d kept after cancellation", i)
+		}
+	}
+}
+
+func TestPoolWalkTable(t *testing.T) {
+	column := func(col, row int) OID { return MustParseOID("1.3.6.1.2.1.2.2.1").Append(col, row) }
+	mib := mibHandler(
+		Variable{OID: column(2, 1), Type: TypeOctetString, Value: []byte("lo")},
+		Variable{OID: column(2, 2), Type: TypeOctetString, Value: []byte("eth0")},
+		Variable{OID: column(8, 1), Type: TypeInteger, Value: 1},
+		Variable{OID: column(8, 2), Type: TypeInteger, Value: 2},
+	)
+
+	// The agent records the in-flight count of each pooled connection
+	// as it answers every request of the table walk.
+	var pool atomic.Pointer[Pool]
+	var mu sync.Mutex
+	var inFlight []string
+	port := mockAgent(t, func(req *Message) *Message {
+		if p := pool.Load(); p != nil {
+			p.mu.RLock()
+			counts := fmt.Sprint(atomic.LoadInt64(&p.clients[0].inFlight), atomic.LoadInt64(&p.clients[1].inFlight))
+			p.mu.RUnlock()
+			mu.Lock()
+			inFlight = append(inFlight, counts)
+			mu.Unlock()
+		}
+		return mib(req)
+	})
+
+	p := NewPool(
+		WithPoolSize(2),
+		WithPoolClientOptions(
+			WithTarget("127.0.0.1"),
+			WithPort(port),
+			WithTimeout(500*time.Millisecond),
+			WithRetries(0),
+			WithMaxRepetitions(1)))
+	if err := p.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	pool.Store(p)
+
+	rows, err := p.WalkTable(context.Background(), MustParseOID("1.3.6.1.2.1.2.2"), 2, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("WalkTable returned %d rows, want 2", len(rows))
+	}
+	for i, want := range []string{"lo", "eth0"} {
+		descr, status := rows[i].Columns[2], rows[i].Columns[8]
+		if got := descr.AsString(); got != want {
+			t.Errorf("row %d ifDescr = %q, want %q", i, got, want)
+		}
+		if got, _ := status.AsInt(); got != int64(i+1) {
+			t.Errorf("row %d ifOperStatus = %d, want %d", i, got, i+1)
+		}
+	}
+
+	// One connection stays borrowed for every request of the walk and
+	// is returned at the end.
+	mu.Lock()
+	defer mu.Unlock()
+	if len(inFlight) < 2 {
+		t.Fatalf("walk sent %d requests", len(inFlight))
+	}
+	for i, counts := range inFlight {
+		if counts != inFlight[0] || (counts != "1 0" && counts != "0 1") {
+			t.Errorf("request %d: in-flight counts %s, want one connection borrowed throughout (first %s)", i, counts, inFlight[0])
+		}
+	}
+	for i, pc := range p.clients {
+		if n := atomic.LoadInt64(&pc.inFlight); n != 0 {
+			t.Errorf("client %d in flight = %d after the walk", i, n)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import "context"
+
+// AgentProfile is a structured summary of an SNMP agent.
+type AgentProfile struct {
+	System     SystemInfo
+	IfNumber   int
+	Interfaces []Interface
+	ORTable    []SysOREntry
+}
+
+// SystemInfo contains the objects of the MIB-II system group.
+type SystemInfo struct {
+	Descr    string
+	ObjectID OID
+	UpTime   uint32
+	Contact  string
+	Name     string
+	Location string
+	Services int
+}
+
+// SysOREntry is a single sysORTable row.
+type SysOREntry struct {
+	Index  int
+	ID     OID
+	Descr  string
+	UpTime uint32
+}
+
+// sysORTable and its columns.
+var oidSysORTable = MustParseOID("1.3.6.1.2.1.1.9")
+
+const (
+	sysORColID     = 2
+	sysORColDescr  = 3
+	sysORColUpTime = 4
+)
+
+// Profile gathers the system group, the ifTable and the sysORTable
+// from the agent. Objects the agent does not implement are left at
+// their zero value rather than failing the whole profile.
+func (c *Client) Profile(ctx context.Context) (*AgentProfile, error) {
+	profile := &AgentProfile{}
+
+	system, err := c.getPartial(ctx,
+		OIDSysDescr, OIDSysObjectID, OIDSysUpTime, OIDSysContact,
+		OIDSysName, OIDSysLocation, OIDSysServices, OIDIfNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range system {
+		switch {
+		case v.OID.Equal(OIDSysDescr):
+			profile.System.Descr = v.AsString()
+		case v.OID.Equal(OIDSysObjectID):
+			if oid, ok := v.Value.(OID); ok {
+				profile.System.ObjectID = oid
+			}
+		case v.OID.Equal(OIDSysUpTime):
+			if n, ok := v.AsUint(); ok {
+				profile.System.UpTime = uint32(n)
+			}
+		case v.OID.Equal(OIDSysContact):
+			profile.System.Contact = v.AsString()
+		case v.OID.Equal(OIDSysName):
+			profile.System.Name = v.AsString()
+		case v.OID.Equal(OIDSysLocation):
+			profile.System.Location = v.AsString()
+		case v.OID.Equal(OIDSysServices):
+			if n, ok := v.AsInt(); ok {
+				profile.System.Services = int(n)
+			}
+		case v.OID.Equal(OIDIfNumber):
+			if n, ok := v.AsInt(); ok {
+				profile.IfNumber = int(n)
+			}
+		}
+	}
+
+	profile.Interfaces, err = c.Interfaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	profile.ORTable, err = c.sysORTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+func (c *Client) sysORTable(ctx context.Context) ([]SysOREntry, error) {
+	rows, err := c.WalkTable(ctx, oidSysORTable, sysORColID, sysORColDescr, sysORColUpTime)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]SysOREntry, 0, len(rows))
+	for _, row := range rows {
+		if len(row.Index) != 1 {
+			continue
+		}
+		entry := SysOREntry{Index: row.Index[0]}
+		if v, ok := row.Columns[sysORColID]; ok {
+			if oid, ok := v.Value.(OID); ok {
+				entry.ID = oid
+			}
+		}
+		if v, ok := row.Columns[sysORColDescr]; ok {
+			entry.Descr = v.AsString()
+		}
+		if v, ok := row.Columns[sysORColUpTime]; ok {
+			if n, ok := v.AsUint(); ok {
+				entry.UpTime = uint32(n)
+			}
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// getPartial performs a GET and drops exception varbinds. SNMPv1 agents
+// reject the whole request with noSuchName when one OID is missing, so in
+// that case each OID is fetched on its own.
+func (c *Client) getPartial(ctx context.Context, oids ...OID) ([]Variable, error) {
+	vars, err := c.Get(ctx, oids...)
+	if err != nil {
+		if !IsNoSuchName(err) {
+			return nil, err
+		}
+		vars = vars[:0]
+		for _, oid := range oids {
+			v, err := c.Get(ctx, oid)
+			if err != nil {
+				if IsNoSuchName(err) {
+					continue
+				}
+				return nil, err
+			}
+			vars = append(vars, v...)
+		}
+	}
+
+	result := vars[:0]
+	for _, v := range vars {
+		if v.Type.IsException() {
+			continue
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+// walkColumn walks a table column, treating a missing table as empty.
+func (c *Client) walkColumn(ctx context.Context, column OID) ([]Variable, error) {
+	vars, err := c.Walk(ctx, column)
+	if err != nil && !IsNoSuchName(err) {
+		return nil, err
+	}
+	return vars, nil
+}
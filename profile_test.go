@@ -0,0 +1,59 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProfilePartial(t *testing.T) {
+	// A device with most of the system group, no ifTable and a
+	// single-row sysORTable.
+	port := mockAgent(t, mibHandler(
+		Variable{OID: OIDSysDescr, Type: TypeOctetString, Value: []byte("edge gateway")},
+		Variable{OID: OIDSysObjectID, Type: TypeObjectIdentifier, Value: MustParseOID("1.3.6.1.4.1.99999.1")},
+		Variable{OID: OIDSysUpTime, Type: TypeTimeTicks, Value: uint32(4200)},
+		Variable{OID: OIDSysName, Type: TypeOctetString, Value: []byte("gw1")},
+		Variable{OID: MustParseOID("1.3.6.1.2.1.1.9.1.2.1"), Type: TypeObjectIdentifier, Value: MustParseOID("1.3.6.1.6.3.1")},
+		Variable{OID: MustParseOID("1.3.6.1.2.1.1.9.1.3.1"), Type: TypeOctetString, Value: []byte("SNMPv2-MIB")},
+		Variable{OID: MustParseOID("1.3.6.1.2.1.1.9.1.4.1"), Type: TypeTimeTicks, Value: uint32(12)},
+	))
+	c := newTestClient(t, port)
+
+	p, err := c.Profile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.System.Descr != "edge gateway" || p.System.Name != "gw1" || p.System.UpTime != 4200 {
+		t.Errorf("System = %+v", p.System)
+	}
+	if !p.System.ObjectID.Equal(MustParseOID("1.3.6.1.4.1.99999.1")) {
+		t.Errorf("System.ObjectID = %s", p.System.ObjectID)
+	}
+	if p.System.Contact != "" || p.System.Location != "" || p.IfNumber != 0 {
+		t.Errorf("missing objects not left at zero: %+v, IfNumber %d", p.System, p.IfNumber)
+	}
+	if len(p.Interfaces) != 0 {
+		t.Errorf("Interfaces = %v, want none", p.Interfaces)
+	}
+	want := SysOREntry{Index: 1, ID: MustParseOID("1.3.6.1.6.3.1"), Descr: "SNMPv2-MIB", UpTime: 12}
+	if len(p.ORTable) != 1 {
+		t.Fatalf("ORTable = %v, want 1 entry", p.ORTable)
+	}
+	if got := p.ORTable[0]; got.Index != want.Index || !got.ID.Equal(want.ID) || got.Descr != want.Descr || got.UpTime != want.UpTime {
+		t.Errorf("ORTable[0] = %+v, want %+v", got, want)
+	}
+}
@@ -17,10 +17,12 @@ package snmp
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net"
+	"time"
 )
 
 // BER encoding/decoding functions for SNMP packets.
@@ -154,33 +156,75 @@ func decodeUnsignedInteger(data []byte) uint64 {
 	return value
 }
 
-// encodeOID encodes an OID using BER.
-func encodeOID(oid OID) []byte {
+// checkUnsignedLength rejects unsigned integers that do not fit in size
+// octets. One extra leading zero octet is legal BER, used to keep values
+// with the high bit set positive.
+func checkUnsignedLength(data []byte, size int) error {
+	if len(data) > size+1 || (len(data) == size+1 && data[0] != 0) {
+		return fmt.Errorf("%w: %d-octet value for %d-bit unsigned type", ErrMalformedPacket, len(data), size*8)
+	}
+	return nil
+}
+
+// Opaque-wrapped types used by net-snmp to carry SNMPv2 types in SNMPv1:
+// an extension tag octet followed by the application tag plus 0x30.
+const (
+	opaqueTagExtension = 0x9f
+	opaqueTagCounter64 = 0x76
+)
+
+// decodeOpaqueCounter64 decodes a Counter64 wrapped in an Opaque value.
+func decodeOpaqueCounter64(data []byte) (uint64, bool) {
+	if len(data) < 3 || data[0] != opaqueTagExtension || data[1] != opaqueTagCounter64 {
+		return 0, false
+	}
+	length := int(data[2])
+	value := data[3:]
+	if length != len(value) || length == 0 || checkUnsignedLength(value, 8) != nil {
+		return 0, false
+	}
+	return decodeUnsignedInteger(value), true
+}
+
+// maxOIDComponent is the largest sub-identifier value (RFC 2578).
+const maxOIDComponent = math.MaxUint32
+
+// encodeOID encodes an OID using BER. OIDs must have at least two
+// sub-identifiers, since BER packs the first two into one, and every
+// sub-identifier must be within 0..maxOIDComponent.
+func encodeOID(oid OID) ([]byte, error) {
 	if len(oid) < 2 {
-		return nil
+		return nil, fmt.Errorf("%w: %q has fewer than 2 sub-identifiers", ErrInvalidOID, oid.String())
+	}
+	for _, n := range oid {
+		if n < 0 || uint64(n) > maxOIDComponent {
+			return nil, fmt.Errorf("%w: sub-identifier %d out of range in %s", ErrInvalidOID, n, oid)
+		}
+	}
+	if oid[0] > 2 || (oid[0] < 2 && oid[1] >= 40) {
+		return nil, fmt.Errorf("%w: %s has an invalid first arc", ErrInvalidOID, oid)
 	}
 
-	// First two components are combined: first*40 + second
-	buf := []byte{byte(oid[0]*40 + oid[1])}
+	// First two components are combined: first*40 + second. Under arc 2
+	// this may not fit in 32 bits or in a single byte.
+	buf := encodeOIDComponent(uint64(oid[0])*40 + uint64(oid[1]))
 
 	for i := 2; i < len(oid); i++ {
-		buf = append(buf, encodeOIDComponent(oid[i])...)
+		buf = append(buf, encodeOIDComponent(uint64(oid[i]))...)
 	}
 
-	return buf
+	return buf, nil
 }
 
-// encodeOIDComponent encodes a single OID component.
-func encodeOIDComponent(value int) []byte {
+// encodeOIDComponent encodes a single OID component in base 128.
+func encodeOIDComponent(value uint64) []byte {
 	if value < 128 {
 		return []byte{byte(value)}
 	}
 
 	var buf []byte
-	temp := value
-	for temp > 0 {
+	for temp := value; temp > 0; temp >>= 7 {
 		buf = append([]byte{byte(temp & 0x7f)}, buf...)
-		temp >>= 7
 	}
 
 	// Set high bit on all but last byte
@@ -194,19 +238,45 @@ func encodeOIDComponent(value int) []byte {
 // decodeOID decodes a BER OID.
 func decodeOID(data []byte) (OID, error) {
 	if len(data) == 0 {
-		return nil, NewParseError("empty OID", -1)
+		return nil, NewParseError("zero-length OID", -1)
+	}
+	if data[len(data)-1]&0x80 != 0 {
+		return nil, NewParseError("truncated OID sub-identifier", len(data)-1)
 	}
 
-	// First byte contains first two components
-	oid := OID{int(data[0] / 40), int(data[0] % 40)}
+	var oid OID
+	var current uint64
+	for i, b := range data {
+		current = current<<7 | uint64(b&0x7f)
+		if b&0x80 != 0 {
+			// The first sub-identifier may carry 2*40 on top of the arc.
+			if current > (maxOIDComponent+80)>>7 {
+				return nil, NewParseError("OID sub-identifier out of range", i)
+			}
+			continue
+		}
 
-	var current int
-	for i := 1; i < len(data); i++ {
-		current = (current << 7) | int(data[i]&0x7f)
-		if data[i]&0x80 == 0 {
-			oid = append(oid, current)
-			current = 0
+		if oid == nil {
+			// The first sub-identifier holds the first two components
+			switch {
+			case current < 40:
+				oid = OID{0, int(current)}
+			case current < 80:
+				oid = OID{1, int(current - 40)}
+			default:
+				current -= 80
+				if current > maxOIDComponent || int(current) < 0 {
+					return nil, NewParseError("OID sub-identifier out of range", i)
+				}
+				oid = OID{2, int(current)}
+			}
+		} else {
+			if current > maxOIDComponent || int(current) < 0 {
+				return nil, NewParseError("OID sub-identifier out of range", i)
+			}
+			oid = append(oid, int(current))
 		}
+		current = 0
 	}
 
 	return oid, nil
@@ -248,15 +318,57 @@ func decodeTLV(r io.Reader) (BERType, []byte, error) {
 	return berType, value, nil
 }
 
+// decodePartialTLV decodes the TLV at the start of data like decodeTLV,
+// but accepts a TLV cut short by the end of data: it then returns the part
+// of the value that is present, if any, and reports truncated. rest holds
+// the bytes that follow a complete TLV.
+func decodePartialTLV(data []byte) (berType BERType, value, rest []byte, truncated bool, err error) {
+	if len(data) == 0 {
+		return 0, nil, nil, true, nil
+	}
+	berType = BERType(data[0])
+
+	r := bytes.NewReader(data[1:])
+	length, err := decodeLength(r)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return berType, nil, nil, true, nil
+	}
+	if err != nil {
+		return 0, nil, nil, false, err
+	}
+
+	start := len(data) - r.Len()
+	if length > r.Len() {
+		return berType, data[start:], nil, true, nil
+	}
+	return berType, data[start : start+length], data[start+length:], false, nil
+}
+
 // encodeVariable encodes a Variable to BER.
 func encodeVariable(v *Variable) ([]byte, error) {
 	var buf bytes.Buffer
 
 	// Encode OID
-	oidBytes := encodeOID(v.OID)
+	oidBytes, err := encodeOID(v.OID)
+	if err != nil {
+		return nil, err
+	}
 	buf.Write(encodeTLV(TypeObjectIdentifier, oidBytes))
 
-	// Encode value based on type
+	value, err := encodeValue(v)
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(value)
+
+	// Wrap in sequence
+	return encodeTLV(TypeSequence, buf.Bytes()), nil
+}
+
+// encodeValue encodes the value of a Variable as a TLV.
+func encodeValue(v *Variable) ([]byte, error) {
+	var buf bytes.Buffer
+
 	switch v.Type {
 	case TypeNull:
 		buf.Write(encodeTLV(TypeNull, nil))
@@ -275,6 +387,8 @@ func encodeVariable(v *Variable) ([]byte, error) {
 			data = val
 		case string:
 			data = []byte(val)
+		case nil:
+			// A zero-length string, which is distinct from NULL.
 		default:
 			return nil, fmt.Errorf("invalid octet string value: %v", v.Value)
 		}
@@ -285,7 +399,11 @@ func encodeVariable(v *Variable) ([]byte, error) {
 		if !ok {
 			return nil, fmt.Errorf("invalid OID value: %v", v.Value)
 		}
-		buf.Write(encodeTLV(TypeObjectIdentifier, encodeOID(oid)))
+		oidValue, err := encodeOID(oid)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodeTLV(TypeObjectIdentifier, oidValue))
 
 	case TypeIPAddress:
 		var ip net.IP
@@ -321,18 +439,37 @@ func encodeVariable(v *Variable) ([]byte, error) {
 		buf.Write(encodeTLV(TypeCounter64, encodeUnsignedInteger(val)))
 
 	case TypeOpaque:
-		data, ok := v.Value.([]byte)
-		if !ok {
+		var data []byte
+		switch val := v.Value.(type) {
+		case []byte:
+			// Pre-encoded BER
+			data = val
+		case *Variable:
+			if val.Type == TypeOpaque || val.Type.IsException() {
+				return nil, fmt.Errorf("invalid opaque value: cannot wrap %s", val.Type)
+			}
+			inner, err := encodeValue(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid opaque value: %w", err)
+			}
+			data = inner
+		default:
 			return nil, fmt.Errorf("invalid opaque value: %v", v.Value)
 		}
 		buf.Write(encodeTLV(TypeOpaque, data))
 
+	case TypeNsapAddress:
+		data, ok := v.Value.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("invalid NSAP address value: %v", v.Value)
+		}
+		buf.Write(encodeTLV(TypeNsapAddress, data))
+
 	default:
 		return nil, fmt.Errorf("unsupported type: %s", v.Type)
 	}
 
-	// Wrap in sequence
-	return encodeTLV(TypeSequence, buf.Bytes()), nil
+	return buf.Bytes(), nil
 }
 
 // decodeVariable decodes a Variable from BER data.
@@ -370,50 +507,65 @@ func decodeVariable(data []byte) (*Variable, error) {
 	}
 
 	v := &Variable{
-		OID:  oid,
-		Type: valType,
+		OID:      oid,
+		Type:     valType,
+		RawValue: valData,
+	}
+
+	v.Value, err = decodeValue(valType, valData)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// decodeValue converts the BER value bytes of a varbind to its Go value.
+func decodeValue(valType BERType, valData []byte) (interface{}, error) {
+	if valType.IsException() {
+		return nil, nil
 	}
 
 	switch valType {
 	case TypeNull:
-		v.Value = nil
+		return nil, nil
 
 	case TypeInteger:
-		v.Value = int(decodeInteger(valData))
+		return int(decodeInteger(valData)), nil
 
 	case TypeOctetString:
-		v.Value = valData
+		return valData, nil
 
 	case TypeObjectIdentifier:
-		v.Value, err = decodeOID(valData)
-		if err != nil {
-			return nil, err
-		}
+		return decodeOID(valData)
 
 	case TypeIPAddress:
 		if len(valData) == 4 {
-			v.Value = net.IP(valData)
-		} else {
-			v.Value = valData
+			return net.IP(valData), nil
 		}
+		return valData, nil
 
 	case TypeCounter32, TypeGauge32, TypeTimeTicks, TypeUInteger32:
-		v.Value = uint32(decodeUnsignedInteger(valData))
+		if err := checkUnsignedLength(valData, 4); err != nil {
+			return nil, err
+		}
+		return uint32(decodeUnsignedInteger(valData)), nil
 
 	case TypeCounter64:
-		v.Value = decodeUnsignedInteger(valData)
+		if err := checkUnsignedLength(valData, 8); err != nil {
+			return nil, err
+		}
+		return decodeUnsignedInteger(valData), nil
 
 	case TypeOpaque:
-		v.Value = valData
+		return valData, nil
 
-	case TypeNoSuchObject, TypeNoSuchInstance, TypeEndOfMibView:
-		v.Value = nil
+	case TypeNsapAddress:
+		return valData, nil
 
 	default:
-		v.Value = valData
+		return valData, nil
 	}
-
-	return v, nil
 }
 
 // decodeVariables decodes a list of variables from BER data.
@@ -464,53 +616,57 @@ func decodeVariables(data []byte) ([]Variable, error) {
 		}
 
 		v := Variable{
-			OID:  oid,
-			Type: valType,
+			OID:      oid,
+			Type:     valType,
+			RawValue: valData,
 		}
 
-		switch valType {
-		case TypeNull:
-			v.Value = nil
-
-		case TypeInteger:
-			v.Value = int(decodeInteger(valData))
-
-		case TypeOctetString:
-			v.Value = valData
-
-		case TypeObjectIdentifier:
-			v.Value, err = decodeOID(valData)
-			if err != nil {
-				return nil, err
-			}
-
-		case TypeIPAddress:
-			if len(valData) == 4 {
-				v.Value = net.IP(valData)
-			} else {
-				v.Value = valData
-			}
-
-		case TypeCounter32, TypeGauge32, TypeTimeTicks, TypeUInteger32:
-			v.Value = uint32(decodeUnsignedInteger(valData))
+		v.Value, err = decodeValue(valType, valData)
+		if err != nil {
+			return nil, err
+		}
 
-		case TypeCounter64:
-			v.Value = decodeUnsignedInteger(valData)
+		variables = append(variables, v)
+	}
 
-		case TypeOpaque:
-			v.Value = valData
+	return variables, nil
+}
 
-		case TypeNoSuchObject, TypeNoSuchInstance, TypeEndOfMibView:
-			v.Value = nil
+// DecodeVariablesLenient decodes a BER varbind list that may have been
+// cut short, such as the tail of a datagram clipped in transit. It returns
+// the varbinds that are complete and reports whether the list was
+// truncated. Malformed varbinds are still an error.
+func DecodeVariablesLenient(data []byte) ([]Variable, bool, error) {
+	seqType, seqData, _, truncated, err := decodePartialTLV(data)
+	if err != nil {
+		return nil, false, err
+	}
+	if seqData == nil && truncated {
+		return nil, true, nil
+	}
+	if seqType != TypeSequence {
+		return nil, false, NewParseError(fmt.Sprintf("expected sequence, got %s", seqType), -1)
+	}
 
-		default:
-			v.Value = valData
+	var variables []Variable
+	for len(seqData) > 0 {
+		_, _, rest, vbTruncated, err := decodePartialTLV(seqData)
+		if err != nil {
+			return nil, false, err
+		}
+		if vbTruncated {
+			return variables, true, nil
 		}
 
-		variables = append(variables, v)
+		v, err := decodeVariable(seqData[:len(seqData)-len(rest)])
+		if err != nil {
+			return nil, false, err
+		}
+		variables = append(variables, *v)
+		seqData = rest
 	}
 
-	return variables, nil
+	return variables, truncated, nil
 }
 
 // encodeVariableBindings encodes a list of variables to a varbind list.
@@ -564,5 +720,37 @@ func TimeTicksToString(ticks uint32) string {
 	return fmt.Sprintf("%02d:%02d:%02d.%02d", hours, minutes, seconds, centiseconds)
 }
 
+// TimeTicksWrapWindow is the largest elapsed time that TimeTicksDelta
+// attributes to a 2^32 wrap (~497 days) rather than to a reboot when the
+// current reading is lower than the previous one.
+const TimeTicksWrapWindow = 24 * time.Hour
+
+// TimeTicksDelta returns the time elapsed between two sysUpTime readings.
+// A decreasing reading is treated as a counter wrap if the wrapped delta is
+// within TimeTicksWrapWindow; otherwise the agent is considered rebooted
+// and the delta is the current uptime.
+func TimeTicksDelta(prev, cur uint32) (time.Duration, bool) {
+	if cur >= prev {
+		return ticksToDuration(uint64(cur - prev)), false
+	}
+
+	wrapped := ticksToDuration(uint64(math.MaxUint32-prev) + uint64(cur) + 1)
+	if wrapped <= TimeTicksWrapWindow {
+		return wrapped, false
+	}
+	return ticksToDuration(uint64(cur)), true
+}
+
+// DetectReboot reports whether the agent rebooted between two sysUpTime
+// readings.
+func DetectReboot(prev, cur uint32) bool {
+	_, rebooted := TimeTicksDelta(prev, cur)
+	return rebooted
+}
+
+func ticksToDuration(ticks uint64) time.Duration {
+	return time.Duration(ticks) * 10 * time.Millisecond
+}
+
 // MaxInt32 is the maximum value for int32
 const MaxInt32 = math.MaxInt32
@@ -0,0 +1,321 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestOIDRoundTrip(t *testing.T) {
+	for _, s := range []string{
+		"1.3.6.1.2.1.1.1.0",
+		"1.3.6.1.4.1.4294967295",
+		"0.39",
+		"2.999.3",
+		"2.4294967215",
+	} {
+		oid := MustParseOID(s)
+		data, err := encodeOID(oid)
+		if err != nil {
+			t.Errorf("encodeOID(%s): %v", s, err)
+			continue
+		}
+		got, err := decodeOID(data)
+		if err != nil {
+			t.Errorf("decodeOID(% x): %v", data, err)
+			continue
+		}
+		if !got.Equal(oid) {
+			t.Errorf("round trip of %s = %s", s, got)
+		}
+	}
+}
+
+func TestEncodeOIDInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		oid  OID
+	}{
+		{"empty", OID{}},
+		{"single arc", OID{1}},
+		{"sub-identifier above 32 bits", OID{1, 3, 6, 1, 4, 1, 4294967296}},
+		{"negative sub-identifier", OID{1, 3, -1}},
+		{"first arc above 2", OID{3, 1}},
+		{"second arc above 39", OID{1, 40}},
+	}
+	for _, tt := range tests {
+		if _, err := encodeOID(tt.oid); !errors.Is(err, ErrInvalidOID) {
+			t.Errorf("%s: encodeOID error = %v, want %v", tt.name, err, ErrInvalidOID)
+		}
+	}
+}
+
+func TestDecodeOIDInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated", []byte{0x2b, 0x86}},
+		// 1.3 followed by 4294967296 (2^32).
+		{"sub-identifier above 32 bits", []byte{0x2b, 0x90, 0x80, 0x80, 0x80, 0x00}},
+		// First sub-identifier 2*40 + 2^32.
+		{"second arc above 32 bits", []byte{0x90, 0x80, 0x80, 0x80, 0x50}},
+	}
+	for _, tt := range tests {
+		var perr *ParseError
+		if oid, err := decodeOID(tt.data); !errors.As(err, &perr) {
+			t.Errorf("%s: decodeOID = %v, %v, want a parse error", tt.name, oid, err)
+		}
+	}
+}
+
+// TestShortOIDsInMessages checks that empty and single-element OIDs are
+// rejected as varbind names and values rather than encoded lossily.
+func TestShortOIDsInMessages(t *testing.T) {
+	for _, oid := range []OID{nil, {1}} {
+		for _, v := range []Variable{
+			{OID: oid, Type: TypeNull},
+			{OID: OIDSysObjectID, Type: TypeObjectIdentifier, Value: oid},
+		} {
+			msg := &Message{Version: Version2c, Community: "public", PDU: &PDU{Type: PDUGetResponse, Variables: []Variable{v}}}
+			if data, err := msg.Encode(); !errors.Is(err, ErrInvalidOID) {
+				t.Errorf("encoding %+v = % x, %v, want %v", v, data, err, ErrInvalidOID)
+			}
+		}
+	}
+
+	for name, packet := range map[string]string{
+		// A varbind named by a zero-length OID.
+		"empty name": "301e0201010406" + "7075626c6963" + "a211020101020100020100" + "3006" + "3004" + "0600" + "0500",
+		// sysObjectID = a zero-length OID.
+		"empty value": "30210201010406" + "7075626c6963" + "a214020101020100020100" + "3009" + "3007" + "06032b0601" + "0600",
+	} {
+		data, err := hex.DecodeString(packet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var perr *ParseError
+		if msg, err := DecodeMessage(data); !errors.As(err, &perr) {
+			t.Errorf("%s: DecodeMessage = %+v, %v, want a parse error", name, msg, err)
+		}
+	}
+}
+
+func TestDecodeVariablesLenient(t *testing.T) {
+	vars := []Variable{
+		{OID: MustParseOID("1.3.6.1.2.1.1.1.0"), Type: TypeOctetString, Value: []byte("edge gateway")},
+		{OID: MustParseOID("1.3.6.1.2.1.1.3.0"), Type: TypeTimeTicks, Value: uint32(4200)},
+		{OID: MustParseOID("1.3.6.1.2.1.1.5.0"), Type: TypeOctetString, Value: []byte("gw1")},
+	}
+	data, err := encodeVariableBindings(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Offsets of the varbind boundaries, after the 2-octet list header.
+	ends := []int{2}
+	for _, v := range vars {
+		vb, err := encodeVariable(&v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ends = append(ends, ends[len(ends)-1]+len(vb))
+	}
+
+	tests := []struct {
+		name          string
+		size          int
+		want          int
+		wantTruncated bool
+	}{
+		{"complete", len(data), 3, false},
+		{"at the last boundary", ends[2], 2, true},
+		{"in the last varbind", ends[2] + 5, 2, true},
+		{"at the first boundary", ends[1], 1, true},
+		{"in the first varbind", ends[0] + 1, 0, true},
+		{"in the list header", 1, 0, true},
+	}
+	for _, tt := range tests {
+		got, truncated, err := DecodeVariablesLenient(data[:tt.size])
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(got) != tt.want || truncated != tt.wantTruncated {
+			t.Errorf("%s: %d variables, truncated %t, want %d, %t", tt.name, len(got), truncated, tt.want, tt.wantTruncated)
+		}
+		for i := range got {
+			if !got[i].OID.Equal(vars[i].OID) {
+				t.Errorf("%s: variable %d is %s, want %s", tt.name, i, got[i].OID, vars[i].OID)
+			}
+		}
+	}
+
+	// A strict decode of the same truncated list fails as a whole.
+	if _, err := decodeVariables(data[:ends[2]+5]); err == nil {
+		t.Error("decodeVariables accepted a truncated list")
+	}
+}
+
+func TestEncodeEmptyOctetString(t *testing.T) {
+	for _, value := range []any{nil, []byte{}, ""} {
+		data, err := encodeVariable(&Variable{OID: OIDSysLocation, Type: TypeOctetString, Value: value})
+		if err != nil {
+			t.Errorf("encoding %#v: %v", value, err)
+			continue
+		}
+		if !bytes.HasSuffix(data, []byte{byte(TypeOctetString), 0}) {
+			t.Errorf("%#v encoded as % x, want a zero-length OCTET STRING", value, data)
+		}
+	}
+}
+
+func TestOpaqueRoundTrip(t *testing.T) {
+	for _, inner := range []Variable{
+		{Type: TypeInteger, Value: -42},
+		{Type: TypeCounter64, Value: uint64(1) << 40},
+		{Type: TypeOctetString, Value: []byte("nested")},
+	} {
+		sent := Variable{OID: MustParseOID("1.3.6.1.4.1.99999.1.0"), Type: TypeOpaque, Value: &inner}
+		data, err := (&Message{Version: Version2c, Community: "public", PDU: &PDU{
+			Type:      PDUSetRequest,
+			Variables: []Variable{sent},
+		}}).Encode()
+		if err != nil {
+			t.Errorf("encoding an opaque %s: %v", inner.Type, err)
+			continue
+		}
+		msg, err := DecodeMessage(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := msg.PDU.Variables[0]
+		if got.Type != TypeOpaque {
+			t.Errorf("opaque %s decoded as %s", inner.Type, got.Type)
+			continue
+		}
+		value, ok := got.OpaqueValue()
+		if !ok {
+			t.Errorf("opaque %s: OpaqueValue failed on % x", inner.Type, got.AsBytes())
+			continue
+		}
+		if value.Type != inner.Type || fmt.Sprint(value.Value) != fmt.Sprint(inner.Value) || !value.OID.Equal(sent.OID) {
+			t.Errorf("opaque %s round trip = %s %v %v", inner.Type, value.OID, value.Type, value.Value)
+		}
+
+		// The pre-encoded form of the same value encodes identically.
+		raw, err := encodeVariable(&Variable{OID: sent.OID, Type: TypeOpaque, Value: got.AsBytes()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nested, err := encodeVariable(&sent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(raw, nested) {
+			t.Errorf("opaque %s: nested encoding % x, pre-encoded % x", inner.Type, nested, raw)
+		}
+	}
+}
+
+func TestOpaqueInvalid(t *testing.T) {
+	for _, inner := range []Variable{
+		{Type: TypeOpaque, Value: []byte{0x02, 0x01, 0x01}},
+		{Type: TypeNoSuchObject},
+		{Type: TypeInteger, Value: "not a number"},
+	} {
+		v := Variable{OID: OIDSysDescr, Type: TypeOpaque, Value: &inner}
+		if data, err := encodeVariable(&v); err == nil {
+			t.Errorf("opaque %s %v encoded as % x, want an error", inner.Type, inner.Value, data)
+		}
+	}
+
+	for _, data := range [][]byte{
+		{},
+		{0x02, 0x01, 0x01, 0x00},       // trailing octet
+		{0x44, 0x03, 0x02, 0x01, 0x01}, // nested Opaque
+		{0x02, 0x05, 0x01},             // truncated
+	} {
+		v := Variable{Type: TypeOpaque, Value: data}
+		if got, ok := v.OpaqueValue(); ok {
+			t.Errorf("OpaqueValue(% x) = %v, want failure", data, got)
+		}
+	}
+}
+
+func TestDecodeUnsignedLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    uint32
+		wantErr bool
+	}{
+		{"4 octets", []byte{0x7f, 0xff, 0xff, 0xff}, 0x7fffffff, false},
+		{"leading zero", []byte{0x00, 0xff, 0xff, 0xff, 0xff}, 0xffffffff, false},
+		{"5 octets", []byte{0x01, 0x00, 0x00, 0x00, 0x00}, 0, true},
+		{"6 octets", []byte{0x00, 0x00, 0xff, 0xff, 0xff, 0xff}, 0, true},
+	}
+	for _, typ := range []BERType{TypeCounter32, TypeGauge32, TypeTimeTicks} {
+		for _, tt := range tests {
+			v, err := decodeValue(typ, tt.data)
+			if tt.wantErr {
+				if !errors.Is(err, ErrMalformedPacket) {
+					t.Errorf("%s %s: error = %v, want %v", typ, tt.name, err, ErrMalformedPacket)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s %s: %v", typ, tt.name, err)
+			} else if v != tt.want {
+				t.Errorf("%s %s = %v, want %d", typ, tt.name, v, tt.want)
+			}
+		}
+	}
+}
+
+func TestTimeTicksDelta(t *testing.T) {
+	const day = 24 * 60 * 60 * 100 // TimeTicks
+	tests := []struct {
+		name       string
+		prev, cur  uint32
+		want       time.Duration
+		wantReboot bool
+	}{
+		{"unchanged", 1000, 1000, 0, false},
+		{"normal", 1000, 1500, 5 * time.Second, false},
+		{"wrap", math.MaxUint32 - 99, 100, 2 * time.Second, false},
+		{"wrap to zero", math.MaxUint32, 0, 10 * time.Millisecond, false},
+		{"wrap at the window", math.MaxUint32 - day + 1, 0, 24 * time.Hour, false},
+		{"beyond the window", math.MaxUint32 - day, 0, 0, true},
+		{"reboot", 5000000, 3000, 30 * time.Second, true},
+	}
+	for _, tt := range tests {
+		got, rebooted := TimeTicksDelta(tt.prev, tt.cur)
+		if got != tt.want || rebooted != tt.wantReboot {
+			t.Errorf("%s: TimeTicksDelta(%d, %d) = %v, %t, want %v, %t",
+				tt.name, tt.prev, tt.cur, got, rebooted, tt.want, tt.wantReboot)
+		}
+		if DetectReboot(tt.prev, tt.cur) != tt.wantReboot {
+			t.Errorf("%s: DetectReboot(%d, %d) = %t", tt.name, tt.prev, tt.cur, !tt.wantReboot)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// syslogSDID is the structured data ID of forwarded traps. 32473 is the
+// private enterprise number reserved for documentation (RFC 5612).
+const syslogSDID = "snmpTrap@32473"
+
+// SyslogTrapForwarder sends received traps to a syslog collector as
+// RFC 5424 messages. The trap metadata is carried as structured data and
+// the variable bindings as the message text.
+//
+// A broken connection is re-established on the next message, and a failed
+// write is retried once on a fresh connection.
+type SyslogTrapForwarder struct {
+	network string
+	address string
+	opts    *SyslogOptions
+	logger  *slog.Logger
+	now     func() time.Time
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogTrapForwarder creates a forwarder for a collector URL of the
+// form udp://host:port or tcp://host:port. The port defaults to 514 for UDP
+// and 601 for TCP. No connection is made until the first trap is forwarded.
+func NewSyslogTrapForwarder(collector string, opts ...SyslogOption) (*SyslogTrapForwarder, error) {
+	u, err := url.Parse(collector)
+	if err != nil {
+		return nil, fmt.Errorf("snmp: invalid syslog collector %q: %w", collector, err)
+	}
+
+	var defaultPort string
+	switch u.Scheme {
+	case "udp", "udp4", "udp6":
+		defaultPort = "514"
+	case "tcp", "tcp4", "tcp6":
+		defaultPort = "601"
+	default:
+		return nil, fmt.Errorf("snmp: unsupported syslog collector scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("snmp: syslog collector %q has no host", collector)
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+
+	options := NewSyslogOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.Hostname == "" {
+		options.Hostname, _ = os.Hostname()
+	}
+
+	logger := options.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &SyslogTrapForwarder{
+		network: u.Scheme,
+		address: net.JoinHostPort(u.Hostname(), port),
+		opts:    options,
+		logger:  logger,
+		now:     time.Now,
+	}, nil
+}
+
+// Forward sends a trap to the collector.
+func (f *SyslogTrapForwarder) Forward(trap *TrapPDU) error {
+	msg := f.format(trap)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := f.write(msg)
+	if err != nil && f.conn != nil {
+		// The collector may have restarted; retry once on a new connection.
+		f.closeConn()
+		err = f.write(msg)
+	}
+	if err != nil {
+		f.closeConn()
+		return fmt.Errorf("snmp: failed to forward trap to %s: %w", f.address, err)
+	}
+	return nil
+}
+
+// Handle forwards a trap and logs any error. It can be passed directly to
+// NewTrapListener as the trap handler.
+func (f *SyslogTrapForwarder) Handle(trap *TrapPDU) {
+	if err := f.Forward(trap); err != nil {
+		f.logger.Warn("syslog forward failed", "error", err, "source", trap.SourceAddress)
+	}
+}
+
+// Close closes the connection to the collector.
+func (f *SyslogTrapForwarder) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closeConn()
+}
+
+func (f *SyslogTrapForwarder) write(msg string) error {
+	if f.conn == nil {
+		conn, err := net.DialTimeout(f.network, f.address, f.opts.DialTimeout)
+		if err != nil {
+			return err
+		}
+		f.conn = conn
+	}
+
+	// Stream transports need framing; use octet counting (RFC 6587).
+	if strings.HasPrefix(f.network, "tcp") {
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+
+	f.conn.SetWriteDeadline(time.Now().Add(f.opts.DialTimeout))
+	_, err := f.conn.Write([]byte(msg))
+	return err
+}
+
+func (f *SyslogTrapForwarder) closeConn() error {
+	if f.conn == nil {
+		return nil
+	}
+	err := f.conn.Close()
+	f.conn = nil
+	return err
+}
+
+// format renders a trap as an RFC 5424 message.
+func (f *SyslogTrapForwarder) format(trap *TrapPDU) string {
+	var b strings.Builder
+
+	pri := f.opts.Facility*8 + f.opts.Severity
+	fmt.Fprintf(&b, "<%d>1 %s %s %s - trap ",
+		pri,
+		f.now().UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderField(f.opts.Hostname),
+		syslogHeaderField(f.opts.AppName))
+
+	b.WriteString("[" + syslogSDID)
+	writeSDParam(&b, "version", trap.Version.String())
+	writeSDParam(&b, "source", trap.SourceAddress)
+	if len(trap.TrapOID) > 0 {
+		writeSDParam(&b, "trapOID", trap.TrapOID.String())
+	}
+	writeSDParam(&b, "uptime", strconv.FormatUint(uint64(trap.Timestamp), 10))
+	if trap.Version == Version1 {
+		writeSDParam(&b, "enterprise", trap.Enterprise.String())
+		writeSDParam(&b, "agentAddress", trap.AgentAddress)
+		writeSDParam(&b, "generic", strconv.Itoa(trap.GenericTrap))
+		writeSDParam(&b, "specific", strconv.Itoa(trap.SpecificTrap))
+	}
+	b.WriteString("]")
+
+	for i, v := range trap.Variables {
+		if i == 0 {
+			b.WriteString(" ")
+		} else {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(&b, "%s = %s: %s", v.OID, v.Type, syslogValue(v))
+	}
+
+	return b.String()
+}
+
+// syslogHeaderField returns a header field value, or the NILVALUE "-" if
+// it is empty. Header fields are printable US-ASCII without spaces.
+func syslogHeaderField(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// writeSDParam writes a structured data parameter, escaping '"', '\' and
+// ']' as RFC 5424 requires.
+func writeSDParam(b *strings.Builder, name, value string) {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+	fmt.Fprintf(b, ` %s="%s"`, name, value)
+}
+
+// syslogValue renders a variable value for the message text. Octet
+// strings are shown as text when they are printable UTF-8, else as hex.
+func syslogValue(v Variable) string {
+	data, ok := v.Value.([]byte)
+	if !ok {
+		return fmt.Sprint(v.Value)
+	}
+	if utf8.Valid(data) && !strings.ContainsFunc(string(data), func(r rune) bool {
+		return r < ' ' && r != '\t'
+	}) {
+		return string(data)
+	}
+	return hex.EncodeToString(data)
+}
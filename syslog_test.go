@@ -0,0 +1,142 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogTrapForwarderUDP(t *testing.T) {
+	collector, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Close()
+
+	f, err := NewSyslogTrapForwarder("udp://"+collector.LocalAddr().String(),
+		WithSyslogFacility(16), WithSyslogSeverity(5),
+		WithSyslogHostname("nms 1"), WithSyslogAppName("snmptrapd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.now = func() time.Time { return time.Date(2025, 3, 1, 12, 30, 0, 250000000, time.UTC) }
+
+	trap := &TrapPDU{
+		Version:       Version2c,
+		Timestamp:     4200,
+		TrapOID:       OIDSnmpTraps.Append(3),
+		SourceAddress: "192.0.2.1:162",
+		Variables: []Variable{
+			{OID: MustParseOID("1.3.6.1.2.1.2.2.1.1.2"), Type: TypeInteger, Value: 2},
+			{OID: MustParseOID("1.3.6.1.2.1.2.2.1.2.2"), Type: TypeOctetString, Value: []byte(`eth0 "uplink"]`)},
+			{OID: MustParseOID("1.3.6.1.2.1.2.2.1.6.2"), Type: TypeOctetString, Value: []byte{0x00, 0x1a, 0x2b}},
+		},
+	}
+	if err := f.Forward(trap); err != nil {
+		t.Fatal(err)
+	}
+
+	collector.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := collector.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<133>1 2025-03-01T12:30:00.250000Z nms1 snmptrapd - trap ` +
+		`[snmpTrap@32473 version="SNMPv2c" source="192.0.2.1:162" trapOID="1.3.6.1.6.3.1.1.5.3" uptime="4200"] ` +
+		`1.3.6.1.2.1.2.2.1.1.2 = INTEGER: 2; ` +
+		`1.3.6.1.2.1.2.2.1.2.2 = OCTET STRING: eth0 "uplink"]; ` +
+		`1.3.6.1.2.1.2.2.1.6.2 = OCTET STRING: 001a2b`
+	if got := string(buf[:n]); got != want {
+		t.Errorf("message\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestSyslogTrapForwarderReconnect(t *testing.T) {
+	collector, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Close()
+
+	f, err := NewSyslogTrapForwarder("tcp://"+collector.Addr().String(), WithSyslogHostname("nms"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// readMessage accepts a connection and reads one octet-counted message.
+	readMessage := func() string {
+		t.Helper()
+		conn, err := collector.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		r := bufio.NewReader(conn)
+		size, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(size))
+		if err != nil {
+			t.Fatalf("bad frame length %q", size)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			t.Fatal(err)
+		}
+		return string(msg)
+	}
+
+	trap := &TrapPDU{Version: Version2c, TrapOID: OIDSnmpTraps.Append(1), SourceAddress: "192.0.2.1:162"}
+	if err := f.Forward(trap); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readMessage(); !strings.Contains(msg, `trapOID="1.3.6.1.6.3.1.1.5.1"`) {
+		t.Errorf("first message = %q", msg)
+	}
+
+	// The collector has closed the connection. A write into it may still
+	// succeed once before the reset is seen, so keep forwarding until a
+	// new connection is made.
+	accepted := make(chan string, 1)
+	go func() { accepted <- readMessage() }()
+	trap.TrapOID = OIDSnmpTraps.Append(2)
+	for i := 0; ; i++ {
+		if err := f.Forward(trap); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case msg := <-accepted:
+			if !strings.Contains(msg, `trapOID="1.3.6.1.6.3.1.1.5.2"`) {
+				t.Errorf("message after reconnect = %q", msg)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+		if i == 20 {
+			t.Fatal("forwarder did not reconnect")
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// RowStatus is the RFC 2579 RowStatus textual convention.
+type RowStatus int
+
+const (
+	RowStatusActive        RowStatus = 1
+	RowStatusNotInService  RowStatus = 2
+	RowStatusNotReady      RowStatus = 3
+	RowStatusCreateAndGo   RowStatus = 4
+	RowStatusCreateAndWait RowStatus = 5
+	RowStatusDestroy       RowStatus = 6
+)
+
+// String returns the string representation of the row status.
+func (r RowStatus) String() string {
+	switch r {
+	case RowStatusActive:
+		return "active"
+	case RowStatusNotInService:
+		return "notInService"
+	case RowStatusNotReady:
+		return "notReady"
+	case RowStatusCreateAndGo:
+		return "createAndGo"
+	case RowStatusCreateAndWait:
+		return "createAndWait"
+	case RowStatusDestroy:
+		return "destroy"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(r))
+	}
+}
+
+// columnOID returns entry.column.index.
+func columnOID(entry OID, column int, index OID) OID {
+	return entry.Append(column).Append(index...)
+}
+
+// CreateRow creates a conceptual row in a table that uses a RowStatus
+// column. The columns are keyed by column number; their OIDs are derived
+// from entryOID and index. All columns are sent in a single SET together
+// with RowStatus=createAndGo(4) in statusColumn.
+func (c *Client) CreateRow(ctx context.Context, entryOID OID, statusColumn int, index OID, columns map[int]Variable) error {
+	numbers := make([]int, 0, len(columns))
+	for n := range columns {
+		if n == statusColumn {
+			return fmt.Errorf("snmp: column %d is the RowStatus column", n)
+		}
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	vars := make([]Variable, 0, len(columns)+1)
+	for _, n := range numbers {
+		v := columns[n]
+		v.OID = columnOID(entryOID, n, index)
+		vars = append(vars, v)
+	}
+	vars = append(vars, Variable{
+		OID:   columnOID(entryOID, statusColumn, index),
+		Type:  TypeInteger,
+		Value: int(RowStatusCreateAndGo),
+	})
+
+	_, err := c.Set(ctx, vars...)
+	return err
+}
+
+// DeleteRow deletes a conceptual row by setting RowStatus=destroy(6) in
+// statusColumn.
+func (c *Client) DeleteRow(ctx context.Context, entryOID OID, statusColumn int, index OID) error {
+	_, err := c.Set(ctx, Variable{
+		OID:   columnOID(entryOID, statusColumn, index),
+		Type:  TypeInteger,
+		Value: int(RowStatusDestroy),
+	})
+	return err
+}
+
+// TableRow is a conceptual row of an SNMP table.
+type TableRow struct {
+	// Index is the instance identifier of the row.
+	Index OID
+	// Columns holds the row's values keyed by column number.
+	Columns map[int]Variable
+}
+
+// WalkTable retrieves a table and groups its values into rows ordered by
+// index. tableOID is the table object (e.g. ifTable), not its entry. If
+// columns are given only those columns are walked, which saves requests
+// on wide tables. A table the agent does not implement yields no rows.
+func (c *Client) WalkTable(ctx context.Context, tableOID OID, columns ...int) ([]TableRow, error) {
+	entry := tableOID.Append(1)
+
+	roots := []OID{entry}
+	if len(columns) > 0 {
+		roots = roots[:0]
+		for _, col := range columns {
+			roots = append(roots, columnOID(entry, col, nil))
+		}
+	}
+
+	rows := make(map[string]*TableRow)
+	for _, root := range roots {
+		vars, err := c.walkColumn(ctx, root)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range vars {
+			index := v.OID.Index(len(entry))
+			if index == nil {
+				continue
+			}
+			key := index.String()
+			row, ok := rows[key]
+			if !ok {
+				row = &TableRow{
+					Index:   index,
+					Columns: make(map[int]Variable),
+				}
+				rows[key] = row
+			}
+			row.Columns[v.OID[len(entry)]] = v
+		}
+	}
+
+	result := make([]TableRow, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, *row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Index.Compare(result[j].Index) < 0
+	})
+
+	return result, nil
+}
+
+// DiscoverColumns returns the column numbers a table implements, in
+// ascending order, without walking its rows. entryOID is the table entry
+// (e.g. ifEntry), not the table. The result can be passed to WalkTable.
+//
+// It issues one GETNEXT per column: a GETNEXT at the entry returns the
+// first instance of the first column, and a GETNEXT at the following
+// column number skips the remaining rows of that column. A column with no
+// rows is not reported, and a table without rows yields no columns.
+func (c *Client) DiscoverColumns(ctx context.Context, entryOID OID) ([]int, error) {
+	var columns []int
+	next := entryOID
+	for {
+		vars, err := c.GetNext(ctx, next)
+		if err != nil {
+			if IsEndOfMIB(err) || IsNoSuchName(err) || IsNoSuchObject(err) || IsNoSuchInstance(err) {
+				return columns, nil
+			}
+			return nil, err
+		}
+		if len(vars) == 0 || vars[0].Type.IsException() {
+			return columns, nil
+		}
+
+		sub, ok := vars[0].OID.TrimPrefix(entryOID)
+		if !ok || len(sub) == 0 {
+			return columns, nil
+		}
+		column := sub[0]
+		if len(columns) > 0 && column <= columns[len(columns)-1] {
+			return nil, fmt.Errorf("%w: %s after column %d", ErrOIDNotIncreasing, vars[0].OID, columns[len(columns)-1])
+		}
+		columns = append(columns, column)
+		next = entryOID.Append(column + 1)
+	}
+}
+
+// WalkColumn walks a single table column, such as ifOperStatus, and
+// returns its values keyed by the dotted index that follows the column
+// OID: "3" for ifIndex 3, or "10.0.0.1.161" for a multi-part index. It
+// avoids the row assembly of WalkTable when only one column is needed. A
+// column the agent does not implement yields an empty map.
+func (c *Client) WalkColumn(ctx context.Context, columnOID OID) (map[string]Variable, error) {
+	vars, err := c.walkColumn(ctx, columnOID)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]Variable, len(vars))
+	for _, v := range vars {
+		index, ok := v.OID.TrimPrefix(columnOID)
+		if !ok || len(index) == 0 {
+			continue
+		}
+		values[index.String()] = v
+	}
+	return values, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// ParseDateAndTime decodes a DateAndTime textual convention (RFC 2579):
+// an 8-byte local time, or an 11-byte time with its offset from UTC.
+// 8-byte values carry no zone and are returned in UTC.
+func ParseDateAndTime(data []byte) (time.Time, error) {
+	if len(data) != 8 && len(data) != 11 {
+		return time.Time{}, fmt.Errorf("snmp: DateAndTime must be 8 or 11 bytes, got %d", len(data))
+	}
+
+	year := int(binary.BigEndian.Uint16(data[0:2]))
+	month, day := int(data[2]), int(data[3])
+	hour, minute, second, deci := int(data[4]), int(data[5]), int(data[6]), int(data[7])
+
+	if month < 1 || month > 12 || day < 1 || day > 31 ||
+		hour > 23 || minute > 59 || second > 60 || deci > 9 {
+		return time.Time{}, fmt.Errorf("snmp: invalid DateAndTime % X", data)
+	}
+
+	loc := time.UTC
+	if len(data) == 11 {
+		sign := data[8]
+		offHours, offMinutes := int(data[9]), int(data[10])
+		if (sign != '+' && sign != '-') || offHours > 14 || offMinutes > 59 {
+			return time.Time{}, fmt.Errorf("snmp: invalid DateAndTime zone % X", data[8:])
+		}
+		offset := offHours*3600 + offMinutes*60
+		if sign == '-' {
+			offset = -offset
+		}
+		loc = time.FixedZone("", offset)
+	}
+
+	t := time.Date(year, time.Month(month), day, hour, minute, second, deci*int(100*time.Millisecond), loc)
+	if t.Day() != day {
+		return time.Time{}, fmt.Errorf("snmp: invalid DateAndTime % X", data)
+	}
+	return t, nil
+}
+
+// EncodeDateAndTime encodes t as an 11-byte DateAndTime, including its
+// offset from UTC rounded down to the minute.
+func EncodeDateAndTime(t time.Time) []byte {
+	_, offset := t.Zone()
+	sign := byte('+')
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+
+	data := make([]byte, 11)
+	binary.BigEndian.PutUint16(data[0:2], uint16(t.Year()))
+	data[2] = byte(t.Month())
+	data[3] = byte(t.Day())
+	data[4] = byte(t.Hour())
+	data[5] = byte(t.Minute())
+	data[6] = byte(t.Second())
+	data[7] = byte(t.Nanosecond() / int(100*time.Millisecond))
+	data[8] = sign
+	data[9] = byte(offset / 3600)
+	data[10] = byte(offset % 3600 / 60)
+	return data
+}
+
+// FormatMAC formats a PhysAddress as colon-separated lowercase hex, e.g.
+// "00:1a:2b:3c:4d:5e". Any length is accepted; an empty address formats
+// as the empty string.
+func FormatMAC(data []byte) string {
+	const digits = "0123456789abcdef"
+
+	if len(data) == 0 {
+		return ""
+	}
+	buf := make([]byte, 0, len(data)*3-1)
+	for i, b := range data {
+		if i > 0 {
+			buf = append(buf, ':')
+		}
+		buf = append(buf, digits[b>>4], digits[b&0x0f])
+	}
+	return string(buf)
+}
+
+// InetAddressType values (RFC 4001).
+const (
+	InetAddressUnknown = 0
+	InetAddressIPv4    = 1
+	InetAddressIPv6    = 2
+	InetAddressIPv4z   = 3
+	InetAddressIPv6z   = 4
+	InetAddressDNS     = 16
+)
+
+// FormatInetAddress formats an InetAddress (RFC 4001) given the value of
+// its InetAddressType object, which MIBs define as a separate column:
+// dotted-quad for IPv4, RFC 5952 text for IPv6, "%zone" appended for the
+// zoned forms, and the name itself for DNS. An unknown type, or an address
+// whose length does not match its type, is formatted as hex.
+func FormatInetAddress(addrType int, data []byte) string {
+	switch {
+	case addrType == InetAddressIPv4 && len(data) == 4,
+		addrType == InetAddressIPv6 && len(data) == 16:
+		addr, _ := netip.AddrFromSlice(data)
+		return addr.String()
+
+	case addrType == InetAddressIPv4z && len(data) == 8,
+		addrType == InetAddressIPv6z && len(data) == 20:
+		n := len(data) - 4
+		addr, _ := netip.AddrFromSlice(data[:n])
+		return fmt.Sprintf("%s%%%d", addr, binary.BigEndian.Uint32(data[n:]))
+
+	case addrType == InetAddressDNS:
+		return string(data)
+
+	default:
+		return hex.EncodeToString(data)
+	}
+}
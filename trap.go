@@ -16,8 +16,12 @@ package snmp
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math"
 	"net"
+	"strconv"
 	"sync"
 )
 
@@ -30,6 +34,10 @@ type TrapListener struct {
 	done    chan struct{}
 	wg      sync.WaitGroup
 	metrics *Metrics
+	spool   *trapSpool
+
+	// Handler calls in flight, which Stop waits for
+	handlers sync.WaitGroup
 }
 
 // NewTrapListener creates a new trap listener.
@@ -44,6 +52,12 @@ func NewTrapListener(handler TrapHandler, opts ...TrapListenerOption) *TrapListe
 		logger = slog.Default()
 	}
 
+	if options.CryptoProvider != nil {
+		for _, user := range options.V3Users {
+			user.crypto = options.CryptoProvider
+		}
+	}
+
 	return &TrapListener{
 		opts:    options,
 		handler: handler,
@@ -55,34 +69,60 @@ func NewTrapListener(handler TrapHandler, opts ...TrapListenerOption) *TrapListe
 
 // Start starts listening for traps.
 func (l *TrapListener) Start(ctx context.Context) error {
-	addr, err := net.ResolveUDPAddr("udp", l.opts.Address)
+	switch l.opts.Network {
+	case "udp", "udp4", "udp6":
+	default:
+		return fmt.Errorf("snmp: unsupported trap network %q", l.opts.Network)
+	}
+
+	addr, err := net.ResolveUDPAddr(l.opts.Network, l.opts.Address)
 	if err != nil {
 		return err
 	}
 
-	conn, err := net.ListenUDP("udp", addr)
+	if l.opts.SpoolDir != "" && l.handler != nil {
+		l.spool, err = openTrapSpool(l.opts.SpoolDir, l.opts.QueueSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	conn, err := net.ListenUDP(l.opts.Network, addr)
 	if err != nil {
 		return err
 	}
 
 	l.conn = conn
-	l.logger.Info("trap listener started", "address", l.opts.Address)
+	l.logger.Info("trap listener started", "address", l.opts.Address, "network", l.opts.Network)
 
 	l.wg.Add(1)
 	go l.listen()
 
+	if l.spool != nil {
+		l.wg.Add(1)
+		go l.deliver()
+	}
+
 	return nil
 }
 
-// Stop stops the trap listener.
+// Stop stops the trap listener and waits for running handler calls to
+// return. With a spool directory, it also spills the traps still queued,
+// which are then replayed by the next listener using the directory.
 func (l *TrapListener) Stop() error {
 	close(l.done)
 	if l.conn != nil {
 		l.conn.Close()
 	}
 	l.wg.Wait()
+	l.handlers.Wait()
+
+	var err error
+	if l.spool != nil {
+		err = l.spool.flush()
+	}
 	l.logger.Info("trap listener stopped")
-	return nil
+	return err
 }
 
 func (l *TrapListener) listen() {
@@ -114,11 +154,20 @@ func (l *TrapListener) listen() {
 		if err != nil {
 			l.logger.Warn("failed to decode trap", "error", err, "source", remoteAddr)
 			l.metrics.Errors.Add(1)
+			if l.opts.RawHandler != nil {
+				// buf is reused by the next read
+				data := append([]byte(nil), buf[:n]...)
+				l.handlers.Add(1)
+				go func() {
+					defer l.handlers.Done()
+					l.opts.RawHandler(data, remoteAddr)
+				}()
+			}
 			continue
 		}
 
 		// Check community if specified
-		if l.opts.Community != "" && trap.Community != l.opts.Community {
+		if l.opts.Community != "" && trap.Version != Version3 && trap.Community != l.opts.Community {
 			l.logger.Warn("trap community mismatch",
 				"expected", l.opts.Community,
 				"received", trap.Community,
@@ -126,15 +175,37 @@ func (l *TrapListener) listen() {
 			continue
 		}
 
+		// Check trap OID if a filter is set
+		if l.opts.TrapOIDFilter != nil && !l.opts.TrapOIDFilter.Matches(trap.TrapOID) {
+			l.logger.Debug("trap OID filtered",
+				"trap_oid", trap.TrapOID,
+				"source", remoteAddr)
+			continue
+		}
+
 		// Call handler
-		if l.handler != nil {
-			go l.handler(trap)
+		if l.spool != nil {
+			l.enqueue(trap, buf[:n], remoteAddr)
+		} else if l.handler != nil {
+			l.handlers.Add(1)
+			go func() {
+				defer l.handlers.Done()
+				l.handler(trap)
+			}()
 		}
 	}
 }
 
 func (l *TrapListener) decodeTrap(data []byte, remoteAddr *net.UDPAddr) (*TrapPDU, error) {
-	// First, try to decode as a regular SNMP message (v2c trap)
+	v3msg, err := decodeV3Message(data)
+	if err == nil {
+		return l.decodeV3Trap(data, v3msg, remoteAddr)
+	}
+	if !errors.Is(err, ErrInvalidVersion) {
+		return nil, err
+	}
+
+	// Try to decode as a regular SNMP message (v2c trap)
 	msg, err := DecodeMessage(data)
 	if err != nil {
 		// Try v1 trap format
@@ -144,23 +215,55 @@ func (l *TrapListener) decodeTrap(data []byte, remoteAddr *net.UDPAddr) (*TrapPD
 	trap := &TrapPDU{
 		Version:       msg.Version,
 		Community:     msg.Community,
-		SourceAddress: remoteAddr.String(),
+		SourceAddress: normalizeSourceAddress(remoteAddr),
 	}
+	setTrapVariables(trap, msg.PDU)
 
-	if msg.PDU.Type == PDUTrapV2 || msg.PDU.Type == PDUInformRequest {
-		trap.Variables = msg.PDU.Variables
+	return trap, nil
+}
+
+// decodeV3Trap authenticates and decrypts an SNMPv3 notification with the
+// configured user of the same name. Notifications without authentication
+// are accepted from any user.
+func (l *TrapListener) decodeV3Trap(data []byte, msg *v3Message, remoteAddr *net.UDPAddr) (*TrapPDU, error) {
+	if err := msg.open(data, l.opts.V3Users[msg.UserName]); err != nil {
+		return nil, err
+	}
+
+	trap := &TrapPDU{
+		Version:       Version3,
+		SourceAddress: normalizeSourceAddress(remoteAddr),
+		SecurityName:  msg.UserName,
+		SecurityLevel: msg.securityLevel(),
+		ContextName:   msg.ScopedPDU.ContextName,
+	}
+	setTrapVariables(trap, msg.ScopedPDU.PDU)
+
+	return trap, nil
+}
+
+// setTrapVariables fills in the variables of an SNMPv2 notification and
+// the sysUpTime and snmpTrapOID they carry.
+func setTrapVariables(trap *TrapPDU, pdu *PDU) {
+	if pdu.Type == PDUTrapV2 || pdu.Type == PDUInformRequest {
+		trap.Variables = pdu.Variables
 
 		// Extract sysUpTime and snmpTrapOID from varbinds
-		for _, v := range msg.PDU.Variables {
+		for _, v := range pdu.Variables {
 			if v.OID.Equal(OIDSysUpTime) {
-				if val, ok := v.Value.(uint32); ok {
-					trap.Timestamp = val
+				// Some agents send the uptime as an INTEGER or a counter
+				// rather than TimeTicks.
+				if val, ok := v.AsUint(); ok && val <= math.MaxUint32 {
+					trap.Timestamp = uint32(val)
+				}
+			}
+			if v.OID.Equal(OIDSnmpTrapOID) {
+				if val, ok := v.Value.(OID); ok {
+					trap.TrapOID = val
 				}
 			}
 		}
 	}
-
-	return trap, nil
 }
 
 func (l *TrapListener) decodeV1Trap(data []byte, remoteAddr *net.UDPAddr) (*TrapPDU, error) {
@@ -183,11 +286,38 @@ func (l *TrapListener) decodeV1Trap(data []byte, remoteAddr *net.UDPAddr) (*Trap
 		GenericTrap:   msg.PDU.GenericTrap,
 		SpecificTrap:  msg.PDU.SpecificTrap,
 		Timestamp:     msg.PDU.Timestamp,
+		TrapOID:       v1TrapOID(msg.PDU.Enterprise, msg.PDU.GenericTrap, msg.PDU.SpecificTrap),
 		Variables:     msg.PDU.Variables,
-		SourceAddress: remoteAddr.String(),
+		SourceAddress: normalizeSourceAddress(remoteAddr),
 	}, nil
 }
 
+// v1TrapOID translates SNMPv1 trap identification to an SNMPv2 trap OID
+// (RFC 3584 section 3.1).
+func v1TrapOID(enterprise OID, generic, specific int) OID {
+	if generic == 6 {
+		oid := make(OID, 0, len(enterprise)+2)
+		oid = append(oid, enterprise...)
+		return append(oid, 0, specific)
+	}
+	oid := OIDSnmpTraps.Copy()
+	return append(oid, generic+1)
+}
+
+// normalizeSourceAddress formats a trap source address, presenting
+// IPv4-mapped IPv6 addresses (::ffff:192.0.2.1) as plain IPv4.
+func normalizeSourceAddress(addr *net.UDPAddr) string {
+	ip := addr.IP
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	host := ip.String()
+	if addr.Zone != "" && ip.To4() == nil {
+		host += "%" + addr.Zone
+	}
+	return net.JoinHostPort(host, strconv.Itoa(addr.Port))
+}
+
 // Metrics returns the listener metrics.
 func (l *TrapListener) Metrics() *Metrics {
 	return l.metrics
@@ -0,0 +1,423 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startTrapListener starts a trap listener on a loopback port and returns
+// it with the port. The caller stops it.
+func startTrapListener(t *testing.T, handler TrapHandler, opts ...TrapListenerOption) (*TrapListener, int) {
+	t.Helper()
+	l := NewTrapListener(handler, append([]TrapListenerOption{WithListenAddress("127.0.0.1:0")}, opts...)...)
+	if err := l.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return l, l.conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestTrapListenerStopWaitsForHandler(t *testing.T) {
+	started := make(chan struct{})
+	var finished atomic.Bool
+	l, port := startTrapListener(t, func(trap *TrapPDU) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		finished.Store(true)
+	})
+
+	c := newTestClient(t, port)
+	if err := c.SendTrap(context.Background(), 100, OIDSnmpTraps.Append(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("handler not called")
+	}
+	if err := l.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if !finished.Load() {
+		t.Error("Stop returned before the handler")
+	}
+}
+
+func TestTrapListenerIPv6(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		listen  string
+		target  string
+		source  string
+	}{
+		{"udp6", "udp6", "[::1]:0", "[::1]", "::1"},
+		{"dual-stack from IPv4", "udp", ":0", "127.0.0.1", "127.0.0.1"},
+		{"dual-stack from IPv6", "udp", ":0", "[::1]", "::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if pc, err := net.ListenPacket("udp6", "[::1]:0"); err != nil {
+				t.Skipf("IPv6 loopback unavailable: %v", err)
+			} else {
+				pc.Close()
+			}
+
+			traps := make(chan *TrapPDU, 1)
+			l, port := startTrapListener(t, func(trap *TrapPDU) { traps <- trap },
+				WithTrapNetwork(tt.network), WithListenAddress(tt.listen))
+			defer l.Stop()
+
+			c := newTestClient(t, port, WithTarget(tt.target))
+			if err := c.SendTrap(context.Background(), 100, OIDSnmpTraps.Append(1)); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case trap := <-traps:
+				host, _, err := net.SplitHostPort(trap.SourceAddress)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if host != tt.source {
+					t.Errorf("SourceAddress = %s, want host %s", trap.SourceAddress, tt.source)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("trap not received")
+			}
+		})
+	}
+}
+
+func TestRawTrapHandler(t *testing.T) {
+	type raw struct {
+		data []byte
+		src  *net.UDPAddr
+	}
+	raws := make(chan raw, 2)
+	traps := make(chan *TrapPDU, 2)
+	l, port := startTrapListener(t, func(trap *TrapPDU) { traps <- trap },
+		WithRawTrapHandler(func(data []byte, src *net.UDPAddr) { raws <- raw{data, src} }))
+	defer l.Stop()
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	garbage := []byte("\x30\x03not a trap")
+	if _, err := conn.Write(garbage); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-raws:
+		if !bytes.Equal(r.data, garbage) {
+			t.Errorf("raw handler got % x, want % x", r.data, garbage)
+		}
+		if r.src.Port != conn.LocalAddr().(*net.UDPAddr).Port {
+			t.Errorf("raw handler source = %s, want %s", r.src, conn.LocalAddr())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("raw handler not called")
+	}
+
+	// A decodable trap goes to the trap handler only.
+	c := newTestClient(t, port)
+	if err := c.SendTrap(context.Background(), 100, OIDSnmpTraps.Append(1)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-traps:
+	case <-time.After(time.Second):
+		t.Fatal("trap not received")
+	}
+	select {
+	case r := <-raws:
+		t.Errorf("raw handler called for a valid trap: % x", r.data)
+	default:
+	}
+}
+
+func TestTrapUptimeTypes(t *testing.T) {
+	v2 := func(uptime Variable) []byte {
+		uptime.OID = OIDSysUpTime
+		data, err := (&Message{Version: Version2c, Community: "public", PDU: &PDU{
+			Type: PDUTrapV2,
+			Variables: []Variable{
+				uptime,
+				{OID: OIDSnmpTrapOID, Type: TypeObjectIdentifier, Value: OIDSnmpTraps.Append(1)},
+			},
+		}}).Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	// An SNMPv1 trap whose time-stamp is tagged INTEGER rather than
+	// TimeTicks.
+	v1, err := (&TrapV1Message{Version: Version1, Community: "public", PDU: &TrapV1PDU{
+		Enterprise:   MustParseOID("1.3.6.1.4.1.99999"),
+		AgentAddress: []byte{192, 0, 2, 1},
+		GenericTrap:  6,
+		SpecificTrap: 1,
+		Timestamp:    4200,
+	}}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeTicks := []byte{byte(TypeTimeTicks), 0x02, 0x10, 0x68}
+	if !bytes.Contains(v1, timeTicks) {
+		t.Fatalf("time-stamp not found in % x", v1)
+	}
+	v1 = bytes.Replace(v1, timeTicks, []byte{byte(TypeInteger), 0x02, 0x10, 0x68}, 1)
+
+	tests := []struct {
+		name string
+		data []byte
+		want uint32
+	}{
+		{"TimeTicks", v2(Variable{Type: TypeTimeTicks, Value: uint32(4200)}), 4200},
+		{"INTEGER", v2(Variable{Type: TypeInteger, Value: 4200}), 4200},
+		{"Counter32", v2(Variable{Type: TypeCounter32, Value: uint32(4200)}), 4200},
+		{"negative INTEGER", v2(Variable{Type: TypeInteger, Value: -1}), 0},
+		{"SNMPv1 INTEGER", v1, 4200},
+	}
+	l := NewTrapListener(nil)
+	for _, tt := range tests {
+		trap, err := l.decodeTrap(tt.data, &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 162})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if trap.Timestamp != tt.want {
+			t.Errorf("%s: Timestamp = %d, want %d", tt.name, trap.Timestamp, tt.want)
+		}
+	}
+}
+
+// countingCrypto is a CryptoProvider that counts the calls it delegates
+// to the standard library.
+type countingCrypto struct {
+	StdCryptoProvider
+	hashes, digests, encrypts, decrypts int
+}
+
+func (p *countingCrypto) Hash(proto AuthProtocol) (hash.Hash, error) {
+	p.hashes++
+	return p.StdCryptoProvider.Hash(proto)
+}
+
+func (p *countingCrypto) AuthDigest(proto AuthProtocol, key, msg []byte) ([]byte, error) {
+	p.digests++
+	return p.StdCryptoProvider.AuthDigest(proto, key, msg)
+}
+
+func (p *countingCrypto) Encrypt(proto PrivProtocol, key, iv, plaintext []byte) ([]byte, error) {
+	p.encrypts++
+	return p.StdCryptoProvider.Encrypt(proto, key, iv, plaintext)
+}
+
+func (p *countingCrypto) Decrypt(proto PrivProtocol, key, iv, ciphertext []byte) ([]byte, error) {
+	p.decrypts++
+	return p.StdCryptoProvider.Decrypt(proto, key, iv, ciphertext)
+}
+
+func TestTrapCryptoProvider(t *testing.T) {
+	data, err := hex.DecodeString(v3TrapPrivAES)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crypto := &countingCrypto{}
+	l := NewTrapListener(nil,
+		WithTrapV3User("trapuser", SHA, "authpass1", AES, "privpass1"),
+		WithTrapCryptoProvider(crypto))
+
+	trap, err := l.decodeTrap(data, &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 162})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trap.SecurityLevel != AuthPriv || trap.Timestamp != 4200 {
+		t.Errorf("trap = %v uptime %d, want authPriv 4200", trap.SecurityLevel, trap.Timestamp)
+	}
+	if crypto.hashes == 0 || crypto.digests != 1 || crypto.decrypts != 1 {
+		t.Errorf("provider called for %d hashes, %d digests, %d decryptions, want some, 1, 1",
+			crypto.hashes, crypto.digests, crypto.decrypts)
+	}
+	if crypto.encrypts != 0 {
+		t.Errorf("provider called for %d encryptions decoding a trap", crypto.encrypts)
+	}
+}
+
+func TestNormalizeSourceAddress(t *testing.T) {
+	tests := []struct {
+		addr net.UDPAddr
+		want string
+	}{
+		{net.UDPAddr{IP: net.ParseIP("::ffff:192.0.2.1"), Port: 162}, "192.0.2.1:162"},
+		{net.UDPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 162}, "192.0.2.1:162"},
+		{net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 162}, "[2001:db8::1]:162"},
+		{net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: 162, Zone: "eth0"}, "[fe80::1%eth0]:162"},
+	}
+	for _, tt := range tests {
+		if got := normalizeSourceAddress(&tt.addr); got != tt.want {
+			t.Errorf("normalizeSourceAddress(%s) = %s, want %s", &tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestTrapListenerSpool(t *testing.T) {
+	const traps = 8
+	dir := t.TempDir()
+	blocked := make(chan struct{})
+	release := make(chan struct{})
+	delivered := make(chan uint32, traps)
+	l, port := startTrapListener(t, func(trap *TrapPDU) {
+		if trap.Timestamp == 1 {
+			close(blocked)
+			<-release
+		}
+		delivered <- trap.Timestamp
+	}, WithTrapSpoolDir(dir), WithTrapQueueSize(2))
+	defer l.Stop()
+
+	c := newTestClient(t, port)
+	for i := uint32(1); i <= traps; i++ {
+		if err := c.SendTrap(context.Background(), i, OIDSnmpTraps.Append(1)); err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 {
+			select {
+			case <-blocked:
+			case <-time.After(time.Second):
+				t.Fatal("handler not called")
+			}
+		}
+	}
+
+	// The handler holds trap 1 and the queue traps 2 and 3, so the
+	// rest are spilled.
+	deadline := time.Now().Add(time.Second)
+	for l.Metrics().TrapsReceived.Value() < traps && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*"+spoolSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != traps-3 {
+		t.Errorf("spooled %d traps, want %d", len(files), traps-3)
+	}
+	if n := l.Metrics().TrapsSpooled.Value(); n != traps-3 {
+		t.Errorf("TrapsSpooled = %d, want %d", n, traps-3)
+	}
+
+	close(release)
+	for i := uint32(1); i <= traps; i++ {
+		select {
+		case got := <-delivered:
+			if got != i {
+				t.Fatalf("delivered trap %d, want %d", got, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("trap %d not delivered", i)
+		}
+	}
+	// The file is removed after the handler returns.
+	deadline = time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if files, _ = filepath.Glob(filepath.Join(dir, "*"+spoolSuffix)); len(files) == 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(files) != 0 {
+		t.Errorf("spool files left after replay: %v", files)
+	}
+}
+
+// SNMPv3 linkUp notifications from engine 80001f8880e9630000d61ff449
+// (boots 3, time 42) for user "trapuser", with authentication passphrase
+// "authpass1" and privacy passphrase "privpass1", carrying sysUpTime 4200.
+const (
+	v3TrapNoAuth   = "308185020103300f020204d2020300ffe304010002010304253023040d80001f8880e9630000d61ff44902010302012a04087472617075736572040004003048040d80001f8880e9630000d61ff4490400a735020204d20201000201003029300e06082b06010201010300430210683017060a2b06010603010104010006092b0601060301010504"
+	v3TrapAuthSHA  = "308191020103300f020204d2020300ffe30401010201030431302f040d80001f8880e9630000d61ff44902010302012a04087472617075736572040cb8d20facde187f31923ddc7804003048040d80001f8880e9630000d61ff4490400a735020204d20201000201003029300e06082b06010201010300430210683017060a2b06010603010104010006092b0601060301010504"
+	v3TrapPrivAES  = "30819b020103300f020204d2020300ffe304010302010304393037040d80001f8880e9630000d61ff44902010302012a04087472617075736572040cc1e5215e528f95bb965d35c50408ff4f7787cba9a257044ad3dcdc0ccd8ad90d5e61712f5b2e308d97be3c019885b68d86aca3b2985b8dc99dc9527fc5a98a623b9e55fa71bd264180d1f06985775f12963d52ea9e70b1546158e14da1040c34c703"
+	v3TrapAuthByte = 0x3c // offset of the first byte of the SHA digest
+)
+
+func TestTrapListenerV3(t *testing.T) {
+	authUser := WithTrapV3User("trapuser", SHA, "authpass1", NoPriv, "")
+	privUser := WithTrapV3User("trapuser", SHA, "authpass1", AES, "privpass1")
+	tests := []struct {
+		name    string
+		packet  string
+		corrupt bool
+		opts    []TrapListenerOption
+		level   SecurityLevel
+		wantErr error
+	}{
+		{name: "noAuthNoPriv", packet: v3TrapNoAuth, level: NoAuthNoPriv},
+		{name: "noAuthNoPriv from configured user", packet: v3TrapNoAuth, opts: []TrapListenerOption{authUser}, level: NoAuthNoPriv},
+		{name: "authNoPriv", packet: v3TrapAuthSHA, opts: []TrapListenerOption{authUser}, level: AuthNoPriv},
+		{name: "authPriv", packet: v3TrapPrivAES, opts: []TrapListenerOption{privUser}, level: AuthPriv},
+		{name: "unknown user", packet: v3TrapAuthSHA, wantErr: ErrAuthFailure},
+		{name: "wrong passphrase", packet: v3TrapAuthSHA, opts: []TrapListenerOption{WithTrapV3User("trapuser", SHA, "authpass2", NoPriv, "")}, wantErr: ErrAuthFailure},
+		{name: "wrong digest", packet: v3TrapAuthSHA, corrupt: true, opts: []TrapListenerOption{authUser}, wantErr: ErrAuthFailure},
+		{name: "no privacy key", packet: v3TrapPrivAES, opts: []TrapListenerOption{authUser}, wantErr: ErrPrivFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.corrupt {
+				data[v3TrapAuthByte] ^= 0xff
+			}
+			l := NewTrapListener(nil, tt.opts...)
+			trap, err := l.decodeTrap(data, &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 162})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("decodeTrap error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if trap.Version != Version3 || trap.SecurityName != "trapuser" || trap.SecurityLevel != tt.level {
+				t.Errorf("trap = %v %q %v, want v3 \"trapuser\" %v", trap.Version, trap.SecurityName, trap.SecurityLevel, tt.level)
+			}
+			if trap.Timestamp != 4200 || !trap.TrapOID.Equal(OIDSnmpTraps.Append(4)) {
+				t.Errorf("trap = uptime %d OID %s, want 4200 %s", trap.Timestamp, trap.TrapOID, OIDSnmpTraps.Append(4))
+			}
+			if trap.SourceAddress != "192.0.2.1:162" {
+				t.Errorf("SourceAddress = %q", trap.SourceAddress)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// spoolSuffix is the file name suffix of spilled traps.
+const spoolSuffix = ".trap"
+
+// queuedTrap is a trap waiting for the handler. The datagram is kept so
+// the trap can still be spilled when the listener stops.
+type queuedTrap struct {
+	seq  uint64
+	trap *TrapPDU
+	data []byte
+	src  *net.UDPAddr
+}
+
+// trapSpool holds the traps waiting for the handler: an in-memory queue
+// and, once that is full, one file per trap in a directory.
+//
+// Traps are numbered in arrival order and files are named after their
+// number, so replaying them in name order preserves arrival order. While
+// any file is pending, new traps are spilled too, and a file is only
+// removed after the handler has returned for its trap.
+//
+// A file holds the source address on the first line followed by the
+// datagram, which is decoded again when the trap is replayed.
+type trapSpool struct {
+	dir   string
+	queue chan queuedTrap
+	wake  chan struct{}
+
+	mu    sync.Mutex
+	seq   uint64
+	files []string
+}
+
+// openTrapSpool opens the spool in dir, creating the directory if needed,
+// and picks up the files left by a previous run.
+func openTrapSpool(dir string, size int) (*trapSpool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("snmp: trap queue size must be positive, got %d", size)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("snmp: failed to create trap spool: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("snmp: failed to read trap spool: %w", err)
+	}
+
+	s := &trapSpool{
+		dir:   dir,
+		queue: make(chan queuedTrap, size),
+		wake:  make(chan struct{}, 1),
+	}
+	// ReadDir sorts by name, which is arrival order.
+	for _, e := range entries {
+		seq, ok := spoolSeq(e.Name())
+		if !ok || !e.Type().IsRegular() {
+			continue
+		}
+		s.files = append(s.files, e.Name())
+		s.seq = max(s.seq, seq)
+	}
+	return s, nil
+}
+
+// spoolFileName returns the file name of the trap numbered seq, padded so
+// that names sort in numeric order.
+func spoolFileName(seq uint64) string {
+	return fmt.Sprintf("%020d%s", seq, spoolSuffix)
+}
+
+// spoolSeq parses the trap number from a spool file name.
+func spoolSeq(name string) (uint64, bool) {
+	base, ok := strings.CutSuffix(name, spoolSuffix)
+	if !ok {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(base, 10, 64)
+	return seq, err == nil
+}
+
+// push queues a trap, or spills it if the queue is full or earlier traps
+// are still spilled. It reports whether the trap was spilled.
+func (s *trapSpool) push(trap *TrapPDU, data []byte, src *net.UDPAddr) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	t := queuedTrap{seq: s.seq, trap: trap, data: data, src: src}
+	if len(s.files) == 0 {
+		select {
+		case s.queue <- t:
+			return false, nil
+		default:
+		}
+	}
+	return true, s.spill(t)
+}
+
+// spill writes a trap to its file. The caller must hold s.mu.
+func (s *trapSpool) spill(t queuedTrap) error {
+	name := spoolFileName(t.seq)
+	path := filepath.Join(s.dir, name)
+
+	content := make([]byte, 0, len(t.data)+64)
+	content = append(content, t.src.String()...)
+	content = append(content, '\n')
+	content = append(content, t.data...)
+
+	// Write under a temporary name so a crash never leaves a partial trap.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return fmt.Errorf("snmp: failed to spool trap: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("snmp: failed to spool trap: %w", err)
+	}
+
+	i, _ := slices.BinarySearch(s.files, name)
+	s.files = slices.Insert(s.files, i, name)
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// next returns the name of the oldest spilled trap, if any.
+func (s *trapSpool) next() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.files) == 0 {
+		return "", false
+	}
+	return s.files[0], true
+}
+
+// read loads a spilled trap.
+func (s *trapSpool) read(name string) ([]byte, *net.UDPAddr, error) {
+	content, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, nil, err
+	}
+	addr, data, ok := bytes.Cut(content, []byte("\n"))
+	if !ok {
+		return nil, nil, errors.New("missing source address")
+	}
+	src, err := netip.ParseAddrPort(string(addr))
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, net.UDPAddrFromAddrPort(src), nil
+}
+
+// remove deletes a spilled trap. It is forgotten even if the file cannot
+// be deleted, so a stuck file is not replayed forever.
+func (s *trapSpool) remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := slices.Index(s.files, name); i >= 0 {
+		s.files = slices.Delete(s.files, i, i+1)
+	}
+	return os.Remove(filepath.Join(s.dir, name))
+}
+
+// flush spills the traps still in the queue. It is called once nothing
+// can be pushed anymore.
+func (s *trapSpool) flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for {
+		select {
+		case t := <-s.queue:
+			if err := s.spill(t); err != nil {
+				errs = append(errs, err)
+			}
+		default:
+			return errors.Join(errs...)
+		}
+	}
+}
+
+// enqueue hands a trap to the delivery goroutine.
+func (l *TrapListener) enqueue(trap *TrapPDU, data []byte, src *net.UDPAddr) {
+	// data is reused by the next read
+	data = append([]byte(nil), data...)
+
+	spilled, err := l.spool.push(trap, data, src)
+	if err != nil {
+		l.logger.Warn("trap dropped", "error", err, "source", src)
+		l.metrics.Errors.Add(1)
+		return
+	}
+	if spilled {
+		l.metrics.TrapsSpooled.Add(1)
+	}
+}
+
+// deliver calls the handler for queued traps and, once the queue is
+// empty, for spilled ones, until the listener stops.
+func (l *TrapListener) deliver() {
+	defer l.wg.Done()
+
+	for {
+		select {
+		case t := <-l.spool.queue:
+			l.handler(t.trap)
+			continue
+		case <-l.done:
+			return
+		default:
+		}
+
+		if name, ok := l.spool.next(); ok {
+			l.replay(name)
+			continue
+		}
+
+		select {
+		case t := <-l.spool.queue:
+			l.handler(t.trap)
+		case <-l.spool.wake:
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// replay delivers a spilled trap and removes it. Traps that cannot be read
+// or decoded are dropped.
+func (l *TrapListener) replay(name string) {
+	data, src, err := l.spool.read(name)
+	if err == nil {
+		var trap *TrapPDU
+		trap, err = l.decodeTrap(data, src)
+		if err == nil {
+			l.handler(trap)
+		}
+	}
+	if err != nil {
+		l.logger.Warn("dropping spooled trap", "file", name, "error", err)
+		l.metrics.Errors.Add(1)
+	}
+
+	if err := l.spool.remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		l.logger.Warn("failed to remove spooled trap", "file", name, "error", err)
+	}
+}
@@ -15,7 +15,10 @@
 package snmp
 
 import (
+	"bytes"
 	"fmt"
+	"net"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -55,6 +58,7 @@ const (
 	TypeGetBulkRequest BERType = 0xA5
 	TypeInformRequest  BERType = 0xA6
 	TypeTrapV2         BERType = 0xA7 // SNMPv2c Trap
+	TypeReport         BERType = 0xA8
 
 	// Exception types (SNMPv2c)
 	TypeNoSuchObject   BERType = 0x80
@@ -85,6 +89,8 @@ func (t BERType) String() string {
 		return "TimeTicks"
 	case TypeOpaque:
 		return "Opaque"
+	case TypeNsapAddress:
+		return "NsapAddress"
 	case TypeCounter64:
 		return "Counter64"
 	case TypeUInteger32:
@@ -107,6 +113,8 @@ func (t BERType) String() string {
 		return "InformRequest-PDU"
 	case TypeTrapV2:
 		return "SNMPv2-Trap-PDU"
+	case TypeReport:
+		return "Report-PDU"
 	case TypeNoSuchObject:
 		return "noSuchObject"
 	case TypeNoSuchInstance:
@@ -118,6 +126,28 @@ func (t BERType) String() string {
 	}
 }
 
+// IsException reports whether t is an SNMPv2 exception value:
+// noSuchObject, noSuchInstance or endOfMibView.
+func (t BERType) IsException() bool {
+	switch t {
+	case TypeNoSuchObject, TypeNoSuchInstance, TypeEndOfMibView:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsApplication reports whether t is in the APPLICATION tag class, like
+// the SNMP-specific types IpAddress, Counter32 and Counter64.
+func (t BERType) IsApplication() bool {
+	return t&0xC0 == 0x40
+}
+
+// IsPDU reports whether t is one of the PDU types.
+func (t BERType) IsPDU() bool {
+	return t >= TypeGetRequest && t <= TypeReport
+}
+
 // PDUType represents SNMP PDU types.
 type PDUType byte
 
@@ -130,6 +160,7 @@ const (
 	PDUGetBulkRequest PDUType = 0xA5
 	PDUInformRequest  PDUType = 0xA6
 	PDUTrapV2         PDUType = 0xA7
+	PDUReport         PDUType = 0xA8
 )
 
 // String returns the string representation of the PDU type.
@@ -208,7 +239,10 @@ func (e ErrorStatus) String() string {
 	}
 }
 
-// OID represents an SNMP Object Identifier.
+// OID represents an SNMP Object Identifier. Sub-identifiers range from 0
+// to 4294967295 (2^32-1); on platforms with a 32-bit int, values above
+// 2147483647 cannot be represented and are rejected when parsing and
+// decoding.
 type OID []int
 
 // String returns the dotted-decimal string representation.
@@ -235,14 +269,17 @@ func ParseOID(s string) (OID, error) {
 	parts := strings.Split(s, ".")
 	oid := make(OID, len(parts))
 	for i, p := range parts {
-		n, err := strconv.Atoi(p)
+		if strings.HasPrefix(p, "-") {
+			return nil, fmt.Errorf("negative OID component: %s", p)
+		}
+		n, err := strconv.ParseUint(p, 10, 32)
 		if err != nil {
 			return nil, fmt.Errorf("invalid OID component '%s': %w", p, err)
 		}
-		if n < 0 {
-			return nil, fmt.Errorf("negative OID component: %d", n)
+		if int(n) < 0 {
+			return nil, fmt.Errorf("OID component %d out of range on this platform", n)
 		}
-		oid[i] = n
+		oid[i] = int(n)
 	}
 
 	return oid, nil
@@ -283,6 +320,27 @@ func (o OID) HasPrefix(prefix OID) bool {
 	return true
 }
 
+// Compare compares two OIDs lexicographically. It returns -1 if o sorts
+// before other, +1 if it sorts after and 0 if they are equal.
+func (o OID) Compare(other OID) int {
+	for i := 0; i < len(o) && i < len(other); i++ {
+		if o[i] < other[i] {
+			return -1
+		}
+		if o[i] > other[i] {
+			return 1
+		}
+	}
+	switch {
+	case len(o) < len(other):
+		return -1
+	case len(o) > len(other):
+		return 1
+	default:
+		return 0
+	}
+}
+
 // Copy returns a copy of the OID.
 func (o OID) Copy() OID {
 	c := make(OID, len(o))
@@ -290,11 +348,48 @@ func (o OID) Copy() OID {
 	return c
 }
 
+// Append returns a new OID made of o followed by sub. o is not modified.
+func (o OID) Append(sub ...int) OID {
+	c := make(OID, 0, len(o)+len(sub))
+	c = append(c, o...)
+	return append(c, sub...)
+}
+
+// TrimPrefix returns a copy of the sub-identifiers of o that follow
+// prefix, and false if o does not start with prefix.
+func (o OID) TrimPrefix(prefix OID) (OID, bool) {
+	if !o.HasPrefix(prefix) {
+		return nil, false
+	}
+	return o[len(prefix):].Copy(), true
+}
+
+// Index returns a copy of the table index of a column instance OID, given
+// the length of the table entry OID: the sub-identifiers after the entry
+// and the column number. It returns nil if o is too short to have one.
+func (o OID) Index(entryLen int) OID {
+	if entryLen < 0 || len(o) <= entryLen+1 {
+		return nil
+	}
+	return o[entryLen+1:].Copy()
+}
+
 // Variable represents an SNMP variable binding.
+//
+// Decoded variables never share memory with the buffer a packet was read
+// into, so they can be retained freely. A decoded OCTET STRING value and
+// its RawValue do share their bytes; use Clone before modifying either.
 type Variable struct {
-	OID   OID
-	Type  BERType
+	OID  OID
+	Type BERType
+	// Value is the Go value for Type. An Opaque value to be sent is either
+	// pre-encoded BER as []byte or a *Variable whose value is encoded and
+	// wrapped; received Opaque values are []byte, see OpaqueValue.
 	Value interface{}
+
+	// RawValue holds the undecoded BER value bytes of a received varbind.
+	// Clients only keep it when created with WithKeepRawValues(true).
+	RawValue []byte
 }
 
 // String returns a string representation of the variable.
@@ -350,6 +445,50 @@ func (v *Variable) AsString() string {
 	}
 }
 
+// Clone returns a deep copy of v: the OID, RawValue and values held in a
+// []byte, OID or net.IP are copied, so the clone can be modified without
+// affecting v.
+func (v *Variable) Clone() Variable {
+	c := *v
+	c.OID = slices.Clone(v.OID)
+	c.RawValue = slices.Clone(v.RawValue)
+	switch val := v.Value.(type) {
+	case []byte:
+		c.Value = slices.Clone(val)
+	case OID:
+		c.Value = slices.Clone(val)
+	case net.IP:
+		c.Value = slices.Clone(val)
+	case *Variable:
+		inner := val.Clone()
+		c.Value = &inner
+	}
+	return c
+}
+
+// OpaqueValue decodes the BER value wrapped in an Opaque variable, such as
+// an INTEGER sent with a *Variable as the Opaque value. The result has the
+// OID of v. It returns false if v is not an Opaque holding exactly one
+// well-formed BER value.
+func (v *Variable) OpaqueValue() (Variable, bool) {
+	if v.Type != TypeOpaque {
+		return Variable{}, false
+	}
+	if inner, ok := v.Value.(*Variable); ok {
+		return *inner, true
+	}
+	r := bytes.NewReader(v.AsBytes())
+	innerType, data, err := decodeTLV(r)
+	if err != nil || r.Len() != 0 || innerType == TypeOpaque {
+		return Variable{}, false
+	}
+	value, err := decodeValue(innerType, data)
+	if err != nil {
+		return Variable{}, false
+	}
+	return Variable{OID: v.OID, Type: innerType, Value: value}, true
+}
+
 // AsBytes returns the value as bytes.
 func (v *Variable) AsBytes() []byte {
 	switch val := v.Value.(type) {
@@ -362,6 +501,51 @@ func (v *Variable) AsBytes() []byte {
 	}
 }
 
+// RequestStats describes how a single request went on the wire.
+type RequestStats struct {
+	// SentAt is when the request was first sent.
+	SentAt time.Time
+	// RTT is the time between the last transmission and the response.
+	RTT time.Duration
+	// Retries is the number of retransmissions after a timeout.
+	Retries int
+	// BytesSent is the total size of all transmissions.
+	BytesSent int
+	// BytesReceived is the size of the response.
+	BytesReceived int
+}
+
+// WalkStats describes how a walk went on the wire.
+type WalkStats struct {
+	// Requests is the number of GETNEXT and GETBULK requests sent.
+	Requests int
+	// Retries is the number of retransmissions after a timeout.
+	Retries int
+	// Variables is the number of variables delivered.
+	Variables int
+	// VarbindsReceived is the number of varbinds in all responses,
+	// including those past the end of the walk and skipped holes.
+	VarbindsReceived int
+	// BulkFallback is set if GETBULK failed and the walk continued with
+	// GETNEXT.
+	BulkFallback bool
+	// MaxRepetitions is the max-repetitions of the last GETBULK, after
+	// any tuning for response size. It is zero if no GETBULK was sent.
+	MaxRepetitions int
+	// Anomalies is the number of non-increasing OIDs a lenient walk
+	// skipped.
+	Anomalies int
+}
+
+// record adds a walk request to the stats, which may be nil.
+func (s *WalkStats) record(req *RequestStats, vars []Variable) {
+	if s == nil {
+		return
+	}
+	s.Retries += req.Retries
+	s.VarbindsReceived += len(vars)
+}
+
 // ConnectionState represents the state of a client connection.
 type ConnectionState int
 
@@ -499,6 +683,18 @@ type ResponseHandler func(variables []Variable)
 // TrapHandler is a callback for received traps.
 type TrapHandler func(trap *TrapPDU)
 
+// AuthFailureHandler is a callback for SNMPv3 authentication failures
+// reported by the agent.
+type AuthFailureHandler func(client *Client, reason string)
+
+// ResponseValidator decides whether a received response is accepted. A
+// non-nil error drops the response.
+type ResponseValidator func(src net.Addr, msg *Message) error
+
+// RawTrapHandler is a callback for datagrams the trap listener could not
+// decode.
+type RawTrapHandler func(data []byte, src *net.UDPAddr)
+
 // ConnectionLostHandler is a callback for connection loss.
 type ConnectionLostHandler func(client *Client, err error)
 
@@ -508,17 +704,51 @@ type OnConnectHandler func(client *Client)
 // ReconnectHandler is a callback for reconnection attempts.
 type ReconnectHandler func(client *Client, opts *ClientOptions)
 
+// PacketDirection indicates whether a dumped packet was sent or received.
+type PacketDirection int
+
+const (
+	// PacketSent is a packet sent to the agent.
+	PacketSent PacketDirection = iota
+	// PacketReceived is a packet received from the agent.
+	PacketReceived
+)
+
+// String returns the string representation of the packet direction.
+func (d PacketDirection) String() string {
+	switch d {
+	case PacketSent:
+		return "sent"
+	case PacketReceived:
+		return "received"
+	default:
+		return "unknown"
+	}
+}
+
+// PacketDumpHandler is a callback receiving a copy of every raw packet.
+type PacketDumpHandler func(dir PacketDirection, data []byte)
+
 // TrapPDU represents an SNMP trap.
 type TrapPDU struct {
 	Version       SNMPVersion
 	Community     string
-	Enterprise    OID       // v1 only
-	AgentAddress  string    // v1 only
-	GenericTrap   int       // v1 only
-	SpecificTrap  int       // v1 only
-	Timestamp     uint32    // v1: TimeTicks, v2: sysUpTime
+	Enterprise    OID    // v1 only
+	AgentAddress  string // v1 only
+	GenericTrap   int    // v1 only
+	SpecificTrap  int    // v1 only
+	Timestamp     uint32 // v1: TimeTicks, v2: sysUpTime
+	TrapOID       OID    // v2: snmpTrapOID.0, v1: RFC 3584 translation
 	Variables     []Variable
-	SourceAddress string    // Source address of the trap
+	SourceAddress string // Source address of the trap
+	SecurityName  string // v3 only
+	ContextName   string // v3 only
+
+	// SecurityLevel is the level the v3 notification was sent with. The
+	// listener authenticates and decrypts as far as the message requests,
+	// so a noAuthNoPriv trap naming a user configured with authentication
+	// is still delivered; handlers must check the level they require.
+	SecurityLevel SecurityLevel
 }
 
 // Common OIDs
@@ -536,18 +766,44 @@ var (
 	OIDIfTable  = MustParseOID("1.3.6.1.2.1.2.2")
 
 	// SNMPv2-MIB trap OIDs
-	OIDSnmpTrapOID     = MustParseOID("1.3.6.1.6.3.1.1.4.1.0")
+	OIDSnmpTrapOID        = MustParseOID("1.3.6.1.6.3.1.1.4.1.0")
 	OIDSnmpTrapEnterprise = MustParseOID("1.3.6.1.6.3.1.1.4.3.0")
+	OIDSnmpTraps          = MustParseOID("1.3.6.1.6.3.1.1.5")
+
+	// SNMP-USER-BASED-SM-MIB statistics carried in SNMPv3 reports
+	OIDUsmStatsUnsupportedSecLevels = MustParseOID("1.3.6.1.6.3.15.1.1.1.0")
+	OIDUsmStatsNotInTimeWindows     = MustParseOID("1.3.6.1.6.3.15.1.1.2.0")
+	OIDUsmStatsUnknownUserNames     = MustParseOID("1.3.6.1.6.3.15.1.1.3.0")
+	OIDUsmStatsUnknownEngineIDs     = MustParseOID("1.3.6.1.6.3.15.1.1.4.0")
+	OIDUsmStatsWrongDigests         = MustParseOID("1.3.6.1.6.3.15.1.1.5.0")
+	OIDUsmStatsDecryptionErrors     = MustParseOID("1.3.6.1.6.3.15.1.1.6.0")
+
+	// SNMP-MPD-MIB and SNMP-TARGET-MIB statistics carried in reports
+	OIDSnmpUnknownSecurityModels = MustParseOID("1.3.6.1.6.3.11.2.1.1.0")
+	OIDSnmpInvalidMsgs           = MustParseOID("1.3.6.1.6.3.11.2.1.2.0")
+	OIDSnmpUnknownPDUHandlers    = MustParseOID("1.3.6.1.6.3.11.2.1.3.0")
+	OIDSnmpUnavailableContexts   = MustParseOID("1.3.6.1.6.3.12.1.4.0")
+	OIDSnmpUnknownContexts       = MustParseOID("1.3.6.1.6.3.12.1.5.0")
 )
 
 // Default values.
 const (
-	DefaultTimeout         = 5 * time.Second
-	DefaultRetries         = 3
-	DefaultPort            = 161
-	DefaultTrapPort        = 162
-	DefaultCommunity       = "public"
-	DefaultMaxOids         = 60
-	DefaultMaxRepetitions  = 10
-	DefaultNonRepeaters    = 0
+	DefaultTimeout            = 5 * time.Second
+	DefaultRetries            = 3
+	DefaultV1Timeout          = 10 * time.Second
+	DefaultV1Retries          = 4
+	DefaultInformRetries      = 5
+	DefaultInformBackoff      = 2.0
+	DefaultPort               = 161
+	DefaultTrapPort           = 162
+	DefaultCommunity          = "public"
+	DefaultMaxOids            = 60
+	DefaultMaxRepetitions     = 10
+	DefaultNonRepeaters       = 0
+	DefaultMaxWalkRegressions = 10
+	DefaultPipelineWindow     = 16
+	DefaultTrapQueueSize      = 1000
+	DefaultPollerJitter       = 0.1
+	DefaultPollerMultiplier   = 2.0
+	DefaultPollerMaxInterval  = 5 * time.Minute
 )
@@ -0,0 +1,666 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// SNMPv3 message flags (RFC 3412 section 6.4).
+const (
+	msgFlagAuth       = 0x01
+	msgFlagPriv       = 0x02
+	msgFlagReportable = 0x04
+)
+
+// usmSecurityModel is the msgSecurityModel of the User-based Security Model.
+const usmSecurityModel = 3
+
+// usmKeyCacheSize bounds the number of engine IDs whose localized keys are
+// cached per user.
+const usmKeyCacheSize = 64
+
+// newHash returns the hash function of the protocol, or nil for NoAuth.
+func (a AuthProtocol) newHash() func() hash.Hash {
+	switch a {
+	case MD5:
+		return md5.New
+	case SHA:
+		return sha1.New
+	case SHA224:
+		return sha256.New224
+	case SHA256:
+		return sha256.New
+	case SHA384:
+		return sha512.New384
+	case SHA512:
+		return sha512.New
+	default:
+		return nil
+	}
+}
+
+// macLength returns the length of the truncated HMAC carried in
+// msgAuthenticationParameters (RFC 3414, RFC 7860).
+func (a AuthProtocol) macLength() int {
+	switch a {
+	case MD5, SHA:
+		return 12
+	case SHA224:
+		return 16
+	case SHA256:
+		return 24
+	case SHA384:
+		return 32
+	case SHA512:
+		return 48
+	default:
+		return 0
+	}
+}
+
+// keyLength returns the length of localized key material the protocol
+// needs. For DES this includes the 8-byte pre-IV.
+func (p PrivProtocol) keyLength() int {
+	switch p {
+	case DES, AES:
+		return 16
+	case AES192, AES192C:
+		return 24
+	case AES256, AES256C:
+		return 32
+	default:
+		return 0
+	}
+}
+
+// passwordToKey derives the master key Ku from a passphrase (RFC 3414
+// section A.2): the hash of the passphrase repeated over one megabyte.
+func passwordToKey(newHash func() hash.Hash, password []byte) []byte {
+	h := newHash()
+	if len(password) == 0 {
+		return h.Sum(nil)
+	}
+	buf := make([]byte, 64)
+	for count := 0; count < 1048576; count += len(buf) {
+		for i := range buf {
+			buf[i] = password[(count+i)%len(password)]
+		}
+		h.Write(buf)
+	}
+	return h.Sum(nil)
+}
+
+// localizeKey binds a master key to an authoritative engine ID:
+// Kul = H(Ku || engineID || Ku).
+func localizeKey(newHash func() hash.Hash, ku, engineID []byte) []byte {
+	h := newHash()
+	h.Write(ku)
+	h.Write(engineID)
+	h.Write(ku)
+	return h.Sum(nil)
+}
+
+// usmUser holds the credentials of an SNMPv3 user (RFC 3414).
+type usmUser struct {
+	name      string
+	authProto AuthProtocol
+	privProto PrivProtocol
+	authPass  string
+	privPass  string
+	crypto    CryptoProvider
+
+	once   sync.Once
+	authKu []byte
+	privKu []byte
+
+	mu   sync.Mutex
+	keys map[string]*usmKeys
+
+	// salt is the counter the privacy parameters of outgoing messages
+	// are derived from.
+	salt atomic.Uint64
+}
+
+// usmKeys are a user's keys localized to one engine.
+type usmKeys struct {
+	auth []byte
+	priv []byte
+}
+
+func newUSMUser(name string, authProto AuthProtocol, authPass string, privProto PrivProtocol, privPass string) *usmUser {
+	u := &usmUser{
+		name:      name,
+		authProto: authProto,
+		privProto: privProto,
+		authPass:  authPass,
+		privPass:  privPass,
+		crypto:    StdCryptoProvider{},
+		keys:      make(map[string]*usmKeys),
+	}
+	u.salt.Store(rand.Uint64())
+	return u
+}
+
+// newHash returns a constructor for the user's authentication hash from
+// the crypto provider, which is checked to support the protocol.
+func (u *usmUser) newHash() (func() hash.Hash, error) {
+	if _, err := u.crypto.Hash(u.authProto); err != nil {
+		return nil, err
+	}
+	return func() hash.Hash {
+		h, _ := u.crypto.Hash(u.authProto)
+		return h
+	}, nil
+}
+
+// localizedKeys returns the user's keys localized to engineID. The
+// expensive passphrase derivation is done once per user.
+func (u *usmUser) localizedKeys(engineID []byte) (*usmKeys, error) {
+	if u.authProto == NoAuth {
+		return &usmKeys{}, nil
+	}
+	newHash, err := u.newHash()
+	if err != nil {
+		return nil, err
+	}
+
+	u.once.Do(func() {
+		u.authKu = passwordToKey(newHash, []byte(u.authPass))
+		if u.privProto != NoPriv {
+			u.privKu = passwordToKey(newHash, []byte(u.privPass))
+		}
+	})
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if keys, ok := u.keys[string(engineID)]; ok {
+		return keys, nil
+	}
+
+	keys := &usmKeys{auth: localizeKey(newHash, u.authKu, engineID)}
+	if u.privProto != NoPriv {
+		keys.priv = u.extendPrivKey(newHash, localizeKey(newHash, u.privKu, engineID), engineID)
+	}
+
+	if len(u.keys) >= usmKeyCacheSize {
+		clear(u.keys)
+	}
+	u.keys[string(engineID)] = keys
+	return keys, nil
+}
+
+// extendPrivKey lengthens a localized privacy key that is shorter than the
+// cipher needs, such as an MD5 key for AES-256.
+func (u *usmUser) extendPrivKey(newHash func() hash.Hash, key, engineID []byte) []byte {
+	need := u.privProto.keyLength()
+	for len(key) < need {
+		switch u.privProto {
+		case AES192C, AES256C:
+			// draft-reeder-snmpv3-usm-3desede: localize the previous key
+			// used as a passphrase.
+			key = append(key, localizeKey(newHash, passwordToKey(newHash, key), engineID)...)
+		default:
+			// draft-blumenthal-aes-usm: append the hash of the key so far.
+			h := newHash()
+			h.Write(key)
+			key = append(key, h.Sum(nil)...)
+		}
+	}
+	return key[:need]
+}
+
+// verify checks the HMAC of a whole message, computed with the
+// authentication parameters at authOffset set to zeros.
+func (u *usmUser) verify(keys *usmKeys, msg []byte, authOffset int, authParams []byte) (bool, error) {
+	macLen := u.authProto.macLength()
+	if macLen == 0 || len(authParams) != macLen {
+		return false, nil
+	}
+
+	zeroed := append([]byte(nil), msg...)
+	clear(zeroed[authOffset : authOffset+macLen])
+
+	mac, err := u.crypto.AuthDigest(u.authProto, keys.auth, zeroed)
+	if err != nil {
+		return false, err
+	}
+	return len(mac) >= macLen && hmac.Equal(mac[:macLen], authParams), nil
+}
+
+// decrypt decrypts an encrypted scoped PDU.
+func (u *usmUser) decrypt(keys *usmKeys, data, privParams []byte, boots, engineTime uint32) ([]byte, error) {
+	if len(privParams) != 8 {
+		return nil, fmt.Errorf("%w: privacy parameters must be 8 bytes, got %d", ErrPrivFailure, len(privParams))
+	}
+
+	switch u.privProto {
+	case DES:
+		// DES-CBC (RFC 3414 section 8.1.1.2): the IV is the pre-IV XOR
+		// the salt.
+		if len(data) == 0 || len(data)%des.BlockSize != 0 {
+			return nil, fmt.Errorf("%w: encrypted PDU is not a multiple of the DES block size", ErrPrivFailure)
+		}
+		iv := make([]byte, des.BlockSize)
+		for i := range iv {
+			iv[i] = keys.priv[8+i] ^ privParams[i]
+		}
+		out, err := u.crypto.Decrypt(u.privProto, keys.priv[:8], iv, data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPrivFailure, err)
+		}
+		return out, nil
+
+	case AES, AES192, AES256, AES192C, AES256C:
+		// AES-CFB (RFC 3826 section 3.1.2.1): the IV is engine boots,
+		// engine time and the salt.
+		iv := make([]byte, aes.BlockSize)
+		binary.BigEndian.PutUint32(iv[0:4], boots)
+		binary.BigEndian.PutUint32(iv[4:8], engineTime)
+		copy(iv[8:], privParams)
+		out, err := u.crypto.Decrypt(u.privProto, keys.priv, iv, data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPrivFailure, err)
+		}
+		return out, nil
+
+	default:
+		return nil, fmt.Errorf("%w: privacy not configured for user %q", ErrPrivFailure, u.name)
+	}
+}
+
+// encrypt encrypts a scoped PDU and returns it with the privacy
+// parameters, the salt, to send along.
+func (u *usmUser) encrypt(keys *usmKeys, data []byte, boots, engineTime uint32) ([]byte, []byte, error) {
+	salt := u.salt.Add(1)
+	privParams := make([]byte, 8)
+
+	switch u.privProto {
+	case DES:
+		// DES-CBC (RFC 3414 section 8.1.1.1): the salt is engine boots
+		// and a local counter, the IV the pre-IV XOR the salt. The
+		// plaintext is padded to the block size.
+		binary.BigEndian.PutUint32(privParams[0:4], boots)
+		binary.BigEndian.PutUint32(privParams[4:8], uint32(salt))
+		iv := make([]byte, des.BlockSize)
+		for i := range iv {
+			iv[i] = keys.priv[8+i] ^ privParams[i]
+		}
+		if rem := len(data) % des.BlockSize; rem != 0 {
+			data = append(data[:len(data):len(data)], make([]byte, des.BlockSize-rem)...)
+		}
+		out, err := u.crypto.Encrypt(u.privProto, keys.priv[:8], iv, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrPrivFailure, err)
+		}
+		return out, privParams, nil
+
+	case AES, AES192, AES256, AES192C, AES256C:
+		// AES-CFB (RFC 3826 section 3.1.2.1): the salt is a 64-bit
+		// counter.
+		binary.BigEndian.PutUint64(privParams, salt)
+		iv := make([]byte, aes.BlockSize)
+		binary.BigEndian.PutUint32(iv[0:4], boots)
+		binary.BigEndian.PutUint32(iv[4:8], engineTime)
+		copy(iv[8:], privParams)
+		out, err := u.crypto.Encrypt(u.privProto, keys.priv, iv, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrPrivFailure, err)
+		}
+		return out, privParams, nil
+
+	default:
+		return nil, nil, fmt.Errorf("%w: privacy not configured for user %q", ErrPrivFailure, u.name)
+	}
+}
+
+// v3Header holds the header fields of an outgoing SNMPv3 message. The
+// engine fields are those of the authoritative engine: the agent for
+// requests and informs, this client for traps.
+type v3Header struct {
+	MsgID       int32
+	MaxSize     int
+	Flags       byte
+	EngineID    []byte
+	EngineBoots uint32
+	EngineTime  uint32
+	UserName    string
+}
+
+// encodeV3Message encodes an SNMPv3 message around an encoded scoped PDU,
+// encrypting and authenticating it for user as the header flags request.
+// user may be nil for noAuthNoPriv messages.
+func encodeV3Message(h v3Header, user *usmUser, scoped []byte) ([]byte, error) {
+	var (
+		keys       *usmKeys
+		authParams []byte
+		privParams []byte
+		msgData    = scoped
+	)
+	if h.Flags&msgFlagAuth != 0 {
+		if user == nil || user.authProto == NoAuth {
+			return nil, fmt.Errorf("%w: authentication not configured for user %q", ErrAuthFailure, h.UserName)
+		}
+		var err error
+		if keys, err = user.localizedKeys(h.EngineID); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrAuthFailure, err)
+		}
+		authParams = make([]byte, user.authProto.macLength())
+
+		if h.Flags&msgFlagPriv != 0 {
+			encrypted, salt, err := user.encrypt(keys, scoped, h.EngineBoots, h.EngineTime)
+			if err != nil {
+				return nil, err
+			}
+			msgData = encodeTLV(TypeOctetString, encrypted)
+			privParams = salt
+		}
+	}
+
+	var global bytes.Buffer
+	global.Write(encodeTLV(TypeInteger, encodeInteger(int64(h.MsgID))))
+	global.Write(encodeTLV(TypeInteger, encodeInteger(int64(h.MaxSize))))
+	global.Write(encodeTLV(TypeOctetString, []byte{h.Flags}))
+	global.Write(encodeTLV(TypeInteger, encodeInteger(usmSecurityModel)))
+
+	var usm bytes.Buffer
+	usm.Write(encodeTLV(TypeOctetString, h.EngineID))
+	usm.Write(encodeTLV(TypeInteger, encodeInteger(int64(h.EngineBoots))))
+	usm.Write(encodeTLV(TypeInteger, encodeInteger(int64(h.EngineTime))))
+	usm.Write(encodeTLV(TypeOctetString, []byte(h.UserName)))
+	usm.Write(encodeTLV(TypeOctetString, authParams))
+	usm.Write(encodeTLV(TypeOctetString, privParams))
+
+	var buf bytes.Buffer
+	buf.Write(encodeTLV(TypeInteger, encodeInteger(int64(Version3))))
+	buf.Write(encodeTLV(TypeSequence, global.Bytes()))
+	buf.Write(encodeTLV(TypeOctetString, encodeTLV(TypeSequence, usm.Bytes())))
+	buf.Write(msgData)
+	msg := encodeTLV(TypeSequence, buf.Bytes())
+
+	if keys == nil {
+		return msg, nil
+	}
+
+	// The HMAC covers the whole message with the authentication
+	// parameters still zeroed (RFC 3414 section 6.3.1).
+	parsed, err := decodeV3Message(msg)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := user.crypto.AuthDigest(user.authProto, keys.auth, msg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthFailure, err)
+	}
+	if len(mac) < len(authParams) {
+		return nil, fmt.Errorf("%w: digest too short", ErrAuthFailure)
+	}
+	copy(msg[parsed.authOffset:], mac[:len(authParams)])
+	return msg, nil
+}
+
+// v3Message is a parsed SNMPv3 message (RFC 3412) with USM security
+// parameters (RFC 3414).
+type v3Message struct {
+	MsgID           int32
+	MaxSize         int
+	Flags           byte
+	EngineID        []byte
+	EngineBoots     uint32
+	EngineTime      uint32
+	UserName        string
+	AuthParams      []byte
+	PrivParams      []byte
+	ScopedPDU       *ScopedPDU
+	authOffset      int
+	encryptedPDU    []byte
+	plaintextScoped []byte
+}
+
+// securityLevel returns the security level indicated by the message flags.
+func (m *v3Message) securityLevel() SecurityLevel {
+	switch {
+	case m.Flags&msgFlagPriv != 0:
+		return AuthPriv
+	case m.Flags&msgFlagAuth != 0:
+		return AuthNoPriv
+	default:
+		return NoAuthNoPriv
+	}
+}
+
+// berElement locates the TLV at data[off:], returning its type and the
+// bounds of its value.
+func berElement(data []byte, off int) (BERType, int, int, error) {
+	if off >= len(data) {
+		return 0, 0, 0, NewParseError("unexpected end of message", off)
+	}
+	r := bytes.NewReader(data[off+1:])
+	length, err := decodeLength(r)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	start := len(data) - r.Len()
+	end := start + length
+	if length < 0 || end > len(data) {
+		return 0, 0, 0, NewParseError("element exceeds message", off)
+	}
+	return BERType(data[off]), start, end, nil
+}
+
+// decodeV3Message parses an SNMPv3 message, leaving an encrypted scoped PDU
+// undecoded. It returns ErrInvalidVersion if data is not a v3 message.
+func decodeV3Message(data []byte) (*v3Message, error) {
+	pos := 0
+	expect := func(want BERType, limit int) (int, int, error) {
+		if pos >= limit {
+			return 0, 0, NewParseError(fmt.Sprintf("missing %s", want), pos)
+		}
+		t, start, end, err := berElement(data[:limit], pos)
+		if err != nil {
+			return 0, 0, err
+		}
+		if t != want {
+			return 0, 0, NewParseError(fmt.Sprintf("expected %s, got %s", want, t), pos)
+		}
+		pos = end
+		return start, end, nil
+	}
+	integer := func(limit int) (int64, error) {
+		start, end, err := expect(TypeInteger, limit)
+		if err != nil {
+			return 0, err
+		}
+		return decodeInteger(data[start:end]), nil
+	}
+	octets := func(limit int) ([]byte, int, error) {
+		start, end, err := expect(TypeOctetString, limit)
+		if err != nil {
+			return nil, 0, err
+		}
+		return data[start:end], start, nil
+	}
+
+	msgStart, msgEnd, err := expect(TypeSequence, len(data))
+	if err != nil {
+		return nil, err
+	}
+	pos = msgStart
+
+	version, err := integer(msgEnd)
+	if err != nil {
+		return nil, err
+	}
+	if SNMPVersion(version) != Version3 {
+		return nil, ErrInvalidVersion
+	}
+
+	msg := &v3Message{}
+
+	// msgGlobalData
+	globalStart, globalEnd, err := expect(TypeSequence, msgEnd)
+	if err != nil {
+		return nil, err
+	}
+	pos = globalStart
+	msgID, err := integer(globalEnd)
+	if err != nil {
+		return nil, err
+	}
+	maxSize, err := integer(globalEnd)
+	if err != nil {
+		return nil, err
+	}
+	flags, _, err := octets(globalEnd)
+	if err != nil {
+		return nil, err
+	}
+	if len(flags) != 1 {
+		return nil, NewParseError(fmt.Sprintf("msgFlags must be 1 byte, got %d", len(flags)), pos)
+	}
+	model, err := integer(globalEnd)
+	if err != nil {
+		return nil, err
+	}
+	if model != usmSecurityModel {
+		return nil, fmt.Errorf("%w: unsupported security model %d", ErrInvalidPacket, model)
+	}
+	msg.MsgID = int32(msgID)
+	msg.MaxSize = int(maxSize)
+	msg.Flags = flags[0]
+	if msg.Flags&msgFlagPriv != 0 && msg.Flags&msgFlagAuth == 0 {
+		return nil, fmt.Errorf("%w: privacy requested without authentication", ErrInvalidPacket)
+	}
+	pos = globalEnd
+
+	// msgSecurityParameters: an OCTET STRING wrapping UsmSecurityParameters
+	paramsStart, paramsEnd, err := expect(TypeOctetString, msgEnd)
+	if err != nil {
+		return nil, err
+	}
+	afterParams := pos
+	pos = paramsStart
+	usmStart, usmEnd, err := expect(TypeSequence, paramsEnd)
+	if err != nil {
+		return nil, err
+	}
+	pos = usmStart
+	if msg.EngineID, _, err = octets(usmEnd); err != nil {
+		return nil, err
+	}
+	boots, err := integer(usmEnd)
+	if err != nil {
+		return nil, err
+	}
+	engineTime, err := integer(usmEnd)
+	if err != nil {
+		return nil, err
+	}
+	msg.EngineBoots = uint32(boots)
+	msg.EngineTime = uint32(engineTime)
+	userName, _, err := octets(usmEnd)
+	if err != nil {
+		return nil, err
+	}
+	msg.UserName = string(userName)
+	if msg.AuthParams, msg.authOffset, err = octets(usmEnd); err != nil {
+		return nil, err
+	}
+	if msg.PrivParams, _, err = octets(usmEnd); err != nil {
+		return nil, err
+	}
+	pos = afterParams
+
+	// msgData: a plaintext ScopedPDU or an encrypted OCTET STRING
+	if pos >= msgEnd {
+		return nil, NewParseError("missing scoped PDU", pos)
+	}
+	t, dataStart, dataEnd, err := berElement(data[:msgEnd], pos)
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case TypeSequence:
+		msg.plaintextScoped = data[pos:dataEnd]
+	case TypeOctetString:
+		msg.encryptedPDU = data[dataStart:dataEnd]
+	default:
+		return nil, NewParseError(fmt.Sprintf("expected scoped PDU, got %s", t), pos)
+	}
+
+	return msg, nil
+}
+
+// open authenticates and decrypts a message for user and decodes its
+// scoped PDU. A nil user is only accepted for noAuthNoPriv messages.
+func (m *v3Message) open(data []byte, user *usmUser) error {
+	if m.Flags&msgFlagAuth != 0 {
+		if user == nil {
+			return fmt.Errorf("%w: unknown user %q", ErrAuthFailure, m.UserName)
+		}
+		if user.authProto == NoAuth {
+			return fmt.Errorf("%w: authentication not configured for user %q", ErrAuthFailure, m.UserName)
+		}
+		keys, err := user.localizedKeys(m.EngineID)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrAuthFailure, err)
+		}
+		ok, err := user.verify(keys, data, m.authOffset, m.AuthParams)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrAuthFailure, err)
+		}
+		if !ok {
+			return fmt.Errorf("%w: wrong digest for user %q", ErrAuthFailure, m.UserName)
+		}
+
+		if m.Flags&msgFlagPriv != 0 {
+			if m.encryptedPDU == nil {
+				return fmt.Errorf("%w: scoped PDU is not encrypted", ErrPrivFailure)
+			}
+			plain, err := user.decrypt(keys, m.encryptedPDU, m.PrivParams, m.EngineBoots, m.EngineTime)
+			if err != nil {
+				return err
+			}
+			m.plaintextScoped = plain
+		}
+	}
+
+	if m.plaintextScoped == nil {
+		return fmt.Errorf("%w: unexpected encrypted scoped PDU", ErrPrivFailure)
+	}
+	scoped, err := DecodeScopedPDU(m.plaintextScoped)
+	if err != nil {
+		if m.Flags&msgFlagPriv != 0 {
+			return fmt.Errorf("%w: decryption failed: %v", ErrPrivFailure, err)
+		}
+		return err
+	}
+	m.ScopedPDU = scoped
+	return nil
+}
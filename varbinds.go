@@ -0,0 +1,70 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import "iter"
+
+// OrderedVarbinds is a set of variables that keeps the order they were
+// added in, typically tree order from a walk, and can also be looked up by
+// OID. The zero value is empty and ready to use.
+type OrderedVarbinds struct {
+	vars  []Variable
+	index map[string]int
+}
+
+// Add appends v, replacing the value of an existing variable with the
+// same OID in place.
+func (o *OrderedVarbinds) Add(v Variable) {
+	key := v.OID.String()
+	if i, ok := o.index[key]; ok {
+		o.vars[i] = v
+		return
+	}
+	if o.index == nil {
+		o.index = make(map[string]int)
+	}
+	o.index[key] = len(o.vars)
+	o.vars = append(o.vars, v)
+}
+
+// Get returns the variable with the given OID.
+func (o *OrderedVarbinds) Get(oid OID) (Variable, bool) {
+	i, ok := o.index[oid.String()]
+	if !ok {
+		return Variable{}, false
+	}
+	return o.vars[i], true
+}
+
+// Len returns the number of variables.
+func (o *OrderedVarbinds) Len() int {
+	return len(o.vars)
+}
+
+// All iterates over the variables in order.
+func (o *OrderedVarbinds) All() iter.Seq[Variable] {
+	return func(yield func(Variable) bool) {
+		for _, v := range o.vars {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Variables returns a copy of the variables in order.
+func (o *OrderedVarbinds) Variables() []Variable {
+	return append([]Variable(nil), o.vars...)
+}
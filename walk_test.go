@@ -0,0 +1,205 @@
+// Copyright 2025 Edgeo SCADA
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// walkAgent answers the first GETBULK with vars and every later one with
+// an OID past the subtree.
+func walkAgent(t *testing.T, vars ...Variable) int {
+	first := true
+	return mockAgent(t, func(req *Message) *Message {
+		if first {
+			first = false
+			return reply(req, vars...)
+		}
+		return reply(req, Variable{OID: MustParseOID("1.3.6.1.4.1.99999.2"), Type: TypeInteger, Value: 0})
+	})
+}
+
+func intVar(oid string) Variable {
+	return Variable{OID: MustParseOID(oid), Type: TypeInteger, Value: 1}
+}
+
+func TestLenientWalkAnomalies(t *testing.T) {
+	port := walkAgent(t,
+		intVar("1.3.6.1.4.1.99999.1.1"),
+		intVar("1.3.6.1.4.1.99999.1.3"),
+		intVar("1.3.6.1.4.1.99999.1.2"),
+		intVar("1.3.6.1.4.1.99999.1.4"))
+	c := newTestClient(t, port, WithLenientWalk(true))
+
+	vars, stats, err := c.WalkWithStats(context.Background(), MustParseOID("1.3.6.1.4.1.99999.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != 3 {
+		t.Errorf("walk returned %d variables, want 3", len(vars))
+	}
+	if stats.Anomalies != 1 {
+		t.Errorf("Anomalies = %d, want 1", stats.Anomalies)
+	}
+}
+
+func TestWalkTermination(t *testing.T) {
+	tests := []struct {
+		name string
+		vars []Variable
+		want int
+	}{
+		{"empty response", nil, 0},
+		{"left subtree", []Variable{
+			intVar("1.3.6.1.4.1.99999.1.1"),
+			intVar("1.3.6.1.4.1.99999.2"),
+			intVar("1.3.6.1.4.1.99999.1.2"),
+		}, 1},
+		{"echoed OID", []Variable{
+			intVar("1.3.6.1.4.1.99999.1.1"),
+			intVar("1.3.6.1.4.1.99999.1.1"),
+		}, 1},
+	}
+	root := MustParseOID("1.3.6.1.4.1.99999.1")
+	for _, tt := range tests {
+		for _, lenient := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/lenient=%t", tt.name, lenient), func(t *testing.T) {
+				c := newTestClient(t, walkAgent(t, tt.vars...), WithLenientWalk(lenient))
+				vars, err := c.Walk(context.Background(), root)
+				if err != nil {
+					t.Fatalf("Walk: %v", err)
+				}
+				if len(vars) != tt.want {
+					t.Errorf("Walk returned %d variables, want %d", len(vars), tt.want)
+				}
+
+				c = newTestClient(t, walkAgent(t, tt.vars...), WithLenientWalk(lenient))
+				n := 0
+				err = c.WalkFunc(context.Background(), root, func(Variable) error {
+					n++
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("WalkFunc: %v", err)
+				}
+				if n != tt.want {
+					t.Errorf("WalkFunc delivered %d variables, want %d", n, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestWalkOIDNotIncreasing(t *testing.T) {
+	port := walkAgent(t,
+		intVar("1.3.6.1.4.1.99999.1.2"),
+		intVar("1.3.6.1.4.1.99999.1.1"))
+	c := newTestClient(t, port)
+
+	_, err := c.Walk(context.Background(), MustParseOID("1.3.6.1.4.1.99999.1"))
+	if !errors.Is(err, ErrOIDNotIncreasing) {
+		t.Fatalf("walk error = %v, want %v", err, ErrOIDNotIncreasing)
+	}
+}
+
+// TestIsWalkDone covers the exception types as well, which the mock agent
+// cannot encode.
+func TestIsWalkDone(t *testing.T) {
+	root := MustParseOID("1.3.6.1.4.1.99999.1")
+	prev := MustParseOID("1.3.6.1.4.1.99999.1.1")
+	tests := []struct {
+		name string
+		vars []Variable
+		want bool
+	}{
+		{"empty list", nil, true},
+		{"endOfMibView", []Variable{{OID: MustParseOID("1.3.6.1.4.1.99999.1.2"), Type: TypeEndOfMibView}}, true},
+		{"noSuchObject", []Variable{{OID: MustParseOID("1.3.6.1.4.1.99999.1.2"), Type: TypeNoSuchObject}}, true},
+		{"noSuchInstance", []Variable{{OID: MustParseOID("1.3.6.1.4.1.99999.1.2"), Type: TypeNoSuchInstance}}, true},
+		{"left subtree", []Variable{intVar("1.3.6.1.4.1.99999.2")}, true},
+		{"sibling prefix", []Variable{intVar("1.3.6.1.4.1.99999.10")}, true},
+		{"echoed OID", []Variable{intVar("1.3.6.1.4.1.99999.1.1")}, true},
+		{"next OID", []Variable{intVar("1.3.6.1.4.1.99999.1.2"), intVar("1.3.6.1.4.1.99999.2")}, false},
+		{"earlier OID", []Variable{intVar("1.3.6.1.4.1.99999.1.0")}, false},
+	}
+	for _, tt := range tests {
+		if got := isWalkDone(root, prev, tt.vars); got != tt.want {
+			t.Errorf("%s: isWalkDone = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConcurrentWalkMaxRepetitions(t *testing.T) {
+	const rows = 40
+	roots := map[int]int{1: 5, 2: 20} // subtree -> max-repetitions
+	base := MustParseOID("1.3.6.1.4.1.99999")
+
+	var mu sync.Mutex
+	seen := make(map[int][]int) // subtree -> max-repetitions on the wire
+	port := mockAgent(t, func(req *Message) *Message {
+		oid := req.PDU.Variables[0].OID
+		subtree, next := oid[len(base)], 1
+		if len(oid) > len(base)+1 {
+			next = oid[len(base)+1] + 1
+		}
+		mu.Lock()
+		seen[subtree] = append(seen[subtree], req.PDU.MaxRepetitions)
+		mu.Unlock()
+
+		var vars []Variable
+		for i := 0; i < req.PDU.MaxRepetitions; i++ {
+			if next+i > rows {
+				vars = append(vars, intVar("1.3.6.1.4.1.99999.3"))
+				break
+			}
+			vars = append(vars, Variable{OID: append(base.Copy(), subtree, next+i), Type: TypeInteger, Value: next + i})
+		}
+		return reply(req, vars...)
+	})
+	c := newTestClient(t, port, WithMaxRepetitions(10))
+
+	var wg sync.WaitGroup
+	for subtree, reps := range roots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := WithRequestOptions(context.Background(), WithRequestMaxRepetitions(reps))
+			vars, err := c.Walk(ctx, base.Append(subtree))
+			if err != nil {
+				t.Errorf("walk of subtree %d: %v", subtree, err)
+			} else if len(vars) != rows {
+				t.Errorf("walk of subtree %d returned %d variables, want %d", subtree, len(vars), rows)
+			}
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for subtree, reps := range roots {
+		if len(seen[subtree]) == 0 {
+			t.Errorf("no requests for subtree %d", subtree)
+		}
+		for _, got := range seen[subtree] {
+			if got != reps {
+				t.Errorf("subtree %d request max-repetitions = %d, want %d", subtree, got, reps)
+			}
+		}
+	}
+}